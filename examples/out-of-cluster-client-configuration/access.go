@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// resourceAccess is a resource a source lists and watches.
+type resourceAccess struct {
+	group, resource string
+	// namespaced resources are listed in the namespace of the scope.
+	namespaced bool
+	// verbs are what is done with the resource, list and watch if empty.
+	verbs []string
+}
+
+// sourceResources are the resources every source of -resources reads.
+var sourceResources = map[string][]resourceAccess{
+	"ingresses":   {{group: "extensions", resource: "ingresses", namespaced: true}},
+	"routes":      {{group: routesResource.Group, resource: routesResource.Resource, namespaced: true}},
+	"gateways":    {{group: gatewayGroup, resource: "gateways", namespaced: true}, {group: gatewayGroup, resource: "httproutes"}},
+	"istio":       {{group: istioGroup, resource: "gateways", namespaced: true}, {group: istioGroup, resource: "virtualservices"}},
+	"webhooks":    {{group: admissionGroup, resource: "validatingwebhookconfigurations"}, {group: admissionGroup, resource: "mutatingwebhookconfigurations"}},
+	"apiservices": {{group: apiRegistrationGroup, resource: "apiservices"}},
+	"crds":        {{group: apiExtensionsGroup, resource: "customresourcedefinitions"}},
+	"services":    {{resource: "services", namespaced: true}},
+	"nodes":       {{resource: "nodes"}},
+	"secrets":     {{resource: "secrets", namespaced: true}},
+}
+
+// certSourceResources are the resources read by every -source, other than
+// the ones of -resources: the Secrets referenced by ingresses are read one
+// at a time.
+var certSourceResources = map[string][]resourceAccess{
+	"secret":  {{resource: "secrets", namespaced: true, verbs: []string{"get"}}},
+	"compare": {{resource: "secrets", namespaced: true, verbs: []string{"get"}}},
+}
+
+// deniedResources returns what client may not do with the resources read by
+// the sources of resources and by certSource, see -source, when namespaced
+// resources are read in namespace (all of them when empty). With --as, it is
+// what the impersonated user lacks to be scanned, so that missing
+// permissions fail the run up front instead of leaving informers retrying
+// forever.
+func deniedResources(client authorizationclient.SelfSubjectAccessReviewsGetter, resources []string, certSource, namespace string) ([]string, error) {
+	var accessed []resourceAccess
+	for _, name := range resources {
+		accessed = append(accessed, sourceResources[strings.TrimSpace(name)]...)
+	}
+	accessed = append(accessed, certSourceResources[certSource]...)
+	var denied []string
+	for _, r := range accessed {
+		attributes := authorizationv1.ResourceAttributes{Group: r.group, Resource: r.resource}
+		var where string
+		if r.namespaced {
+			attributes.Namespace = namespace
+			where = " in all namespaces"
+			if namespace != "" {
+				where = " in namespace " + namespace
+			}
+		}
+		verbs := r.verbs
+		if len(verbs) == 0 {
+			verbs = []string{"list", "watch"}
+		}
+		for _, verb := range verbs {
+			attributes.Verb = verb
+			review, err := client.SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !review.Status.Allowed {
+				denied = append(denied, verb+" "+groupResource(r)+where)
+			}
+		}
+	}
+	return denied, nil
+}
+
+// groupResource returns the resource of r qualified by its group, as RBAC
+// rules are written.
+func groupResource(r resourceAccess) string {
+	if r.group == "" {
+		return r.resource
+	}
+	return r.resource + "." + r.group
+}
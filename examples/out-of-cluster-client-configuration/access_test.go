@@ -0,0 +1,59 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestDeniedResources(t *testing.T) {
+	// The tenant may only read the ingresses and services of its namespace.
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = attributes.Namespace == "shop" && (attributes.Resource == "ingresses" || attributes.Resource == "services")
+		return true, review, nil
+	})
+
+	denied, err := deniedResources(clientset.AuthorizationV1(), []string{"ingresses", "services"}, "dial", "shop")
+	if err != nil || len(denied) != 0 {
+		t.Errorf("expected the tenant to read its namespace, got %v, %v", denied, err)
+	}
+	denied, err = deniedResources(clientset.AuthorizationV1(), []string{"ingresses", " nodes", "secrets"}, "compare", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"list ingresses.extensions in all namespaces",
+		"watch ingresses.extensions in all namespaces",
+		"list nodes",
+		"watch nodes",
+		"list secrets in all namespaces",
+		"watch secrets in all namespaces",
+		"get secrets in all namespaces",
+	}
+	if !reflect.DeepEqual(denied, expected) {
+		t.Errorf("expected %v to be denied, got %v", expected, denied)
+	}
+}
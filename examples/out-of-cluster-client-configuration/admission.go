@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// admissionWarningAnnotation is the audit annotation of admitted Ingresses
+// whose certificates need attention.
+const admissionWarningAnnotation = "cert-check/warning"
+
+// maxAdmissionReviewBytes bounds the size of the admission reviews read.
+const maxAdmissionReviewBytes = 3 << 20
+
+// admissionWebhook is a validating admission webhook for Ingresses: it
+// looks up the certificate in every TLS Secret an Ingress references while
+// it is created or updated, and denies the request if one of them is at
+// least at severity deny. Requests with certificates that need attention
+// but are not denied are admitted with an audit annotation.
+//
+// Secrets that do not exist yet, or cannot be read, never deny a request:
+// they are usually created after the Ingress, e.g. by cert-manager.
+type admissionWebhook struct {
+	// secretCertificate returns the leaf certificate of a Secret.
+	secretCertificate func(ctx context.Context, namespace, name string) (*certificate, error)
+	// namespaceAnnotations, if set, returns the annotations of a namespace,
+	// for its warning window and whether it is ignored.
+	namespaceAnnotations func(ctx context.Context, namespace string) map[string]string
+	policy               policy
+	// deny is severityExpired or severityWarning.
+	deny severity
+	now  func() time.Time
+}
+
+func (w *admissionWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, maxAdmissionReviewBytes))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(rw, "expected an AdmissionReview with a request", http.StatusBadRequest)
+		return
+	}
+	review.Response = w.review(req.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		klog.ErrorS(err, "Writing the admission response failed")
+	}
+}
+
+// review admits or denies the Ingress of req.
+func (w *admissionWebhook) review(ctx context.Context, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	allowed := &admissionv1beta1.AdmissionResponse{Allowed: true}
+	if req.Kind.Kind != ingressKind {
+		return allowed
+	}
+	// The Ingresses of extensions/v1beta1 and networking.k8s.io/v1beta1 are
+	// the same.
+	ing := &v1beta1.Ingress{}
+	if err := json.Unmarshal(req.Object.Raw, ing); err != nil {
+		return &admissionv1beta1.AdmissionResponse{Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: fmt.Sprintf("invalid Ingress: %v", err),
+		}}
+	}
+	namespace := ing.Namespace
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+
+	if ignored(namespace, ing.Name, ing.Annotations) {
+		return allowed
+	}
+	// The warning window of the Ingress takes precedence over the one of
+	// its namespace.
+	t := target{namespace: namespace, kind: ingressKind, object: ing.Name, warnBefore: annotatedWarnBefore(namespace, ing.Name, ing.Annotations)}
+	if w.namespaceAnnotations != nil {
+		annotations := w.namespaceAnnotations(ctx, namespace)
+		if ignored("", namespace, annotations) {
+			return allowed
+		}
+		if t.warnBefore == 0 {
+			t.warnBefore = annotatedWarnBefore("", namespace, annotations)
+		}
+	}
+
+	now := w.now()
+	var denied, warnings []string
+	seen := map[string]bool{}
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" || seen[tls.SecretName] {
+			continue
+		}
+		seen[tls.SecretName] = true
+		c, err := w.secretCertificate(ctx, namespace, tls.SecretName)
+		if err != nil {
+			klog.V(2).InfoS("Admitting Ingress without a readable Secret", "ingress", klog.KRef(namespace, ing.Name), "secret", tls.SecretName, "err", err)
+			continue
+		}
+		sev := w.policy.severity(result{target: t, certificate: c}, now)
+		if sev == severityOK {
+			continue
+		}
+		msg := fmt.Sprintf("secret %s/%s: %s", namespace, tls.SecretName, admissionMessage(t, c, sev, w.policy, now))
+		if sev >= w.deny {
+			denied = append(denied, msg)
+		} else {
+			warnings = append(warnings, msg)
+		}
+	}
+
+	if len(denied) > 0 {
+		klog.InfoS("Denied Ingress", "ingress", klog.KRef(namespace, ing.Name), "operation", req.Operation, "reasons", denied)
+		return &admissionv1beta1.AdmissionResponse{Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: strings.Join(denied, "; "),
+		}}
+	}
+	if len(warnings) > 0 {
+		klog.InfoS("Admitted Ingress with warnings", "ingress", klog.KRef(namespace, ing.Name), "operation", req.Operation, "warnings", warnings)
+		allowed.AuditAnnotations = map[string]string{admissionWarningAnnotation: strings.Join(warnings, "; ")}
+	}
+	return allowed
+}
+
+// admissionMessage says what is wrong with c, the certificate of t, at
+// severity sev under p.
+func admissionMessage(t target, c *certificate, sev severity, p policy, now time.Time) string {
+	switch {
+	case sev == severityExpired:
+		return fmt.Sprintf("certificate expired %s", c.NotAfter.Format("2006-01-02"))
+	case p.expiresSoon(t, c, now):
+		return fmt.Sprintf("certificate expires %s, in %d days", c.NotAfter.Format("2006-01-02"), int(c.NotAfter.Sub(now).Hours()/24))
+	case len(p.weakKeys(c)) > 0:
+		return strings.Join(p.weakKeys(c), ", ")
+	case c.Sunset != nil && !c.NotAfter.Before(*c.Sunset):
+		return fmt.Sprintf("certificate signed with %s, which is distrusted from %s", c.Algorithm, c.Sunset.Format("2006-01-02"))
+	}
+	return "certificate is " + strings.ToLower(sev.String())
+}
+
+// parseAdmissionDeny parses the value of -webhook-deny: expired or warning.
+func parseAdmissionDeny(s string) (severity, error) {
+	switch s {
+	case "expired":
+		return severityExpired, nil
+	case "warning":
+		return severityWarning, nil
+	}
+	return severityOK, fmt.Errorf("invalid -webhook-deny %q: must be expired or warning", s)
+}
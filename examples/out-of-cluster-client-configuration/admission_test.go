@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAdmissionWebhook(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	secrets := map[string]*certificate{
+		"shop/valid":    {NotAfter: now.AddDate(1, 0, 0)},
+		"shop/expiring": {NotAfter: now.AddDate(0, 0, 3)},
+		"shop/expired":  {NotAfter: now.AddDate(0, 0, -1)},
+	}
+	w := &admissionWebhook{
+		secretCertificate: func(ctx context.Context, namespace, name string) (*certificate, error) {
+			if c, ok := secrets[namespace+"/"+name]; ok {
+				return c, nil
+			}
+			return nil, errors.New("not found")
+		},
+		policy: policy{days: 30},
+		deny:   severityExpired,
+		now:    func() time.Time { return now },
+	}
+
+	tests := []struct {
+		secrets    []string
+		deny       severity
+		allowed    bool
+		message    string
+		annotation string
+	}{
+		{secrets: []string{"valid"}, deny: severityExpired, allowed: true},
+		// Not issued yet.
+		{secrets: []string{"missing"}, deny: severityExpired, allowed: true},
+		{secrets: []string{"valid", "expired"}, deny: severityExpired, message: "secret shop/expired: certificate expired 2019-10-13"},
+		{secrets: []string{"expiring"}, deny: severityExpired, allowed: true, annotation: "secret shop/expiring: certificate expires 2019-10-17, in 3 days"},
+		{secrets: []string{"expiring"}, deny: severityWarning, message: "secret shop/expiring: certificate expires 2019-10-17, in 3 days"},
+	}
+	for _, test := range tests {
+		w.deny = test.deny
+		ing := &v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+		for _, secret := range test.secrets {
+			ing.Spec.TLS = append(ing.Spec.TLS, v1beta1.IngressTLS{Hosts: []string{"shop.example.com"}, SecretName: secret})
+		}
+		raw, err := json.Marshal(ing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(&admissionv1beta1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+			Request: &admissionv1beta1.AdmissionRequest{
+				UID:       types.UID("42"),
+				Kind:      metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1beta1", Kind: ingressKind},
+				Namespace: "shop",
+				Operation: admissionv1beta1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%v: unexpected status %d: %s", test.secrets, rec.Code, rec.Body)
+		}
+		review := &admissionv1beta1.AdmissionReview{}
+		if err := json.Unmarshal(rec.Body.Bytes(), review); err != nil {
+			t.Fatal(err)
+		}
+		resp := review.Response
+		if resp == nil || resp.UID != "42" {
+			t.Fatalf("%v: expected the response to request 42, got %+v", test.secrets, resp)
+		}
+		if resp.Allowed != test.allowed {
+			t.Errorf("%v at %s: expected allowed=%v, got %+v", test.secrets, test.deny, test.allowed, resp)
+		}
+		if test.message != "" && (resp.Result == nil || resp.Result.Message != test.message) {
+			t.Errorf("%v at %s: expected %q, got %+v", test.secrets, test.deny, test.message, resp.Result)
+		}
+		if got := resp.AuditAnnotations[admissionWarningAnnotation]; got != test.annotation {
+			t.Errorf("%v at %s: expected the audit annotation %q, got %q", test.secrets, test.deny, test.annotation, got)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader("{}")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a review without a request to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertName is the alertname label of every alert pushed to Alertmanager.
+const alertName = "TLSCertificateExpiry"
+
+// alertSeverities are the values of the severity label of pushed alerts.
+var alertSeverities = []string{"warning", "critical"}
+
+// alertmanagerNotifier pushes a firing alert for every finding to the
+// Alertmanager v2 API, and a resolved one for every host that is fine, so
+// that renewed certificates resolve their alerts without further state.
+type alertmanagerNotifier struct {
+	url string
+	// resolveAfter is how long a pushed alert keeps firing if it is not
+	// pushed again.
+	resolveAfter time.Duration
+	client       *http.Client
+}
+
+// alert is an alert as accepted by POST /api/v2/alerts.
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt,omitempty"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+func (n *alertmanagerNotifier) resolvesFindings() {}
+
+func (n *alertmanagerNotifier) notify(ctx context.Context, s *summary) error {
+	var alerts []alert
+	for _, ns := range s.Namespaces {
+		for _, f := range ns.Findings {
+			severity := alertSeverity(f.Severity)
+			a := alert{
+				Labels:      alertLabels(f.Cluster, f.Namespace, f.Kind, f.Object, f.Host, severity),
+				Annotations: map[string]string{},
+				EndsAt:      s.Time.Add(n.resolveAfter),
+			}
+			if f.Error != "" {
+				a.Annotations["summary"] = fmt.Sprintf("TLS certificate of %s could not be checked", f.Host)
+				a.Annotations["error"] = f.Error
+			} else {
+				a.Annotations["summary"] = fmt.Sprintf("TLS certificate of %s expires in %d days", f.Host, f.DaysRemaining)
+				a.Annotations["days_remaining"] = strconv.Itoa(f.DaysRemaining)
+				a.Annotations["expires"] = f.NotAfter.Format(time.RFC3339)
+			}
+			alerts = append(alerts, a)
+			// Resolve the alert of the other severity, in case the host
+			// went from warning to critical or back.
+			for _, other := range alertSeverities {
+				if other != severity {
+					alerts = append(alerts, alert{Labels: alertLabels(f.Cluster, f.Namespace, f.Kind, f.Object, f.Host, other), EndsAt: s.Time})
+				}
+			}
+		}
+	}
+	for _, t := range s.fine {
+		for _, severity := range alertSeverities {
+			alerts = append(alerts, alert{Labels: alertLabels(t.cluster, t.namespace, t.kind, t.object, t.name(), severity), EndsAt: s.Time})
+		}
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.client, strings.TrimSuffix(n.url, "/")+"/api/v2/alerts", "application/json", body)
+}
+
+// alertSeverity maps the severity of a finding to the severity label of its
+// alert.
+func alertSeverity(s string) string {
+	if s == severityWarning.String() {
+		return "warning"
+	}
+	return "critical"
+}
+
+// alertLabels returns the labels of the alert about host of the object of
+// kind, e.g. kind=Ingress and object=web, which is also labeled with its
+// cluster when several clusters are scanned. Kinds are values rather than
+// label names, which they might not be valid as or clash with. Days remaining
+// are an annotation rather than a label: a label changing every day would
+// start a new alert every day.
+func alertLabels(cluster, namespace, kind, object, host, severity string) map[string]string {
+	labels := map[string]string{
+		"alertname": alertName,
+		"namespace": namespace,
+		"kind":      kind,
+		"object":    object,
+		"host":      host,
+		"severity":  severity,
+	}
+	if cluster != "" {
+		labels["cluster"] = cluster
+	}
+	return labels
+}
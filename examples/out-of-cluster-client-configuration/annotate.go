@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	extensionsclient "k8s.io/client-go/kubernetes/typed/extensions/v1beta1"
+)
+
+// annotationNotifier writes the expiry and the status of the hosts of every
+// checked ingress back to it as annotations, for other controllers and
+// dashboards to read. It is a resolving notifier: ingresses whose hosts are
+// all fine are annotated too.
+type annotationNotifier struct {
+	client extensionsclient.IngressesGetter
+	policy policy
+	// conditions enables writing the CertExpiryHealthy condition too.
+	conditions bool
+}
+
+func (n *annotationNotifier) resolvesFindings() {}
+
+// ingressStatus is what gets written to an ingress.
+type ingressStatus struct {
+	namespace, name string
+	// expiresAt is when the first certificate of the ingress expires, zero
+	// if none could be read.
+	expiresAt time.Time
+	// severity is the worst severity of its hosts.
+	severity severity
+	// checked is when the hosts were checked.
+	checked time.Time
+}
+
+func (n *annotationNotifier) notify(ctx context.Context, s *summary) error {
+	var failed int
+	var last error
+	for _, status := range ingressStatuses(s.results, n.policy, s.Time) {
+		if err := n.annotate(ctx, status); err != nil {
+			failed++
+			last = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("annotating %d ingresses: %v", failed, last)
+	}
+	return nil
+}
+
+// annotate patches the annotations of status onto its ingress. The expiry
+// annotation is removed if no certificate could be read.
+func (n *annotationNotifier) annotate(ctx context.Context, status ingressStatus) error {
+	annotations := map[string]interface{}{
+		statusAnnotation:    status.severity.String(),
+		expiresAtAnnotation: nil,
+	}
+	if !status.expiresAt.IsZero() {
+		annotations[expiresAtAnnotation] = status.expiresAt.UTC().Format(time.RFC3339)
+	}
+	if n.conditions {
+		condition, err := n.condition(ctx, status)
+		if err != nil {
+			return err
+		}
+		annotations[conditionAnnotation] = condition
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	return callAPI(ctx, func() error {
+		_, err := n.client.Ingresses(status.namespace).Patch(status.name, types.MergePatchType, patch)
+		return err
+	})
+}
+
+// ingressStatuses returns the status of every ingress results were taken
+// from, in the order of results.
+func ingressStatuses(results []result, p policy, now time.Time) []ingressStatus {
+	var statuses []ingressStatus
+	index := map[string]int{}
+	for _, r := range results {
+		if r.ref == nil || r.ref.Kind != ingressKind {
+			continue
+		}
+		key := r.ref.Namespace + "/" + r.ref.Name
+		i, ok := index[key]
+		if !ok {
+			i = len(statuses)
+			index[key] = i
+			statuses = append(statuses, ingressStatus{namespace: r.ref.Namespace, name: r.ref.Name, checked: now})
+		}
+		if sev := p.severity(r, now); sev > statuses[i].severity {
+			statuses[i].severity = sev
+		}
+		if r.certificate != nil && (statuses[i].expiresAt.IsZero() || r.certificate.NotAfter.Before(statuses[i].expiresAt)) {
+			statuses[i].expiresAt = r.certificate.NotAfter
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestAnnotationNotifier(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", Annotations: map[string]string{
+			portAnnotation:      "8443",
+			expiresAtAnnotation: "2019-01-01T00:00:00Z",
+		}}},
+		&v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "broken"}},
+	)
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	ingress := func(name string) *v1.ObjectReference {
+		return &v1.ObjectReference{Kind: ingressKind, Namespace: "shop", Name: name}
+	}
+	results := []result{
+		{target: target{host: "shop.example.com", ref: ingress("web")}, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}},
+		{target: target{host: "api.example.com", ref: ingress("web")}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 3)}},
+		{target: target{host: "broken.example.com", ref: ingress("broken")}, err: errors.New("connection refused")},
+		// Not an ingress, not annotated.
+		{target: target{host: "10.0.0.1:6443", kind: controlPlaneKind}, err: errors.New("connection refused")},
+	}
+	p := policy{days: 30}
+	n := &annotationNotifier{client: clientset.ExtensionsV1beta1(), policy: p}
+	if err := n.notify(context.Background(), newSummary(results, p, now)); err != nil {
+		t.Fatal(err)
+	}
+
+	ing, err := clientset.ExtensionsV1beta1().Ingresses("shop").Get("web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		portAnnotation:      "8443",
+		expiresAtAnnotation: "2019-10-17T00:00:00Z",
+		statusAnnotation:    "WARNING",
+	}
+	if !reflect.DeepEqual(ing.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, ing.Annotations)
+	}
+
+	// The fake clientset keeps the annotations a merge patch removes, look
+	// at the patch instead.
+	var patches []string
+	for _, action := range clientset.Actions() {
+		if patch, ok := action.(clienttesting.PatchAction); ok && patch.GetName() == "broken" {
+			patches = append(patches, string(patch.GetPatch()))
+		}
+	}
+	expectedPatch := `{"metadata":{"annotations":{"cert-check/expires-at":null,"cert-check/status":"ERROR"}}}`
+	if len(patches) != 1 || patches[0] != expectedPatch {
+		t.Errorf("expected patch %s, got %v", expectedPatch, patches)
+	}
+}
+
+func TestCheckerAnnotationsIgnoreStatus(t *testing.T) {
+	old := map[string]string{portAnnotation: "8443"}
+	new := map[string]string{portAnnotation: "8443", statusAnnotation: "OK", expiresAtAnnotation: "2019-10-17T00:00:00Z"}
+	if checkerAnnotationsChanged(old, new) {
+		t.Errorf("writing the status annotations must not change the targets")
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// annotationPrefix is the prefix of every annotation read by the checker.
+const annotationPrefix = "cert-check/"
+
+// portAnnotation lists the ports, separated by commas, the TLS hosts of an
+// object are checked on instead of the ones given by -port.
+const portAnnotation = annotationPrefix + "port"
+
+// warnBeforeAnnotation overrides the warning window given by -days, -months
+// and -years for the hosts of an object, or of every object of a namespace,
+// e.g. "14d".
+const warnBeforeAnnotation = annotationPrefix + "warn-before"
+
+// ignoreAnnotation, set to "true" on an object or a namespace, stops its
+// hosts from being checked.
+const ignoreAnnotation = annotationPrefix + "ignore"
+
+// enabledAnnotation, set to "true" on a LoadBalancer service, opts it in to
+// being checked with -resources=services.
+const enabledAnnotation = annotationPrefix + "enabled"
+
+// hostAnnotation is the name the certificate served by a LoadBalancer
+// service is requested and verified for, instead of the address of its load
+// balancer.
+const hostAnnotation = annotationPrefix + "host"
+
+// alpnAnnotation lists the ALPN protocols, separated by commas, offered when
+// checking the TLS hosts of an object instead of the ones given by -alpn,
+// e.g. "h2" for listeners that pick their certificate by protocol.
+const alpnAnnotation = annotationPrefix + "alpn"
+
+// protocolAnnotation is the protocol the TLS hosts of an object are asked to
+// start TLS in instead of the one given by -protocol, e.g. "smtp".
+const protocolAnnotation = annotationPrefix + "protocol"
+
+// clientCertSecretAnnotation names the kubernetes.io/tls Secret, in the
+// namespace of an ingress, whose certificate and key its TLS hosts are sent
+// when they ask for a client certificate, instead of the ones given by
+// -client-cert and -client-key.
+const clientCertSecretAnnotation = annotationPrefix + "client-cert-secret"
+
+// expiresAtAnnotation and statusAnnotation are written by -annotate: the
+// time the first certificate of an object expires, and the worst severity of
+// its hosts.
+const (
+	expiresAtAnnotation = annotationPrefix + "expires-at"
+	statusAnnotation    = annotationPrefix + "status"
+)
+
+// conditionAnnotation holds the CertExpiryHealthy condition written by
+// -operator, as JSON.
+const conditionAnnotation = annotationPrefix + "condition"
+
+// checkerAnnotations returns the annotations read by the checker. The ones it
+// writes itself are left out, so that writing them does not queue the object
+// again.
+func checkerAnnotations(annotations map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for k, v := range annotations {
+		if k == expiresAtAnnotation || k == statusAnnotation || k == conditionAnnotation {
+			continue
+		}
+		if strings.HasPrefix(k, annotationPrefix) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// checkerAnnotationsChanged reports whether the annotations read by the
+// checker differ between old and new.
+func checkerAnnotationsChanged(old, new map[string]string) bool {
+	return !reflect.DeepEqual(checkerAnnotations(old), checkerAnnotations(new))
+}
+
+// annotatedPorts returns the ports listed in the port annotation of the
+// object namespace/name, or ports if it has none or it is invalid.
+func annotatedPorts(namespace, name string, annotations map[string]string, ports []int) []int {
+	value, ok := annotations[portAnnotation]
+	if !ok {
+		return ports
+	}
+	annotated, err := parsePorts(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", portAnnotation)
+		return ports
+	}
+	return annotated
+}
+
+// annotatedWarnBefore returns the warning window in the warn-before
+// annotation of the object namespace/name, or 0 if it has none or it is
+// invalid.
+func annotatedWarnBefore(namespace, name string, annotations map[string]string) time.Duration {
+	value, ok := annotations[warnBeforeAnnotation]
+	if !ok {
+		return 0
+	}
+	d, err := parseDuration(value)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("warning window %q is not positive", value)
+	}
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", warnBeforeAnnotation)
+		return 0
+	}
+	return d
+}
+
+// annotatedALPN returns the ALPN protocols listed in the alpn annotation of
+// the object namespace/name, or nil if it has none or it is invalid.
+func annotatedALPN(namespace, name string, annotations map[string]string) []string {
+	value, ok := annotations[alpnAnnotation]
+	if !ok {
+		return nil
+	}
+	protos, err := parseALPN(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", alpnAnnotation)
+		return nil
+	}
+	return protos
+}
+
+// annotatedProtocol returns the protocol in the protocol annotation of the
+// object namespace/name, or "" if it has none or it is invalid.
+func annotatedProtocol(namespace, name string, annotations map[string]string) string {
+	value, ok := annotations[protocolAnnotation]
+	if !ok {
+		return ""
+	}
+	protocol, err := parseProtocol(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", protocolAnnotation)
+		return ""
+	}
+	return protocol
+}
+
+// annotatedClientCertSecret returns the Secret named in the
+// client-cert-secret annotation of the object namespace/name, or "" if it has
+// none or it is invalid.
+func annotatedClientCertSecret(namespace, name string, annotations map[string]string) string {
+	value, ok := annotations[clientCertSecretAnnotation]
+	if !ok {
+		return ""
+	}
+	if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+		klog.ErrorS(fmt.Errorf("invalid Secret name %q: %s", value, strings.Join(errs, ", ")), "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", clientCertSecretAnnotation)
+		return ""
+	}
+	return value
+}
+
+// parsePorts parses a comma separated list of ports.
+func parsePorts(s string) ([]int, error) {
+	var ports []int
+	for _, p := range strings.Split(s, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
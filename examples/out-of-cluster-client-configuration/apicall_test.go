@@ -0,0 +1,44 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallAPI(t *testing.T) {
+	refused := errors.New("connection refused")
+	if err := callAPI(context.Background(), func() error { return refused }); err != refused {
+		t.Errorf("expected the error of the request, got %v", err)
+	}
+
+	// A request to an API server that does not answer is abandoned.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	hang := make(chan struct{})
+	defer close(hang)
+	if err := callAPI(ctx, func() error { <-hang; return nil }); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	called := false
+	if err := callAPI(ctx, func() error { called = true; return nil }); err == nil || called {
+		t.Errorf("expected no request once ctx is done, got %v", err)
+	}
+}
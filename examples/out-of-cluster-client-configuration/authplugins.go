@@ -0,0 +1,27 @@
+//go:build !noauthplugins
+// +build !noauthplugins
+
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The auth providers of kubeconfig users (gcp, azure, oidc and openstack),
+// so that the kubeconfigs of cloud-managed clusters load as they are. Exec
+// credential plugins, such as aws-iam-authenticator or gke-gcloud-auth-plugin,
+// need no import. Build with -tags noauthplugins to leave the providers and
+// their SDKs out.
+import _ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -0,0 +1,38 @@
+//go:build !noauthplugins
+// +build !noauthplugins
+
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestAuthProviders(t *testing.T) {
+	for _, name := range []string{"gcp", "azure", "oidc", "openstack"} {
+		// Registering a provider again fails.
+		err := rest.RegisterAuthProviderPlugin(name, func(string, map[string]string, rest.AuthProviderConfigPersister) (rest.AuthProvider, error) {
+			return nil, nil
+		})
+		if err == nil {
+			t.Errorf("expected the %s auth provider to be registered", name)
+		}
+	}
+}
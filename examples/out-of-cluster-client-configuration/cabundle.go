@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+const (
+	validatingWebhookKind = "ValidatingWebhookConfiguration"
+	mutatingWebhookKind   = "MutatingWebhookConfiguration"
+	apiServiceKind        = "APIService"
+)
+
+// admissionGroup and apiRegistrationGroup are the API groups of webhook
+// configurations and API services.
+const (
+	admissionGroup       = "admissionregistration.k8s.io"
+	apiRegistrationGroup = "apiregistration.k8s.io"
+)
+
+// caBundleVersions are the versions of the admission registration and API
+// registration APIs the checker reads, most preferred first.
+var caBundleVersions = []string{"v1", "v1beta1"}
+
+// webhookSource returns a source checking the CA bundle of every webhook of
+// the webhook configurations of kind served by informer.
+func webhookSource(kind string, informer cache.SharedIndexInformer) *source {
+	return &source{
+		kind:     kind,
+		informer: informer,
+		targets: func(obj interface{}) []target {
+			return webhookTargets(kind, obj.(*unstructured.Unstructured))
+		},
+		changed: func(old, new interface{}) bool {
+			oldWebhooks, _, _ := unstructured.NestedFieldNoCopy(old.(*unstructured.Unstructured).Object, "webhooks")
+			newWebhooks, _, _ := unstructured.NestedFieldNoCopy(new.(*unstructured.Unstructured).Object, "webhooks")
+			return !reflect.DeepEqual(oldWebhooks, newWebhooks)
+		},
+	}
+}
+
+// apiServiceSource returns a source checking the CA bundle of every API
+// service served by informer.
+func apiServiceSource(informer cache.SharedIndexInformer) *source {
+	return &source{
+		kind:     apiServiceKind,
+		informer: informer,
+		targets: func(obj interface{}) []target {
+			return apiServiceTargets(obj.(*unstructured.Unstructured))
+		},
+		changed: func(old, new interface{}) bool {
+			oldBundle, _, _ := unstructured.NestedString(old.(*unstructured.Unstructured).Object, "spec", "caBundle")
+			newBundle, _, _ := unstructured.NestedString(new.(*unstructured.Unstructured).Object, "spec", "caBundle")
+			return oldBundle != newBundle
+		},
+	}
+}
+
+// webhookTargets returns a target for the CA bundle of every webhook of the
+// webhook configuration config. Webhooks without a CA bundle are verified
+// against the system certificate authorities of the API server and are left
+// out.
+func webhookTargets(kind string, config *unstructured.Unstructured) []target {
+	webhooks, _, err := unstructured.NestedSlice(config.Object, "webhooks")
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid webhooks", "kind", kind, "object", klog.KObj(config))
+		return nil
+	}
+
+	var targets []target
+	for _, item := range webhooks {
+		webhook, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(webhook, "name")
+		bundle, _, _ := unstructured.NestedString(webhook, "clientConfig", "caBundle")
+		if t, ok := caBundleTarget(kind, config, name, bundle); ok {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// apiServiceTargets returns a target for the CA bundle of the API service
+// svc. Local API services, served by the API server itself, and the ones
+// skipping TLS verification have none.
+func apiServiceTargets(svc *unstructured.Unstructured) []target {
+	bundle, _, _ := unstructured.NestedString(svc.Object, "spec", "caBundle")
+	if t, ok := caBundleTarget(apiServiceKind, svc, svc.GetName(), bundle); ok {
+		return []target{t}
+	}
+	return nil
+}
+
+// caBundleTarget returns the target of the base64 encoded CA bundle of the
+// object obj of kind, reported as name.
+func caBundleTarget(kind string, obj *unstructured.Unstructured, name, bundle string) (target, bool) {
+	if bundle == "" {
+		return target{}, false
+	}
+	data, err := base64.StdEncoding.DecodeString(bundle)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid caBundle", "kind", kind, "object", klog.KObj(obj), "name", name)
+		return target{}, false
+	}
+	return target{
+		namespace:      obj.GetNamespace(),
+		kind:           kind,
+		object:         obj.GetName(),
+		host:           name,
+		certificatePEM: data,
+		caBundle:       true,
+		ref:            objectReference(obj.GetAPIVersion(), obj.GetKind(), obj),
+	}, true
+}
+
+// caBundleChecker returns a checker that reports on the CA bundle of targets
+// that have one, and checks the other targets with next.
+func caBundleChecker(next checker) checker {
+	return func(ctx context.Context, t target) result {
+		if !t.caBundle {
+			return next(ctx, t)
+		}
+		c, err := bundleCertificate(t)
+		return result{target: t, certificate: c, err: err}
+	}
+}
+
+// bundleCertificate returns the certificate expiring first in the CA bundle of
+// t: a bundle is only as good as the authority the server certificate is
+// actually issued by, which the bundle does not tell.
+func bundleCertificate(t target) (*certificate, error) {
+	bundle, err := certutil.ParseCertsPEM(t.certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: caBundle of %s: %v", t.kind, t.object, t.host, err)
+	}
+	var first *x509.Certificate
+	for _, crt := range bundle {
+		if first == nil || crt.NotAfter.Before(first.NotAfter) {
+			first = crt
+		}
+	}
+	return newCertificate(first), nil
+}
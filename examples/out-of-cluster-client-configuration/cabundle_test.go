@@ -0,0 +1,74 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWebhookTargets(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Webhook CA", true, nil, nil)
+	// Issued by ca, only to get a second certificate expiring at another
+	// time in the bundle.
+	other, _ := newTestCertificate(t, "Other CA", true, ca, caKey)
+	bundle := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.Raw})...,
+	)
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "policy"},
+		"webhooks": []interface{}{
+			map[string]interface{}{
+				"name":         "validate.policy.example.com",
+				"clientConfig": map[string]interface{}{"caBundle": base64.StdEncoding.EncodeToString(bundle)},
+			},
+			// Verified against the system certificate authorities.
+			map[string]interface{}{
+				"name":         "public.policy.example.com",
+				"clientConfig": map[string]interface{}{"url": "https://policy.example.com"},
+			},
+		},
+	}}
+
+	targets := webhookTargets(validatingWebhookKind, config)
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %+v", targets)
+	}
+	if targets[0].name() != "validate.policy.example.com" || targets[0].object != "policy" || !targets[0].caBundle {
+		t.Errorf("unexpected target %+v", targets[0])
+	}
+
+	dial := func(ctx context.Context, t target) result {
+		return result{target: t}
+	}
+	r := caBundleChecker(dial)(context.Background(), targets[0])
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	first := ca
+	if other.NotAfter.Before(ca.NotAfter) {
+		first = other
+	}
+	if r.certificate.CommonName != first.Subject.CommonName || !r.certificate.NotAfter.Equal(first.NotAfter) {
+		t.Errorf("expected the certificate expiring first, got %+v", r.certificate)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// certificate is the part of an x509 certificate that gets reported.
+type certificate struct {
+	CommonName       string     `json:"cn"`
+	NotBefore        time.Time  `json:"issued"`
+	NotAfter         time.Time  `json:"expires"`
+	IssuerCommonName string     `json:"issuer"`
+	Algorithm        string     `json:"algorithm"`
+	PublicKey        publicKey  `json:"publicKey"`
+	Sunset           *time.Time `json:"sunset,omitempty"`
+	SerialNumber     string     `json:"serial"`
+	Fingerprint      string     `json:"sha256"`
+	DNSNames         []string   `json:"dnsNames,omitempty"`
+	IPAddresses      []string   `json:"ipAddresses,omitempty"`
+	// Coverage is how the host of a served certificate is covered by it:
+	// exact, wildcard, commonName or none.
+	Coverage string `json:"coverage,omitempty"`
+	// Revocation is only set for served certificates whose revocation
+	// status was looked up.
+	Revocation *revocation `json:"revocation,omitempty"`
+	// Transparency is only set for served certificates when their
+	// Certificate Transparency status was checked.
+	Transparency *transparency `json:"transparency,omitempty"`
+	// VerifyError is why a served certificate failed verification, when
+	// that is tolerated.
+	VerifyError string `json:"verifyError,omitempty"`
+	// Protocol is only set for served certificates whose handshake
+	// completed.
+	Protocol *protocol `json:"protocol,omitempty"`
+	// Chain are the other certificates served along with a served
+	// certificate, in the order they were sent.
+	Chain []chainCertificate `json:"chain,omitempty"`
+	// VerifiedChain are the certificates a served certificate was verified
+	// through, from its issuer up to the root; its length is the depth of
+	// the chain.
+	VerifiedChain []chainCertificate `json:"verifiedChain,omitempty"`
+	// Roots are the roots of every chain a cross-signed certificate was
+	// verified through.
+	Roots []string `json:"roots,omitempty"`
+	// IncompleteChain is the URL the intermediate missing from the served
+	// chain was fetched from, when a served certificate only verifies
+	// along with it.
+	IncompleteChain string `json:"incompleteChain,omitempty"`
+	// served is the chain a served certificate was sent along with, leaf
+	// first.
+	served []*x509.Certificate
+}
+
+// chainCertificate is an intermediate or root certificate served along with
+// a leaf.
+type chainCertificate struct {
+	CommonName       string    `json:"cn"`
+	NotAfter         time.Time `json:"expires"`
+	IssuerCommonName string    `json:"issuer"`
+	PublicKey        publicKey `json:"publicKey"`
+	// ExpiresBeforeLeaf is set on the certificates of a verified chain
+	// that expire before its leaf.
+	ExpiresBeforeLeaf bool `json:"expiresBeforeLeaf,omitempty"`
+}
+
+// revocation is the revocation status of a certificate.
+type revocation struct {
+	// Status is good, revoked or unknown.
+	Status string `json:"status"`
+	// Source is where the status comes from: stapled, or the URL of the
+	// OCSP responder.
+	Source    string     `json:"source"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	// Error is set when the status could not be determined.
+	Error string `json:"error,omitempty"`
+}
+
+// revoked reports whether the certificate is known to be revoked.
+func (c *certificate) revoked() bool {
+	return c.Revocation != nil && c.Revocation.Status == revocationRevoked
+}
+
+func newCertificate(crt *x509.Certificate) *certificate {
+	c := &certificate{
+		CommonName:       crt.Subject.CommonName,
+		NotBefore:        crt.NotBefore,
+		NotAfter:         crt.NotAfter,
+		IssuerCommonName: crt.Issuer.CommonName,
+		Algorithm:        crt.SignatureAlgorithm.String(),
+		PublicKey:        newPublicKey(crt),
+		SerialNumber:     crt.SerialNumber.Text(16),
+		Fingerprint:      fingerprint(crt),
+		DNSNames:         crt.DNSNames,
+	}
+	for _, ip := range crt.IPAddresses {
+		c.IPAddresses = append(c.IPAddresses, ip.String())
+	}
+	if alg, ok := sunsetSignatureAlgorithms[crt.SignatureAlgorithm]; ok {
+		c.Sunset = &alg.date
+	}
+	return c
+}
+
+// chainOf returns the chain certificates of certs.
+func chainOf(certs []*x509.Certificate) []chainCertificate {
+	var chain []chainCertificate
+	for _, crt := range certs {
+		chain = append(chain, chainCertificate{
+			CommonName:       crt.Subject.CommonName,
+			NotAfter:         crt.NotAfter,
+			IssuerCommonName: crt.Issuer.CommonName,
+			PublicKey:        newPublicKey(crt),
+		})
+	}
+	return chain
+}
+
+// fingerprint returns the hex encoded SHA-256 digest of the DER encoding of
+// crt.
+func fingerprint(crt *x509.Certificate) string {
+	sum := sha256.Sum256(crt.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// leafCertificate returns the first certificate of chain that is not a CA,
+// falling back to the first certificate when all of them are.
+func leafCertificate(chain []*x509.Certificate) *x509.Certificate {
+	for _, crt := range chain {
+		if !crt.IsCA {
+			return crt
+		}
+	}
+	return chain[0]
+}
+
+func (c *certificate) Jsonify() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
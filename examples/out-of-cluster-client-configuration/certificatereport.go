@@ -0,0 +1,267 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// certificateReportKind is the kind of the custom resource scan results are
+// written to, defined by manifests/certificatereport-crd.yaml.
+const certificateReportKind = "CertificateReport"
+
+// certificateReportName is the name of the report of every namespace.
+const certificateReportName = "cert-check"
+
+var certificateReportsResource = schema.GroupVersionResource{Group: "certcheck.pathcl.io", Version: "v1alpha1", Resource: "certificatereports"}
+
+// reportNotifier writes the results of every scan to a CertificateReport per
+// namespace, for other controllers, dashboards and kubectl to read. It is a
+// resolving notifier: namespaces whose hosts are all fine get a report too.
+//
+// A report lists the hosts of every object checked in the namespace. A
+// one-shot scan replaces every entry of the namespaces it checked. Watch
+// mode checks a single object at a time: its entries replace the ones it had
+// and the entries of the other objects are kept, until they are deleted.
+// Hosts of cluster scoped objects are not reported.
+//
+// Entries also keep the history of the certificate of their host across
+// scans: when it was first seen, when it was last renewed and how many
+// times it was, so that renewal automation that stopped working can be told
+// apart from certificates that are not renewed automatically.
+type reportNotifier struct {
+	client dynamic.Interface
+	policy policy
+	// renewalOverdue is how long a host may serve the same certificate
+	// before its entry is marked renewalOverdue, 0 never marks them.
+	renewalOverdue time.Duration
+}
+
+func (n *reportNotifier) resolvesFindings() {}
+
+func (n *reportNotifier) notify(ctx context.Context, s *summary) error {
+	byNamespace := map[string][]result{}
+	checked := map[string]bool{}
+	for _, r := range s.results {
+		if r.namespace != "" {
+			byNamespace[r.namespace] = append(byNamespace[r.namespace], r)
+			checked[r.kind+"/"+r.object] = true
+		}
+	}
+	// The object of an interrupted scan may not have been checked at all,
+	// its entries are kept then.
+	if o := s.object; o != nil && o.namespace != "" && !s.Interrupted {
+		if _, ok := byNamespace[o.namespace]; !ok {
+			byNamespace[o.namespace] = nil
+		}
+		checked[o.kind+"/"+o.object] = true
+	}
+	replaces := func(kind, object string) bool {
+		return checked[kind+"/"+object]
+	}
+	if s.scope == scopeScan && !s.Interrupted {
+		replaces = func(kind, object string) bool { return true }
+	}
+	var failed int
+	var last error
+	for namespace, results := range byNamespace {
+		if err := n.write(ctx, namespace, results, replaces, s.Time); err != nil {
+			failed++
+			last = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("writing %d certificate reports: %v", failed, last)
+	}
+	return nil
+}
+
+// write merges results into the report of namespace, creating it if needed.
+// The entries of the objects replaces reports true for are dropped.
+func (n *reportNotifier) write(ctx context.Context, namespace string, results []result, replaces func(kind, object string) bool, now time.Time) error {
+	reports := n.client.Resource(certificateReportsResource).Namespace(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return callAPI(ctx, func() error {
+			report, err := reports.Get(certificateReportName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				report = &unstructured.Unstructured{Object: map[string]interface{}{}}
+				report.SetAPIVersion(certificateReportsResource.GroupVersion().String())
+				report.SetKind(certificateReportKind)
+				report.SetNamespace(namespace)
+				report.SetName(certificateReportName)
+				setReportResults(report, results, replaces, n.policy, n.renewalOverdue, now)
+				_, err = reports.Create(report, metav1.CreateOptions{})
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			setReportResults(report, results, replaces, n.policy, n.renewalOverdue, now)
+			_, err = reports.Update(report, metav1.UpdateOptions{})
+			return err
+		})
+	})
+}
+
+// setReportResults replaces the entries of the objects replaces reports true
+// for in report with results, carrying the history of their hosts over, and
+// updates its summary.
+func setReportResults(report *unstructured.Unstructured, results []result, replaces func(kind, object string) bool, p policy, renewalOverdue time.Duration, now time.Time) {
+	var entries []map[string]interface{}
+	replaced := map[string]map[string]interface{}{}
+	previous, _, _ := unstructured.NestedSlice(report.Object, "results")
+	for _, e := range previous {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(entry, "kind")
+		object, _, _ := unstructured.NestedString(entry, "object")
+		host, _, _ := unstructured.NestedString(entry, "host")
+		if replaces(kind, object) {
+			replaced[kind+"/"+object+"/"+host] = entry
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	for _, r := range results {
+		entry := reportEntry(r, p, now)
+		setHistory(entry, replaced[r.kind+"/"+r.object+"/"+r.name()], r.certificate, now)
+		if renewalOverdue > 0 && renewedBefore(entry, now.Add(-renewalOverdue)) {
+			entry["renewalOverdue"] = true
+			klog.InfoS("Certificate not renewed", "namespace", r.namespace, "kind", r.kind, "object", r.object, "host", r.name(), "lastRenewed", entry["lastRenewed"])
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		for _, field := range []string{"kind", "object", "host"} {
+			a, b := entries[i][field].(string), entries[j][field].(string)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+
+	summary := map[string]interface{}{
+		"checked":        now.UTC().Format(time.RFC3339),
+		"hosts":          int64(len(entries)),
+		"renewalOverdue": int64(0),
+	}
+	worst := severityOK
+	for sev := severityOK; sev <= severityError; sev++ {
+		summary[strings.ToLower(sev.String())] = int64(0)
+	}
+	list := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		for sev := severityOK; sev <= severityError; sev++ {
+			if entry["severity"] == sev.String() {
+				summary[strings.ToLower(sev.String())] = summary[strings.ToLower(sev.String())].(int64) + 1
+				if sev > worst {
+					worst = sev
+				}
+			}
+		}
+		if entry["renewalOverdue"] == true {
+			summary["renewalOverdue"] = summary["renewalOverdue"].(int64) + 1
+		}
+		// RFC 3339 timestamps in UTC sort chronologically.
+		if notAfter, ok := entry["notAfter"].(string); ok {
+			if first, ok := summary["nextExpiry"].(string); !ok || notAfter < first {
+				summary["nextExpiry"] = notAfter
+			}
+		}
+		list = append(list, entry)
+	}
+	summary["worst"] = worst.String()
+	report.Object["results"] = list
+	report.Object["summary"] = summary
+}
+
+// reportEntry returns the entry of r in a report.
+func reportEntry(r result, p policy, now time.Time) map[string]interface{} {
+	entry := map[string]interface{}{
+		"kind":     r.kind,
+		"object":   r.object,
+		"host":     r.name(),
+		"severity": p.severity(r, now).String(),
+	}
+	if c := r.certificate; c != nil {
+		entry["subject"] = c.CommonName
+		entry["issuer"] = c.IssuerCommonName
+		entry["notAfter"] = c.NotAfter.UTC().Format(time.RFC3339)
+		entry["daysRemaining"] = int64(c.NotAfter.Sub(now).Hours() / 24)
+	}
+	if r.err != nil {
+		entry["error"] = r.err.Error()
+	}
+	return entry
+}
+
+// setHistory sets the history of the host of entry from the one of its
+// previous entry, if any, and its certificate c. A host is renewed whenever it
+// serves a certificate with another fingerprint; the certificate it served
+// when first seen is taken to have been renewed when it was issued. Hosts
+// that could not be checked keep their history.
+func setHistory(entry, previous map[string]interface{}, c *certificate, now time.Time) {
+	for _, field := range []string{"firstSeen", "lastRenewed", "renewals", "sha256"} {
+		if value, ok := previous[field]; ok {
+			entry[field] = value
+		}
+	}
+	if _, ok := entry["firstSeen"]; !ok {
+		entry["firstSeen"] = now.UTC().Format(time.RFC3339)
+	}
+	if c == nil {
+		return
+	}
+	renewals, _, _ := unstructured.NestedInt64(entry, "renewals")
+	if fingerprint, ok := entry["sha256"].(string); ok && fingerprint != c.Fingerprint {
+		renewals++
+	}
+	if entry["sha256"] != c.Fingerprint {
+		issued := c.NotBefore
+		if issued.IsZero() || issued.After(now) {
+			issued = now
+		}
+		entry["lastRenewed"] = issued.UTC().Format(time.RFC3339)
+	}
+	entry["renewals"] = renewals
+	entry["sha256"] = c.Fingerprint
+}
+
+// renewedBefore reports whether the host of entry was last renewed before t.
+func renewedBefore(entry map[string]interface{}, t time.Time) bool {
+	lastRenewed, ok := entry["lastRenewed"].(string)
+	if !ok {
+		return false
+	}
+	renewed, err := time.Parse(time.RFC3339, lastRenewed)
+	return err == nil && renewed.Before(t)
+}
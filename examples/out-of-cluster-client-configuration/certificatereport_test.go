@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestReportNotifier(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	n := &reportNotifier{client: client, policy: policy{days: 30}}
+	web := target{namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}
+	api := target{namespace: "shop", kind: ingressKind, object: "api", host: "api.example.com"}
+	node := target{kind: nodeKind, object: "node-1", host: "node-1"}
+
+	results := []result{
+		{target: web, certificate: &certificate{CommonName: "shop.example.com", NotAfter: now.AddDate(0, 0, 10)}},
+		{target: api, err: errors.New("connection refused")},
+		{target: node, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}},
+	}
+	if err := n.notify(context.Background(), newSummary(results, n.policy, now)); err != nil {
+		t.Fatal(err)
+	}
+	report := getReport(t, client, "shop")
+	if hosts, _, _ := unstructured.NestedInt64(report.Object, "summary", "hosts"); hosts != 2 {
+		t.Errorf("expected 2 hosts, got %d", hosts)
+	}
+	if worst, _, _ := unstructured.NestedString(report.Object, "summary", "worst"); worst != "ERROR" {
+		t.Errorf("expected the worst severity to be ERROR, got %s", worst)
+	}
+	if next, _, _ := unstructured.NestedString(report.Object, "summary", "nextExpiry"); next != "2019-10-24T00:00:00Z" {
+		t.Errorf("expected the next expiry of web, got %s", next)
+	}
+
+	// A scan of api alone, as in watch mode, keeps the entry of web.
+	results = []result{{target: api, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}}}
+	sum := newSummary(results, n.policy, now)
+	sum.scope, sum.object = scopeObject, &objectKey{kind: ingressKind, namespace: "shop", object: "api"}
+	if err := n.notify(context.Background(), sum); err != nil {
+		t.Fatal(err)
+	}
+	report = getReport(t, client, "shop")
+	entries, _, _ := unstructured.NestedSlice(report.Object, "results")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries)
+	}
+	for i, expected := range []struct{ object, severity string }{{"api", "OK"}, {"web", "WARNING"}} {
+		entry := entries[i].(map[string]interface{})
+		if entry["object"] != expected.object || entry["severity"] != expected.severity {
+			t.Errorf("expected %s to be %s, got %v", expected.object, expected.severity, entry)
+		}
+	}
+	if errs, _, _ := unstructured.NestedInt64(report.Object, "summary", "error"); errs != 0 {
+		t.Errorf("expected no more errors, got %d", errs)
+	}
+
+	// Deleting web in watch mode drops its entry.
+	sum = newSummary(nil, n.policy, now)
+	sum.scope, sum.object = scopeObject, &objectKey{kind: ingressKind, namespace: "shop", object: "web"}
+	if err := n.notify(context.Background(), sum); err != nil {
+		t.Fatal(err)
+	}
+	entries, _, _ = unstructured.NestedSlice(getReport(t, client, "shop").Object, "results")
+	if len(entries) != 1 || entries[0].(map[string]interface{})["object"] != "api" {
+		t.Errorf("expected the entry of web to be dropped, got %v", entries)
+	}
+
+	// A one-shot scan replaces every entry of the namespace.
+	results = []result{{target: web, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}}}
+	if err := n.notify(context.Background(), newSummary(results, n.policy, now)); err != nil {
+		t.Fatal(err)
+	}
+	report = getReport(t, client, "shop")
+	entries, _, _ = unstructured.NestedSlice(report.Object, "results")
+	if len(entries) != 1 || entries[0].(map[string]interface{})["object"] != "web" {
+		t.Errorf("expected only the entry of web, got %v", entries)
+	}
+	if hosts, _, _ := unstructured.NestedInt64(report.Object, "summary", "hosts"); hosts != 1 {
+		t.Errorf("expected 1 host, got %d", hosts)
+	}
+}
+
+func TestReportHistory(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	n := &reportNotifier{client: client, policy: policy{days: 30}, renewalOverdue: 90 * 24 * time.Hour}
+	web := target{namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}
+	issued := now.AddDate(0, 0, -60)
+	old := &certificate{Fingerprint: "old", NotBefore: issued, NotAfter: now.AddDate(0, 0, 30)}
+
+	scan := func(now time.Time, r result) map[string]interface{} {
+		t.Helper()
+		if err := n.notify(context.Background(), newSummary([]result{r}, n.policy, now)); err != nil {
+			t.Fatal(err)
+		}
+		entries, _, _ := unstructured.NestedSlice(getReport(t, client, "shop").Object, "results")
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 entry, got %v", entries)
+		}
+		return entries[0].(map[string]interface{})
+	}
+
+	entry := scan(now, result{target: web, certificate: old})
+	if entry["firstSeen"] != "2019-10-14T00:00:00Z" || entry["lastRenewed"] != "2019-08-15T00:00:00Z" || entry["renewals"] != int64(0) {
+		t.Errorf("expected the certificate to have been renewed when issued, got %v", entry)
+	}
+
+	// The same certificate 40 days later is overdue, failing to connect
+	// keeps the history.
+	later := now.AddDate(0, 0, 40)
+	entry = scan(later, result{target: web, err: errors.New("connection refused")})
+	if entry["firstSeen"] != "2019-10-14T00:00:00Z" || entry["sha256"] != "old" {
+		t.Errorf("expected the history to be kept, got %v", entry)
+	}
+	entry = scan(later, result{target: web, certificate: old})
+	if entry["renewalOverdue"] != true {
+		t.Errorf("expected the renewal to be overdue, got %v", entry)
+	}
+	if overdue, _, _ := unstructured.NestedInt64(getReport(t, client, "shop").Object, "summary", "renewalOverdue"); overdue != 1 {
+		t.Errorf("expected 1 overdue renewal in the summary, got %d", overdue)
+	}
+
+	renewed := &certificate{Fingerprint: "new", NotBefore: later.Add(-time.Hour), NotAfter: later.AddDate(0, 0, 90)}
+	entry = scan(later, result{target: web, certificate: renewed})
+	if entry["lastRenewed"] != "2019-11-22T23:00:00Z" || entry["renewals"] != int64(1) || entry["renewalOverdue"] != nil {
+		t.Errorf("expected the certificate to have been renewed, got %v", entry)
+	}
+}
+
+func getReport(t *testing.T, client *dynamicfake.FakeDynamicClient, namespace string) *unstructured.Unstructured {
+	t.Helper()
+	report, err := client.Resource(certificateReportsResource).Namespace(namespace).Get(certificateReportName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
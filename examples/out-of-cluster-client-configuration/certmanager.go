@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// certManagerGroup is the API group of cert-manager.
+const certManagerGroup = "cert-manager.io"
+
+// certManagerVersions are the versions of the cert-manager API the checker
+// reads, most preferred first.
+var certManagerVersions = []string{"v1", "v1beta1", "v1alpha3", "v1alpha2"}
+
+// secretIndex indexes cert-manager certificates by the namespace/name keys of
+// the Secrets they issue into.
+const secretIndex = "secret"
+
+// certManagerStatus is the status of the cert-manager Certificate issuing
+// into the Secret of a target.
+type certManagerStatus struct {
+	// Certificate is the namespace/name of the Certificate.
+	Certificate string `json:"certificate"`
+	Ready       bool   `json:"ready"`
+	// Reason and Message explain the Ready condition.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// RenewalTime is when cert-manager will renew the certificate, if it
+	// scheduled a renewal.
+	RenewalTime *time.Time `json:"renewalTime,omitempty"`
+}
+
+// String describes s in report lines.
+func (s *certManagerStatus) String() string {
+	status := "not ready"
+	if s.Ready {
+		status = "ready"
+	}
+	if s.Reason != "" {
+		status += " (" + s.Reason + ")"
+	}
+	if s.RenewalTime != nil {
+		status += ", renewal scheduled " + s.RenewalTime.Format(time.RFC3339)
+	} else {
+		status += ", no renewal scheduled"
+	}
+	return fmt.Sprintf("cert-manager %s %s", s.Certificate, status)
+}
+
+// certificateIndexers index cert-manager certificates by the Secret they
+// issue into.
+var certificateIndexers = cache.Indexers{secretIndex: func(obj interface{}) ([]string, error) {
+	crt := obj.(*unstructured.Unstructured)
+	name, _, _ := unstructured.NestedString(crt.Object, "spec", "secretName")
+	if name == "" {
+		return nil, nil
+	}
+	return []string{crt.GetNamespace() + "/" + name}, nil
+}}
+
+// certManagerChecker returns a checker that checks targets with next and
+// adds the status of the cert-manager Certificate issuing into their Secret,
+// if any, looked up in certificates, indexed by certificateIndexers.
+func certManagerChecker(next checker, certificates cache.Indexer) checker {
+	return func(ctx context.Context, t target) result {
+		r := next(ctx, t)
+		if t.secretName == "" {
+			return r
+		}
+		namespace, name := t.secretRef()
+		crts, err := certificates.ByIndex(secretIndex, namespace+"/"+name)
+		if err != nil || len(crts) == 0 {
+			return r
+		}
+		r.certManager = newCertManagerStatus(crts[0].(*unstructured.Unstructured))
+		return r
+	}
+}
+
+func newCertManagerStatus(crt *unstructured.Unstructured) *certManagerStatus {
+	s := &certManagerStatus{Certificate: crt.GetNamespace() + "/" + crt.GetName()}
+	conditions, _, _ := unstructured.NestedSlice(crt.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if conditionType, _, _ := unstructured.NestedString(condition, "type"); conditionType != "Ready" {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(condition, "status")
+		s.Ready = status == "True"
+		s.Reason, _, _ = unstructured.NestedString(condition, "reason")
+		s.Message, _, _ = unstructured.NestedString(condition, "message")
+	}
+	if renewal, ok, _ := unstructured.NestedString(crt.Object, "status", "renewalTime"); ok {
+		if t, err := time.Parse(time.RFC3339, renewal); err == nil {
+			s.RenewalTime = &t
+		}
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCertManagerChecker(t *testing.T) {
+	certificates := cache.NewIndexer(cache.MetaNamespaceKeyFunc, certificateIndexers)
+	certificates.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "shop", "name": "shop"},
+		"spec":     map[string]interface{}{"secretName": "shop-tls"},
+		"status": map[string]interface{}{
+			"renewalTime": "2020-01-01T00:00:00Z",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "False", "reason": "Issuing"},
+			},
+		},
+	}})
+	check := certManagerChecker(func(ctx context.Context, t target) result {
+		return result{target: t}
+	}, certificates)
+
+	r := check(context.Background(), target{namespace: "shop", secretName: "shop-tls"})
+	want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if s := r.certManager; s == nil || s.Certificate != "shop/shop" || s.Ready || s.Reason != "Issuing" || s.RenewalTime == nil || !s.RenewalTime.Equal(want) {
+		t.Errorf("unexpected cert-manager status %+v", r.certManager)
+	}
+
+	for _, target := range []target{
+		{namespace: "shop", secretName: "other-tls"},
+		// The Secret of an Istio gateway is the one of another namespace.
+		{namespace: "istio-system", secretName: "shop-tls"},
+	} {
+		if r := check(context.Background(), target); r.certManager != nil {
+			t.Errorf("%+v: expected no cert-manager status, got %+v", target, r.certManager)
+		}
+	}
+}
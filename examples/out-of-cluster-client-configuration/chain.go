@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxIntermediateSize bounds how much of a certificate fetched from the
+// issuer URL of another one is read.
+const maxIntermediateSize = 1 << 20
+
+// maxFetchedIntermediates is how many certificates are fetched at most to
+// complete a chain.
+const maxFetchedIntermediates = 3
+
+// analyzeChain reports the first of chains, the ones a leaf certificate was
+// verified through, on c: its certificates up to the root, the ones expiring
+// before the leaf, and the roots of every chain when the leaf is
+// cross-signed.
+func analyzeChain(c *certificate, chains [][]*x509.Certificate) {
+	if len(chains) == 0 {
+		return
+	}
+	c.VerifiedChain = chainOf(chains[0][1:])
+	for i := range c.VerifiedChain {
+		if c.VerifiedChain[i].NotAfter.Before(c.NotAfter) {
+			c.VerifiedChain[i].ExpiresBeforeLeaf = true
+		}
+	}
+	if len(chains) > 1 {
+		seen := map[string]bool{}
+		for _, chain := range chains {
+			root := chain[len(chain)-1].Subject.CommonName
+			if !seen[root] {
+				seen[root] = true
+				c.Roots = append(c.Roots, root)
+			}
+		}
+	}
+}
+
+// chainExpiresFirst reports whether a certificate of the verified chain of c
+// expires before c itself, which breaks clients before c needs renewing.
+func (c *certificate) chainExpiresFirst() bool {
+	for _, crt := range c.VerifiedChain {
+		if crt.ExpiresBeforeLeaf {
+			return true
+		}
+	}
+	return false
+}
+
+// completeChain fetches the intermediates missing from certs, the
+// certificates served by host, from the issuer URLs (AIA) of the last of
+// them, and verifies certs again along with them. It returns the chains they
+// were verified through and the first URL an intermediate was fetched from.
+func (d *dialer) completeChain(ctx context.Context, host string, certs []*x509.Certificate) ([][]*x509.Certificate, string, error) {
+	var first string
+	for i := 0; i < maxFetchedIntermediates; i++ {
+		last := certs[len(certs)-1]
+		if len(last.IssuingCertificateURL) == 0 {
+			return nil, "", fmt.Errorf("no issuer URL to fetch the intermediate issuing %q from", last.Subject.CommonName)
+		}
+		url := last.IssuingCertificateURL[0]
+		crt, err := d.fetchCertificate(ctx, url)
+		if err != nil {
+			return nil, "", fmt.Errorf("fetching the intermediate from %s: %v", url, err)
+		}
+		if first == "" {
+			first = url
+		}
+		certs = append(certs, crt)
+		chains, err := d.verify(host, certs)
+		if err == nil {
+			return chains, first, nil
+		}
+		if _, ok := err.(x509.UnknownAuthorityError); !ok {
+			return nil, "", err
+		}
+	}
+	return nil, "", fmt.Errorf("the chain is still incomplete after fetching %d intermediates", maxFetchedIntermediates)
+}
+
+// fetchCertificate downloads the certificate at url, DER or PEM encoded.
+func (d *dialer) fetchCertificate(ctx context.Context, url string) (*x509.Certificate, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIntermediateSize))
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+	return x509.ParseCertificate(raw)
+}
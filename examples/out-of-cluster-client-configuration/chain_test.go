@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeChain(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "shop.example.com"}, NotAfter: now.AddDate(0, 6, 0)}
+	intermediate := &x509.Certificate{Subject: pkix.Name{CommonName: "R3"}, NotAfter: now.AddDate(0, 3, 0)}
+	root := &x509.Certificate{Subject: pkix.Name{CommonName: "ISRG Root X1"}, NotAfter: now.AddDate(10, 0, 0)}
+	crossSigned := &x509.Certificate{Subject: pkix.Name{CommonName: "DST Root CA X3"}, NotAfter: now.AddDate(2, 0, 0)}
+
+	c := &certificate{NotAfter: leaf.NotAfter}
+	analyzeChain(c, [][]*x509.Certificate{{leaf, intermediate, root}, {leaf, intermediate, root, crossSigned}})
+	if len(c.VerifiedChain) != 2 || c.VerifiedChain[0].CommonName != "R3" || c.VerifiedChain[1].CommonName != "ISRG Root X1" {
+		t.Fatalf("expected the first chain up to its root, got %+v", c.VerifiedChain)
+	}
+	if !c.VerifiedChain[0].ExpiresBeforeLeaf || c.VerifiedChain[1].ExpiresBeforeLeaf || !c.chainExpiresFirst() {
+		t.Errorf("expected only the intermediate to expire before the leaf, got %+v", c.VerifiedChain)
+	}
+	if expected := []string{"ISRG Root X1", "DST Root CA X3"}; !reflect.DeepEqual(c.Roots, expected) {
+		t.Errorf("expected the roots %v of the cross-signed leaf, got %v", expected, c.Roots)
+	}
+	if sev := (policy{days: 30}).severity(result{certificate: c}, now); sev != severityWarning {
+		t.Errorf("expected an intermediate expiring first to be a warning, got %v", sev)
+	}
+}
+
+func TestCompleteChain(t *testing.T) {
+	root, rootKey := newTestCertificate(t, "Test Root", true, nil, nil)
+	intermediate, intermediateKey := newTestCertificate(t, "Test Intermediate", true, root, rootKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(intermediate.Raw)
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "shop.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"shop.example.com"},
+		IssuingCertificateURL: []string{server.URL + "/r3.der"},
+	}, intermediate, key.Public(), intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	d := &dialer{roots: roots, client: server.Client()}
+	if _, err := d.verify("shop.example.com", []*x509.Certificate{leaf}); err == nil {
+		t.Fatal("expected the leaf alone not to verify")
+	}
+	chains, url, err := d.completeChain(context.Background(), "shop.example.com", []*x509.Certificate{leaf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != server.URL+"/r3.der" || len(chains) != 1 || len(chains[0]) != 3 {
+		t.Errorf("expected a chain of 3 completed from %s/r3.der, got %d chains from %s", server.URL, len(chains), url)
+	}
+
+	if _, _, err := d.completeChain(context.Background(), "shop.example.com", []*x509.Certificate{root}); err == nil {
+		t.Error("expected an error without an issuer URL")
+	}
+}
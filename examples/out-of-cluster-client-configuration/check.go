@@ -0,0 +1,424 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// checker returns the result of checking a single target. It must return
+// once ctx is done.
+type checker func(ctx context.Context, t target) result
+
+// dialer connects to the hosts of targets and inspects the certificates they
+// serve.
+type dialer struct {
+	// timeout bounds every attempt at connecting to a host, completing the
+	// handshake and any revocation check done for it.
+	timeout time.Duration
+	// ocsp enables querying the OCSP responder of leaf certificates whose
+	// host did not staple a response.
+	ocsp bool
+	// fetchIntermediates enables completing the chains of hosts that do
+	// not serve every intermediate from the issuer URLs of certificates.
+	fetchIntermediates bool
+	// ct enables checking that served leaf certificates were logged to
+	// Certificate Transparency.
+	ct bool
+	// crls, if set, is used to look up the revocation status of leaf
+	// certificates that OCSP could not tell about.
+	crls *crlCache
+	// client is used for requests made on behalf of a check, such as OCSP
+	// queries.
+	client *http.Client
+	// roots are the certificate authorities served certificates are
+	// verified against, the system ones when nil.
+	roots *x509.CertPool
+	// insecureSkipVerify reports certificates that fail verification as
+	// warnings rather than errors.
+	insecureSkipVerify bool
+	// retry is how transient failures are retried; its steps are the number
+	// of retries.
+	retry wait.Backoff
+	// network is the network hosts are dialed on, tcp4 or tcp6 to only
+	// dial addresses of one family. All of them are dialed when empty.
+	network string
+	// proxy, if set, returns the proxy hosts are dialed through.
+	proxy proxyFunc
+	// limiter, if set, spaces out the dials of hosts and addresses.
+	limiter *dialLimiter
+	// connectTo maps hosts to the addr[:port] they are dialed at instead of
+	// their own address. Without a port the port of the target is used.
+	connectTo map[string]string
+	// alpn are the ALPN protocols offered in handshakes, none when nil.
+	// Hosts may serve another certificate depending on the protocol.
+	alpn []string
+	// protocol is the protocol hosts are asked to start TLS in, e.g. smtp,
+	// or empty for hosts starting the handshake right away.
+	protocol string
+	// clientCertificate, if set, is sent to hosts asking for a client
+	// certificate, so that hosts requiring one complete the handshake.
+	clientCertificate *tls.Certificate
+	// resolver looks up the hosts dialed, the one of the system when nil.
+	resolver *net.Resolver
+	// ips, if set, are the addresses the host of a check resolved to,
+	// dialed in order rather than looking it up again.
+	ips []string
+}
+
+// parseConnectTo parses values of the -connect-to flag, host=addr[:port],
+// into a map from host to addr[:port].
+func parseConnectTo(values []string) (map[string]string, error) {
+	connectTo := map[string]string{}
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 || i == len(v)-1 {
+			return nil, fmt.Errorf("invalid -connect-to %q: must be host=addr[:port]", v)
+		}
+		connectTo[v[:i]] = v[i+1:]
+	}
+	return connectTo, nil
+}
+
+// check is a checker that reports on the certificate served by the host of t.
+func (d *dialer) check(ctx context.Context, t target) result {
+	if t.address == "" {
+		t.address = d.connectTo[t.host]
+	}
+	if t.port == 0 {
+		t.port = defaultPort
+	}
+	port := strconv.Itoa(t.port)
+	addr := t.address
+	if addr == "" {
+		addr = net.JoinHostPort(t.host, port)
+	} else if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+		t.address = addr
+	}
+	if t.rootsPEM != nil {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(t.rootsPEM) {
+			return result{target: t, err: fmt.Errorf("no certificate authority found to verify %s against", t.host)}
+		}
+		withRoots := *d
+		withRoots.roots = roots
+		d = &withRoots
+	}
+	if t.alpn != nil {
+		withALPN := *d
+		withALPN.alpn = t.alpn
+		d = &withALPN
+	}
+	if t.protocol != "" {
+		withProtocol := *d
+		withProtocol.protocol = t.protocol
+		d = &withProtocol
+	}
+	if t.clientCertificate != nil {
+		withClientCertificate := *d
+		withClientCertificate.clientCertificate = t.clientCertificate
+		d = &withClientCertificate
+	}
+	// The host is looked up once, so that its addresses are reported and
+	// failing to look it up is told apart from failing to connect. Lookups
+	// may fail transiently, in which case dialing looks the host up again
+	// along with the retries.
+	var resolved []string
+	if host, _, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) == nil && !d.proxied(addr) {
+		lookupCtx, cancel := context.WithTimeout(ctx, d.timeout)
+		ips, err := d.resolve(lookupCtx, host)
+		cancel()
+		switch {
+		case err == nil:
+			resolved = ips
+			withIPs := *d
+			withIPs.ips = ips
+			d = &withIPs
+		case ctx.Err() != nil:
+			return result{target: t, attempts: 1, err: ctx.Err()}
+		case !transient(err):
+			return result{target: t, attempts: 1, err: err}
+		}
+	}
+	c, attempts, err := d.checkHostWithRetries(ctx, t.host, addr)
+	return result{target: t, certificate: c, attempts: attempts, err: err, resolved: resolved}
+}
+
+// proxied reports whether addr is dialed through a proxy.
+func (d *dialer) proxied(addr string) bool {
+	if d.proxy == nil {
+		return false
+	}
+	u, err := d.proxy(addr)
+	return err == nil && u != nil
+}
+
+// dialedIPs returns the addresses addr is dialed at: the ones its host
+// resolved to, looked up again if it failed to. Addresses dialed through a
+// proxy, or whose host does not resolve, have none.
+func (d *dialer) dialedIPs(ctx context.Context, addr string) []string {
+	if d.proxied(addr) {
+		return nil
+	}
+	if len(d.ips) > 0 {
+		return d.ips
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	ips, _ := d.resolve(lookupCtx, host)
+	return ips
+}
+
+// dial connects to addr, through the proxy of d for addr if any.
+func (d *dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if d.proxy != nil {
+		u, err := d.proxy(addr)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			return dialThrough(ctx, u, addr)
+		}
+	}
+	network := d.network
+	if network == "" {
+		network = "tcp"
+	}
+	netDialer := net.Dialer{Resolver: d.resolver}
+	if len(d.ips) == 0 {
+		return netDialer.DialContext(ctx, network, addr)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range d.ips {
+		var conn net.Conn
+		if conn, err = netDialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// checkHost dials addr, sending host as SNI, and returns the leaf certificate
+// it serves, after asking addr to start TLS if d has a protocol. Both the TCP
+// connection and the TLS handshake are abandoned once ctx is done. If the
+// certificate fails verification for host it is returned along with the
+// error.
+func (d *dialer) checkHost(ctx context.Context, host, addr string) (*certificate, error) {
+	rawConn, err := d.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer rawConn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+
+	// Closing the connection is the only way to interrupt a handshake that
+	// is blocked on the network.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			rawConn.Close()
+		case <-done:
+		}
+	}()
+	if err := startTLS(rawConn, d.protocol); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// The certificate is verified below rather than during the handshake,
+	// so that certificates failing verification can still be reported. It
+	// is kept as soon as it is received: servers requiring a client
+	// certificate, such as etcd, fail the handshake after sending theirs.
+	var peers []*x509.Certificate
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		CipherSuites:       offeredCipherSuites,
+		NextProtos:         d.alpn,
+		// The client certificate is sent whatever authorities the host
+		// asks for, none is sent without one.
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if d.clientCertificate == nil {
+				return &tls.Certificate{}, nil
+			}
+			return d.clientCertificate, nil
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			for _, raw := range rawCerts {
+				crt, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				peers = append(peers, crt)
+			}
+			return nil
+		},
+	})
+	state := tls.ConnectionState{}
+	if err := conn.Handshake(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if len(peers) == 0 {
+			return nil, err
+		}
+		state.PeerCertificates = peers
+	} else {
+		state = conn.ConnectionState()
+	}
+
+	c := newCertificate(state.PeerCertificates[0])
+	c.Chain = chainOf(state.PeerCertificates[1:])
+	c.served = state.PeerCertificates
+	if state.HandshakeComplete {
+		c.Protocol = newProtocol(state)
+	}
+	c.Coverage = hostCoverage(state.PeerCertificates[0], host)
+	state.VerifiedChains, err = d.verify(host, state.PeerCertificates)
+	if _, ok := err.(x509.HostnameError); ok {
+		err = hostnameError(state.PeerCertificates[0], host, c.Coverage)
+	}
+	if _, ok := err.(x509.UnknownAuthorityError); ok && d.fetchIntermediates {
+		if chains, url, fetchErr := d.completeChain(ctx, host, state.PeerCertificates); fetchErr == nil {
+			state.VerifiedChains, err = chains, nil
+			c.IncompleteChain = url
+		} else {
+			klog.V(2).InfoS("Completing the chain failed", "host", host, "err", fetchErr)
+		}
+	}
+	analyzeChain(c, state.VerifiedChains)
+	if err != nil {
+		if !d.insecureSkipVerify {
+			return c, err
+		}
+		c.VerifyError = err.Error()
+	}
+	if d.ct {
+		c.Transparency = checkTransparency(state, d.publiclyTrusted(state.VerifiedChains), time.Now())
+	}
+	c.Revocation = d.checkOCSP(ctx, state)
+	if d.crls != nil && (c.Revocation == nil || c.Revocation.Status == revocationUnknown) {
+		if leaf, issuer := leafAndIssuer(state); issuer != nil {
+			if r := d.crls.revocation(ctx, leaf, issuer); r != nil {
+				c.Revocation = r
+			}
+		}
+	}
+	return c, nil
+}
+
+// verify verifies the certificates served by host and returns the chains
+// they were verified through.
+func (d *dialer) verify(host string, certs []*x509.Certificate) ([][]*x509.Certificate, error) {
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         d.roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, crt := range certs[1:] {
+		opts.Intermediates.AddCert(crt)
+	}
+	return certs[0].Verify(opts)
+}
+
+// interruptedError is the error of the targets a scan did not check, or was
+// still checking, when it was interrupted by err.
+type interruptedError struct {
+	err error
+}
+
+func (e interruptedError) Error() string {
+	return "not checked, the scan was interrupted: " + e.err.Error()
+}
+
+func (e interruptedError) Unwrap() error {
+	return e.err
+}
+
+// checkTargets checks targets with at most concurrency checks in flight and
+// returns their results in the order of targets. Targets that were not
+// checked before ctx is done, or failed because it was, get an
+// interruptedError as their result.
+func checkTargets(ctx context.Context, targets []target, concurrency int, check checker) []result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]result, len(targets))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				t := targets[i]
+				klog.V(3).InfoS("Checking host", "namespace", t.namespace, "kind", t.kind, "object", t.object, "host", t.name())
+				start := time.Now()
+				results[i] = check(ctx, t)
+				if ctx.Err() != nil && results[i].err != nil && results[i].certificate == nil {
+					results[i].err = interruptedError{ctx.Err()}
+				}
+				klog.V(3).InfoS("Checked host", "namespace", t.namespace, "kind", t.kind, "object", t.object, "host", t.name(), "address", results[i].address, "duration", time.Since(start))
+			}
+		}()
+	}
+	for i := range targets {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			results[i] = result{target: targets[i], err: interruptedError{ctx.Err()}}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestCheckTargets(t *testing.T) {
+	var targets []target
+	for i := 0; i < 50; i++ {
+		targets = append(targets, target{host: fmt.Sprintf("host-%02d", i)})
+	}
+
+	var inFlight, maxInFlight int32
+	check := func(ctx context.Context, t target) result {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return result{target: t}
+	}
+
+	for _, concurrency := range []int{0, 1, 4, 100} {
+		maxInFlight = 0
+		results := checkTargets(context.Background(), targets, concurrency, check)
+		if len(results) != len(targets) {
+			t.Fatalf("concurrency %d: expected %d results, got %d", concurrency, len(targets), len(results))
+		}
+		for i, r := range results {
+			if r.host != targets[i].host {
+				t.Errorf("concurrency %d: result %d is for %s, expected %s", concurrency, i, r.host, targets[i].host)
+			}
+		}
+		limit := int32(concurrency)
+		if limit < 1 {
+			limit = 1
+		}
+		if maxInFlight > limit {
+			t.Errorf("concurrency %d: %d checks were in flight", concurrency, maxInFlight)
+		}
+	}
+}
+
+func TestCheckTargetsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	targets := []target{{host: "a"}, {host: "b"}, {host: "c"}}
+	results := checkTargets(ctx, targets, 1, func(ctx context.Context, t target) result {
+		return result{target: t, err: ctx.Err()}
+	})
+	for i, r := range results {
+		if r.host != targets[i].host {
+			t.Errorf("result %d is for %s, expected %s", i, r.host, targets[i].host)
+		}
+		if err, ok := r.err.(interruptedError); !ok || !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected the scan to be interrupted, got %v", r.host, r.err)
+		}
+	}
+}
+
+func TestDialerCheckHost(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	d := &dialer{roots: roots}
+	c, err := d.checkHost(context.Background(), "127.0.0.1", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Fingerprint != fingerprint(server.Certificate()) {
+		t.Errorf("expected the certificate of the server, got %+v", c)
+	}
+
+	// Without the CA the certificate is still reported, along with the
+	// error.
+	d = &dialer{roots: x509.NewCertPool()}
+	c, err = d.checkHost(context.Background(), "127.0.0.1", addr)
+	if _, ok := err.(x509.UnknownAuthorityError); !ok {
+		t.Errorf("expected an unknown authority error, got %v", err)
+	}
+	if c == nil {
+		t.Fatal("expected a certificate")
+	}
+
+	d.insecureSkipVerify = true
+	c, err = d.checkHost(context.Background(), "127.0.0.1", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.VerifyError == "" {
+		t.Error("expected the verification error to be reported")
+	}
+	if got := (policy{}).severity(result{certificate: c}, time.Now()); got != severityWarning {
+		t.Errorf("expected %v, got %v", severityWarning, got)
+	}
+}
+
+func TestDialerCheckHostProtocol(t *testing.T) {
+	tests := []struct {
+		config *tls.Config
+		want   protocol
+	}{
+		{
+			config: &tls.Config{MinVersion: tls.VersionTLS13},
+			want:   protocol{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256"},
+		},
+		{
+			config: &tls.Config{MinVersion: tls.VersionTLS11, MaxVersion: tls.VersionTLS11},
+			want:   protocol{Version: "TLS 1.1", CipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA", Weak: "TLS 1.1 is deprecated"},
+		},
+		{
+			config: &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256}},
+			want:   protocol{Version: "TLS 1.2", CipherSuite: "TLS_RSA_WITH_AES_128_GCM_SHA256", Weak: "TLS_RSA_WITH_AES_128_GCM_SHA256 is insecure"},
+		},
+	}
+	for _, test := range tests {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = test.config
+		server.StartTLS()
+		addr := strings.TrimPrefix(server.URL, "https://")
+
+		d := &dialer{insecureSkipVerify: true}
+		c, err := d.checkHost(context.Background(), "127.0.0.1", addr)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.want.Version, err)
+			continue
+		}
+		if c.Protocol == nil || *c.Protocol != test.want {
+			t.Errorf("expected %+v, got %+v", test.want, c.Protocol)
+		}
+	}
+}
+
+func TestDialerALPN(t *testing.T) {
+	// The server serves another certificate to clients offering h2, as
+	// gRPC gateways sharing an address with an HTTPS listener do.
+	crt, key := newTestCertificate(t, "grpc.example.com", false, nil, nil)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			for _, p := range hello.SupportedProtos {
+				if p == "h2" {
+					return &tls.Config{
+						Certificates: []tls.Certificate{{Certificate: [][]byte{crt.Raw}, PrivateKey: key}},
+						NextProtos:   []string{"h2"},
+					}, nil
+				}
+			}
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	d := &dialer{timeout: wait.ForeverTestTimeout, insecureSkipVerify: true}
+	r := d.check(context.Background(), target{host: "127.0.0.1", address: addr})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if r.certificate.Fingerprint != fingerprint(server.Certificate()) || r.certificate.Protocol.ALPN != "" {
+		t.Errorf("expected the HTTPS certificate without ALPN, got %+v", r.certificate)
+	}
+	r = d.check(context.Background(), target{host: "127.0.0.1", address: addr, alpn: []string{"h2"}})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if r.certificate.Fingerprint != fingerprint(crt) || r.certificate.Protocol.ALPN != "h2" {
+		t.Errorf("expected the h2 certificate, got %+v", r.certificate)
+	}
+}
+
+func TestDialerClientCertificate(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Client CA", true, nil, nil)
+	client, clientKey := newTestCertificate(t, "cert-check", false, ca, caKey)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	var sent string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		// The client only learns that its certificate was rejected during
+		// the handshake up to TLS 1.2.
+		MaxVersion: tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if crt, err := x509.ParseCertificate(rawCerts[0]); err == nil {
+				sent = crt.Subject.CommonName
+			}
+			return nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	// Without a client certificate the certificate of the server is still
+	// reported, but the handshake never completes.
+	d := &dialer{timeout: wait.ForeverTestTimeout, insecureSkipVerify: true}
+	r := d.check(context.Background(), target{host: "127.0.0.1", address: addr})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if r.certificate.Protocol != nil {
+		t.Errorf("expected the handshake to fail without a client certificate, got %+v", r.certificate.Protocol)
+	}
+	crt := &tls.Certificate{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}
+	r = d.check(context.Background(), target{host: "127.0.0.1", address: addr, clientCertificate: crt})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if sent != "cert-check" || r.certificate.Fingerprint != fingerprint(server.Certificate()) || r.certificate.Protocol == nil {
+		t.Errorf("expected the client certificate to complete the handshake, got %q and %+v", sent, r.certificate)
+	}
+}
+
+func TestParseALPN(t *testing.T) {
+	got, err := parseALPN("grpc, http/1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"h2", "http/1.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for _, invalid := range []string{"", "h2,", strings.Repeat("x", 256)} {
+		if _, err := parseALPN(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestDialerCheckHostClientAuth(t *testing.T) {
+	// With TLS 1.2 the server fails the handshake after sending its
+	// certificate.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	d := &dialer{roots: roots}
+	c, err := d.checkHost(context.Background(), "127.0.0.1", strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Fingerprint != fingerprint(server.Certificate()) {
+		t.Errorf("expected the certificate of the server, got %+v", c)
+	}
+}
+
+func TestDialerConnectTo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	// The certificate of the test server is valid for example.com, which
+	// only verifies when it is sent as SNI while dialing the server.
+	connectTo, err := parseConnectTo([]string{"example.com=" + strings.TrimPrefix(server.URL, "https://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dialer{timeout: wait.ForeverTestTimeout, roots: roots, connectTo: connectTo}
+	r := d.check(context.Background(), target{host: "example.com"})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if r.address != connectTo["example.com"] {
+		t.Errorf("expected %s to be dialed, got %s", connectTo["example.com"], r.address)
+	}
+}
+
+func TestDialerRootsPEM(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	rootsPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// The roots of the target take precedence over the ones of the dialer.
+	d := &dialer{timeout: wait.ForeverTestTimeout, roots: x509.NewCertPool()}
+	r := d.check(context.Background(), target{host: "example.com", address: strings.TrimPrefix(server.URL, "https://"), rootsPEM: rootsPEM})
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+}
+
+func TestParseConnectTo(t *testing.T) {
+	got, err := parseConnectTo([]string{"a.example.com=10.0.0.1", "b.example.com=lb.example.com:8443", "c.example.com=[::1]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.example.com": "10.0.0.1",
+		"b.example.com": "lb.example.com:8443",
+		"c.example.com": "[::1]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for _, invalid := range []string{"a.example.com", "=10.0.0.1", "a.example.com="} {
+		if _, err := parseConnectTo([]string{invalid}); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterOptions configure what is checked in every scanned cluster.
+type clusterOptions struct {
+	// client changes how the configuration of every cluster is loaded.
+	client    *clientFlags
+	resources []string
+	sources   sourceOptions
+	filter    scope
+	resync    time.Duration
+	// listPageSize is how many objects informers list at once, all of them
+	// when 0.
+	listPageSize int64
+	// certSource is dial, secret or compare, see -source.
+	certSource string
+	dial       checker
+	// controlPlane enables checking the API server and controlPlaneEndpoints.
+	controlPlane          bool
+	controlPlaneEndpoints []string
+	events                bool
+	annotate              bool
+	certificateReports    bool
+	renewalOverdue        time.Duration
+	policy                policy
+	// operator writes conditions onto ingresses and checks objects again
+	// when the severity of a certificate is about to change.
+	operator bool
+	// recheck, if set, checks objects again on schedule in watch mode.
+	recheck *recheckSchedule
+}
+
+// cluster is a scanned cluster: the controller reading its objects, and the
+// checker and notifiers that talk to its API server.
+type cluster struct {
+	// name is the kubeconfig context of the cluster, empty for the current
+	// context or the in-cluster configuration.
+	name       string
+	factories  *informerFactories
+	controller *controller
+	check      checker
+	notifiers  []notifier
+}
+
+// newCluster connects to the cluster of config and builds the controller
+// checking it with s. The informers are not started.
+func newCluster(name string, config *rest.Config, s *scanner, opts clusterOptions) (*cluster, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating the clientset: %v", err)
+	}
+	c := &cluster{name: name}
+	if opts.events {
+		c.notifiers = append(c.notifiers, newEventNotifier(clientset.CoreV1()))
+	}
+	if opts.annotate || opts.operator {
+		c.notifiers = append(c.notifiers, &annotationNotifier{client: clientset.ExtensionsV1beta1(), policy: opts.policy, conditions: opts.operator})
+	}
+
+	switch opts.certSource {
+	case "dial":
+		c.check = opts.dial
+	case "secret":
+		c.check = secretChecker(clientset.CoreV1().RESTClient())
+	case "compare":
+		c.check = compareChecker(clientset.CoreV1().RESTClient(), opts.dial)
+	default:
+		return nil, fmt.Errorf("unknown source %q", opts.certSource)
+	}
+	if opts.certSource != "secret" {
+		c.check = clientCertificateChecker(c.check, clientset.CoreV1().RESTClient())
+	}
+	c.check = caBundleChecker(c.check)
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating the dynamic client: %v", err)
+	}
+	if opts.certificateReports {
+		c.notifiers = append(c.notifiers, &reportNotifier{client: dynamicClient, policy: opts.policy, renewalOverdue: opts.renewalOverdue})
+	}
+	// Scans impersonating a tenant fail unless it may read everything they
+	// check, rather than retry listing what it may not.
+	if user := config.Impersonate.UserName; user != "" {
+		namespace, _, err := opts.filter.listOptions()
+		if err != nil {
+			return nil, err
+		}
+		denied, err := deniedResources(clientset.AuthorizationV1(), opts.resources, opts.certSource, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("reviewing the access of %s: %v", user, err)
+		}
+		if len(denied) > 0 {
+			return nil, fmt.Errorf("%s may not %s", user, strings.Join(denied, ", "))
+		}
+	}
+	if c.factories, err = newInformerFactories(clientset, dynamicClient, opts.filter, opts.listPageSize); err != nil {
+		return nil, fmt.Errorf("creating informers: %v", err)
+	}
+	srcOpts := opts.sources
+	if srcOpts.clusterCA, err = clusterCA(config); err != nil {
+		return nil, fmt.Errorf("reading the cluster CA: %v", err)
+	}
+	sources, err := c.factories.sources(opts.resources, srcOpts)
+	if err != nil {
+		return nil, fmt.Errorf("discovering the resources to check: %v", err)
+	}
+	certificates, err := c.factories.certManagerCertificates()
+	if err != nil {
+		return nil, fmt.Errorf("discovering cert-manager: %v", err)
+	}
+	if certificates != nil {
+		c.check = certManagerChecker(c.check, certificates.GetIndexer())
+	}
+	c.controller = newController(s, sources...)
+	if certificates != nil {
+		c.controller.waitFor(certificates)
+	}
+	if mayWatchNamespaces(clientset.AuthorizationV1()) {
+		c.controller.watchNamespaces(c.factories.namespaces())
+	}
+	c.controller.resync = opts.resync
+	c.controller.cluster = name
+	c.controller.requeue = opts.operator
+	c.controller.recheck = opts.recheck
+	if opts.controlPlane {
+		if c.controller.static, err = controlPlaneTargets(config, opts.controlPlaneEndpoints); err != nil {
+			return nil, fmt.Errorf("invalid -control-plane-endpoint: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// newClusters connects to the cluster of every context concurrently. The
+// empty context is the current one, or the in-cluster configuration if
+// kubeconfig was not set explicitly and does not exist.
+func newClusters(kubeconfig string, explicit bool, contexts []string, s *scanner, opts clusterOptions) ([]*cluster, error) {
+	clusters := make([]*cluster, len(contexts))
+	errs := make([]error, len(contexts))
+	var wg sync.WaitGroup
+	for i, name := range contexts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			var config *rest.Config
+			if name == "" {
+				config, errs[i] = buildConfig(kubeconfig, explicit, opts.client)
+			} else if config, errs[i] = contextConfig(loadingRules(kubeconfig, explicit), name); errs[i] == nil {
+				opts.client.apply(config)
+			}
+			if errs[i] == nil {
+				clusters[i], errs[i] = newCluster(name, config, s, opts)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			if contexts[i] != "" {
+				return nil, fmt.Errorf("context %q: %v", contexts[i], err)
+			}
+			return nil, err
+		}
+	}
+	return clusters, nil
+}
+
+// contextConfig loads the client configuration of context in the kubeconfig
+// files of rules.
+func contextConfig(rules *clientcmd.ClientConfigLoadingRules, context string) (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+}
+
+// kubeconfigContexts returns the contexts of the kubeconfig files of rules
+// that are scanned: every one of them if all is set, else the given ones,
+// which must exist.
+func kubeconfigContexts(rules *clientcmd.ClientConfigLoadingRules, contexts []string, all bool) ([]string, error) {
+	config, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		var names []string
+		for name := range config.Contexts {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no context in %s", kubeconfigName(rules))
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	for _, name := range contexts {
+		if _, ok := config.Contexts[name]; !ok {
+			return nil, fmt.Errorf("no context %q in %s", name, kubeconfigName(rules))
+		}
+	}
+	return contexts, nil
+}
+
+// clusterChecker checks every target with the checker of its cluster.
+func clusterChecker(clusters []*cluster) checker {
+	checks := map[string]checker{}
+	for _, c := range clusters {
+		checks[c.name] = c.check
+	}
+	return func(ctx context.Context, t target) result {
+		return checks[t.cluster](ctx, t)
+	}
+}
+
+// clusterNotifier sends the part of every summary about a cluster to the
+// notifiers of that cluster, such as the ones recording events, when several
+// clusters are scanned at once. It is a resolving notifier so that the
+// resolving notifiers of clusters whose hosts are all fine are notified too.
+type clusterNotifier struct {
+	notifiers map[string][]notifier
+	policy    policy
+}
+
+func (n *clusterNotifier) resolvesFindings() {}
+
+// newClusterNotifier returns the notifier of every cluster, or nil if none
+// has notifiers.
+func newClusterNotifier(clusters []*cluster, p policy) notifier {
+	n := &clusterNotifier{notifiers: map[string][]notifier{}, policy: p}
+	for _, c := range clusters {
+		if len(c.notifiers) > 0 {
+			n.notifiers[c.name] = c.notifiers
+		}
+	}
+	if len(n.notifiers) == 0 {
+		return nil
+	}
+	return n
+}
+
+func (n *clusterNotifier) notify(ctx context.Context, s *summary) error {
+	byCluster := map[string][]result{}
+	for _, r := range s.results {
+		byCluster[r.cluster] = append(byCluster[r.cluster], r)
+	}
+	for name, notifiers := range n.notifiers {
+		if s.object != nil && s.object.cluster != name {
+			continue
+		}
+		sum := newSummary(byCluster[name], n.policy, s.Time)
+		sum.Interrupted, sum.scope, sum.object = s.Interrupted, s.scope, s.object
+		notifyAll(ctx, notifiers, sum)
+	}
+	return nil
+}
+
+// clusterTargets returns the targets of every cluster, waiting for the
+// caches of all of them to fill concurrently.
+func clusterTargets(ctx context.Context, clusters []*cluster) ([]target, error) {
+	targets := make([][]target, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c *cluster) {
+			defer wg.Done()
+			targets[i], errs[i] = c.controller.targets(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	var all []target
+	for i := range clusters {
+		if errs[i] != nil {
+			if clusters[i].name != "" {
+				return nil, fmt.Errorf("context %q: %v", clusters[i].name, errs[i])
+			}
+			return nil, errs[i]
+		}
+		all = append(all, targets[i]...)
+	}
+	return all, nil
+}
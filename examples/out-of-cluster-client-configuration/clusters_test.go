@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: staging
+  cluster:
+    server: https://staging.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: staging
+  context: {cluster: staging, user: admin}
+- name: prod
+  context: {cluster: prod, user: admin}
+current-context: prod
+`
+
+func TestKubeconfigContexts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(file, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := kubeconfigContexts(loadingRules(file, true), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"prod", "staging"}; !reflect.DeepEqual(all, expected) {
+		t.Errorf("expected every context %v, got %v", expected, all)
+	}
+	some, err := kubeconfigContexts(loadingRules(file, true), []string{"staging"}, false)
+	if err != nil || !reflect.DeepEqual(some, []string{"staging"}) {
+		t.Errorf("expected [staging], got %v, %v", some, err)
+	}
+	if _, err := kubeconfigContexts(loadingRules(file, true), []string{"dev"}, false); err == nil {
+		t.Error("expected an error for a missing context")
+	}
+
+	config, err := contextConfig(loadingRules(file, true), "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://staging.example.com" {
+		t.Errorf("expected the server of staging, got %s", config.Host)
+	}
+}
+
+func TestKubeconfigContextsEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prod := filepath.Join(dir, "prod")
+	if err := ioutil.WriteFile(prod, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: prod
+  context: {cluster: prod, user: admin}
+current-context: prod
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	staging := filepath.Join(dir, "staging")
+	if err := ioutil.WriteFile(staging, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+contexts:
+- name: staging
+  context: {cluster: staging, user: admin}
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", prod+string(filepath.ListSeparator)+staging)
+
+	rules := loadingRules(filepath.Join(dir, "missing"), false)
+	all, err := kubeconfigContexts(rules, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"prod", "staging"}; !reflect.DeepEqual(all, expected) {
+		t.Errorf("expected the contexts of both files %v, got %v", expected, all)
+	}
+	config, err := contextConfig(rules, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://staging.example.com" || config.BearerToken != "secret" {
+		t.Errorf("expected the server of staging and the user of prod, got %s, %q", config.Host, config.BearerToken)
+	}
+
+	if _, err := kubeconfigContexts(loadingRules(prod, true), []string{"staging"}, false); err == nil {
+		t.Error("expected $KUBECONFIG to be ignored with an explicit -kubeconfig")
+	}
+}
+
+func TestClusterChecker(t *testing.T) {
+	clusters := []*cluster{{name: "prod"}, {name: "staging"}}
+	for _, c := range clusters {
+		name := c.name
+		c.check = func(ctx context.Context, t target) result {
+			return result{target: t, err: errors.New(name)}
+		}
+	}
+	check := clusterChecker(clusters)
+	for _, name := range []string{"prod", "staging"} {
+		if r := check(context.Background(), target{cluster: name, host: "shop.example.com"}); r.err.Error() != name {
+			t.Errorf("expected %s to be checked in its own cluster, got %v", name, r.err)
+		}
+	}
+}
+
+// recordingNotifier records the summaries it is sent.
+type recordingNotifier struct {
+	summaries []*summary
+}
+
+func (n *recordingNotifier) notify(ctx context.Context, s *summary) error {
+	n.summaries = append(n.summaries, s)
+	return nil
+}
+
+func TestClusterNotifier(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	prod, staging := &recordingNotifier{}, &recordingNotifier{}
+	n := newClusterNotifier([]*cluster{
+		{name: "prod", notifiers: []notifier{prod}},
+		{name: "staging", notifiers: []notifier{staging}},
+		{name: "dev"},
+	}, policy{days: 30})
+	results := []result{
+		{target: target{cluster: "prod", namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}, err: errors.New("connection refused")},
+		{target: target{cluster: "staging", namespace: "shop", kind: ingressKind, object: "web", host: "shop.staging.example.com"}, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}},
+	}
+	if err := n.notify(context.Background(), newSummary(results, policy{days: 30}, now)); err != nil {
+		t.Fatal(err)
+	}
+	if len(prod.summaries) != 1 || prod.summaries[0].Findings != 1 || prod.summaries[0].Namespaces[0].Cluster != "prod" {
+		t.Errorf("expected prod to be notified about its own finding, got %+v", prod.summaries)
+	}
+	if len(staging.summaries) != 0 {
+		t.Errorf("expected staging not to be notified without findings, got %+v", staging.summaries)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+// tableColumns are the columns of -o table, and wideColumns the ones of
+// -o wide, in -o custom-columns syntax.
+const (
+	tableColumns = "NAMESPACE:.namespace,KIND:.kind,OBJECT:.object,HOST:.host,EXPIRES:.notAfter,DAYS:.daysRemaining,SEVERITY:.severity"
+	wideColumns  = "CLUSTER:.cluster," + tableColumns + ",GRADE:.grade,ISSUER:.issuer,ALGORITHM:.algorithm,TLS:.tlsVersion,CHANGE:.change,FAILURE:.failure,ERROR:.error"
+)
+
+// column is a column of a table report: a header and the JSONPath of its
+// values in the row of every result.
+type column struct {
+	header   string
+	jsonPath *jsonpath.JSONPath
+}
+
+// parseColumns parses the columns of -o custom-columns, as with kubectl
+// HEADER:jsonpath separated by commas, e.g. NAME:.host,EXPIRES:.notAfter.
+func parseColumns(spec string) ([]column, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("custom-columns format specified but no custom columns given")
+	}
+	var columns []column
+	for _, s := range strings.Split(spec, ",") {
+		i := strings.Index(s, ":")
+		if i <= 0 || i == len(s)-1 {
+			return nil, fmt.Errorf("invalid custom column %q: expected HEADER:jsonpath", s)
+		}
+		path := strings.TrimSpace(s[i+1:])
+		if !strings.HasPrefix(path, "{") {
+			path = "{" + path + "}"
+		}
+		j := jsonpath.New(s[:i]).AllowMissingKeys(true)
+		if err := j.Parse(path); err != nil {
+			return nil, fmt.Errorf("invalid custom column %q: %v", s, err)
+		}
+		columns = append(columns, column{header: s[:i], jsonPath: j})
+	}
+	return columns, nil
+}
+
+// tableReporter returns a reporter writing the results of every scan to w as
+// a table of columns, without the header line if noHeaders is set.
+func tableReporter(w io.Writer, columns []column, noHeaders bool) reporter {
+	return func(results []result, p policy) {
+		if err := writeTableReport(w, results, p, time.Now(), columns, noHeaders); err != nil {
+			klog.ErrorS(err, "Writing the table report failed")
+		}
+	}
+}
+
+// writeTableReport writes a line per result to w, with the values of
+// columns aligned. Columns without a value show <none>, as with kubectl.
+func writeTableReport(w io.Writer, results []result, p policy, now time.Time, columns []column, noHeaders bool) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	if !noHeaders {
+		headers := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = c.header
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, r := range results {
+		row, err := tableRow(r, p, now)
+		if err != nil {
+			return err
+		}
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			var b bytes.Buffer
+			if err := c.jsonPath.Execute(&b, row); err != nil {
+				return fmt.Errorf("column %s: %v", c.header, err)
+			}
+			values[i] = b.String()
+			if values[i] == "" {
+				values[i] = "<none>"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableRow returns the object the columns of r are looked up in: its CSV
+// columns, and the whole certificate as it is logged under certificate, e.g.
+// .certificate.dnsNames.
+func tableRow(r result, p policy, now time.Time) (map[string]interface{}, error) {
+	row := map[string]interface{}{}
+	for i, value := range csvRecord(r, p, now) {
+		row[csvColumns[i]] = value
+	}
+	if r.certificate != nil {
+		// JSONPath only follows the JSON names of generic values.
+		var c interface{}
+		if err := json.Unmarshal([]byte(r.certificate.Jsonify()), &c); err != nil {
+			return nil, err
+		}
+		row["certificate"] = c
+	}
+	return row, nil
+}
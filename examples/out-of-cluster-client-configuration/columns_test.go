@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWriteTableReport(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}, certificate: &certificate{
+			CommonName: "shop.example.com",
+			NotAfter:   now.AddDate(0, 0, 3),
+			DNSNames:   []string{"shop.example.com", "www.shop.example.com"},
+		}},
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "api.example.com", port: 8443}, err: errors.New("connection refused")},
+	}
+
+	columns, err := parseColumns(tableColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := writeTableReport(&b, results, policy{days: 30}, now, columns, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := `NAMESPACE   KIND      OBJECT   HOST                   EXPIRES                DAYS     SEVERITY
+shop        Ingress   web      shop.example.com       2019-10-17T00:00:00Z   3        WARNING
+shop        Ingress   web      api.example.com:8443   <none>                 <none>   ERROR
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	if columns, err = parseColumns("NAME:.host,NAMES:.certificate.dnsNames[*]"); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := writeTableReport(&b, results[:1], policy{days: 30}, now, columns, true); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "shop.example.com   shop.example.com www.shop.example.com\n"; b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+
+	if _, err := parseColumns(wideColumns); err != nil {
+		t.Error(err)
+	}
+	for _, spec := range []string{"", "NAME", "NAME:", ":.host", "NAME:.host{"} {
+		if _, err := parseColumns(spec); err == nil {
+			t.Errorf("expected %q to be invalid", spec)
+		}
+	}
+}
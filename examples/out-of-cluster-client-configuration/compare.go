@@ -0,0 +1,39 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	"k8s.io/client-go/rest"
+)
+
+// compareChecker returns a checker that checks the host of a target with dial
+// and compares the served certificate with the one stored in the referenced
+// Secret or embedded in the object. A mismatch usually means a stale Secret,
+// a controller falling back to its default certificate, or a failed reload.
+func compareChecker(client rest.Interface, dial checker) checker {
+	return func(ctx context.Context, t target) result {
+		stored, err := storedCertificate(ctx, client, t)
+		if err != nil {
+			return result{target: t, err: err}
+		}
+		r := dial(ctx, t)
+		r.stored = stored
+		return r
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"sigs.k8s.io/yaml"
+)
+
+// configEnvPrefix is the prefix of the environment variables setting flags,
+// e.g. CERT_CHECK_DAYS for -days and CERT_CHECK_NOTIFY_SLACK_WEBHOOK for
+// -notify-slack-webhook.
+const configEnvPrefix = "CERT_CHECK_"
+
+// configEnv returns the name of the environment variable of the flag name.
+func configEnv(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// applyEnv sets every flag of fs that is not in set from its environment
+// variable, if it has one, and adds it to set.
+func applyEnv(fs *flag.FlagSet, set map[string]bool, lookupEnv func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		value, ok := lookupEnv(configEnv(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid $%s: %v", configEnv(f.Name), setErr)
+			return
+		}
+		set[f.Name] = true
+	})
+	return err
+}
+
+// configFile is the YAML file read by -config, mapping the names of flags to
+// their values:
+//
+//	days: 14
+//	resources: [ingresses, routes]
+//	notify-email-to:
+//	- ops@example.com
+//	- payments=payments-team@example.com
+//
+// Every value of a list is set in turn on flags that may be repeated, and
+// the values are joined with commas for the others. Flags set on the command
+// line or in the environment take precedence.
+type configFile map[string]interface{}
+
+// loadConfigFile reads file, whose keys must all be flags of fs and whose
+// values must be valid for them.
+func loadConfigFile(file string, fs *flag.FlagSet) (configFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := configFile{}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %v", file, err)
+	}
+	for name := range c {
+		f := fs.Lookup(name)
+		if f == nil || name == "config" {
+			return nil, fmt.Errorf("invalid configuration file %s: unknown flag %q", file, name)
+		}
+		values, err := c.values(f)
+		if err == nil {
+			err = validateFlag(f, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %s: %v", file, name, err)
+		}
+	}
+	return c, nil
+}
+
+// apply sets the flags of fs that are not in set, and adds them to it.
+func (c configFile) apply(fs *flag.FlagSet, set map[string]bool) error {
+	// Flags are set in order so that errors are reported consistently.
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if set[name] {
+			continue
+		}
+		values, err := c.values(fs.Lookup(name))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		set[name] = true
+	}
+	return nil
+}
+
+// values returns the values f is set to in turn: every value of a list for
+// flags that may be repeated, or all of them joined with commas.
+func (c configFile) values(f *flag.Flag) ([]string, error) {
+	values, err := configValues(c[f.Name])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.Value.(*stringSlice); !ok {
+		values = []string{strings.Join(values, ",")}
+	}
+	return values, nil
+}
+
+// validateFlag sets values on a new value of the type of f, so that invalid
+// values are reported without changing f.
+func validateFlag(f *flag.Flag, values []string) error {
+	t := reflect.TypeOf(f.Value)
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	v, ok := reflect.New(t.Elem()).Interface().(flag.Value)
+	if !ok {
+		return nil
+	}
+	for _, value := range values {
+		if err := v.Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configValues returns value, a scalar or a list of scalars, as the strings
+// flags are set to.
+func configValues(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		switch v := v.(type) {
+		case string:
+			values = append(values, v)
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("unsupported value %v, must be a string, number, boolean or a list of them", v)
+		}
+	}
+	return values, nil
+}
+
+// reexec replaces the process with a new one running the same executable
+// with the same arguments and environment, so that the configuration file
+// is read again from scratch.
+func reexec() error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, os.Args, os.Environ())
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte(`
+days: 14
+months: 1
+years: 1
+watch: true
+timeout: 5s
+resources: [ingresses, routes]
+notify-email-to:
+- ops@example.com
+- payments=payments-team@example.com
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	days := fs.Int("days", 0, "")
+	months := fs.Int("months", 0, "")
+	years := fs.Int("years", 0, "")
+	watch := fs.Bool("watch", false, "")
+	timeout := fs.Duration("timeout", 10*time.Second, "")
+	resources := fs.String("resources", "ingresses", "")
+	var emailTo stringSlice
+	fs.Var(&emailTo, "notify-email-to", "")
+	fs.String("config", "", "")
+	if err := fs.Parse([]string{"-days=7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	env := map[string]string{"CERT_CHECK_MONTHS": "2", "CERT_CHECK_DAYS": "3"}
+	if err := applyEnv(fs, set, func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadConfigFile(file, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(fs, set); err != nil {
+		t.Fatal(err)
+	}
+
+	// The command line takes precedence over the environment, which takes
+	// precedence over the file.
+	if *days != 7 || *months != 2 || *years != 1 {
+		t.Errorf("expected 1 year, 2 months and 7 days, got %d, %d and %d", *years, *months, *days)
+	}
+	if !*watch || *timeout != 5*time.Second || *resources != "ingresses,routes" {
+		t.Errorf("unexpected watch %v, timeout %v and resources %q", *watch, *timeout, *resources)
+	}
+	if want := (stringSlice{"ops@example.com", "payments=payments-team@example.com"}); !reflect.DeepEqual(emailTo, want) {
+		t.Errorf("expected recipients %v, got %v", want, emailTo)
+	}
+	if !set["years"] || !set["watch"] {
+		t.Errorf("expected the flags of the file to be set, got %v", set)
+	}
+
+	for _, invalid := range []string{
+		"dayz: 14\n",
+		"config: other.yaml\n",
+		"days:\n  value: 14\n",
+		"timeout: soon\n",
+		"{",
+	} {
+		if err := ioutil.WriteFile(file, []byte(invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfigFile(file, fs); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// source is a kind of object whose TLS hosts are checked, served from a
+// shared informer.
+type source struct {
+	kind string
+	// objectKind is the kind the targets of the source report their objects
+	// as, kind if empty.
+	objectKind string
+	informer   cache.SharedIndexInformer
+	// targets returns the targets of an object of the informer.
+	targets func(obj interface{}) []target
+	// changed reports whether an update of an object changes its targets.
+	changed func(old, new interface{}) bool
+	// dependencies are the other objects the targets depend on.
+	dependencies []dependency
+}
+
+// object returns the key of the object of src with the namespace/name key in
+// cluster.
+func (src *source) object(cluster, key string) objectKey {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	kind := src.objectKind
+	if kind == "" {
+		kind = src.kind
+	}
+	return objectKey{cluster: cluster, kind: kind, namespace: namespace, object: name}
+}
+
+// dependency is an informer of objects the targets of a source depend on,
+// e.g. the HTTPRoutes attached to a Gateway.
+type dependency struct {
+	informer cache.SharedIndexInformer
+	// keys returns the keys of the objects of the source whose targets
+	// depend on obj.
+	keys func(obj interface{}) []string
+}
+
+// queueKey identifies a queued object: its namespace/name key within the
+// source of kind.
+type queueKey struct {
+	kind string
+	key  string
+}
+
+// staticKey is the queue key of the static targets of a controller.
+var staticKey = queueKey{kind: "static"}
+
+// controller checks the TLS hosts of objects served from shared informers.
+// Only the objects that were added or whose TLS section changed are queued,
+// so a running controller never re-scans the whole cluster unless asked to by
+// the resync period, in scan rounds.
+type controller struct {
+	sources map[string]*source
+	synced  []cache.InformerSynced
+	queue   workqueue.RateLimitingInterface
+	scanner *scanner
+	// static are targets that are not taken from any object, such as
+	// control plane endpoints. They are checked in every scan round.
+	static []target
+	// resync is how often a scan round checks every object again, 0 for
+	// only the one on start.
+	resync time.Duration
+	// cluster is the context of the cluster the objects are read from, set
+	// on every target.
+	cluster string
+	// requeue queues every checked object again for when one of its
+	// certificates enters the warning window or expires.
+	requeue bool
+	// recheck, if set, queues every checked object again after the
+	// interval of the first of its certificates to expire.
+	recheck *recheckSchedule
+	// namespaces, if set, serves the namespaces whose annotations set the
+	// warning window of the targets in them, or ignore them.
+	namespaces cache.SharedIndexInformer
+
+	mu sync.Mutex
+	// round is the scan round being checked, if any.
+	round *scanRound
+}
+
+// scanRound gathers the results of a scan round: of every object in the
+// caches on start, and again on every resync. Their summary is sent to the
+// round notifiers once every object of the round was checked.
+type scanRound struct {
+	started time.Time
+	// pending are the objects of the round not checked yet.
+	pending map[queueKey]bool
+	results []result
+	// changes are the results that changed since the previous scan, with
+	// -diff-with.
+	changes []result
+}
+
+func newController(s *scanner, sources ...*source) *controller {
+	c := &controller{
+		sources: map[string]*source{},
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		scanner: s,
+	}
+
+	for _, src := range sources {
+		src := src
+		c.sources[src.kind] = src
+		c.synced = append(c.synced, src.informer.HasSynced)
+		src.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.enqueue(src.kind, obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				// Relists deliver the same resource version; those are the
+				// only updates we check without a change to the targets of
+				// the object.
+				oldMeta, err1 := meta.Accessor(old)
+				newMeta, err2 := meta.Accessor(new)
+				if err1 == nil && err2 == nil &&
+					oldMeta.GetResourceVersion() != newMeta.GetResourceVersion() &&
+					!src.changed(old, new) {
+					return
+				}
+				c.enqueue(src.kind, new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				// The informer uses a delta queue, therefore for deletes we
+				// have to use this key function.
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				if err == nil {
+					c.queue.Add(queueKey{kind: src.kind, key: key})
+				}
+			},
+		})
+		for _, dep := range src.dependencies {
+			c.watchDependency(src.kind, dep)
+		}
+	}
+
+	return c
+}
+
+// waitFor makes the controller wait for informers to sync before checking
+// anything, for informers that checkers depend on.
+func (c *controller) waitFor(informers ...cache.SharedIndexInformer) {
+	for _, informer := range informers {
+		c.synced = append(c.synced, informer.HasSynced)
+	}
+}
+
+// watchDependency queues the objects of kind depending on the objects of dep
+// whenever those change.
+func (c *controller) watchDependency(kind string, dep dependency) {
+	c.synced = append(c.synced, dep.informer.HasSynced)
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		for _, key := range dep.keys(obj) {
+			c.queue.Add(queueKey{kind: kind, key: key})
+		}
+	}
+	dep.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(old, new interface{}) {
+			// Resyncs of the dependency are covered by the resyncs of the
+			// source itself.
+			oldMeta, err1 := meta.Accessor(old)
+			newMeta, err2 := meta.Accessor(new)
+			if err1 == nil && err2 == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			enqueue(old)
+			enqueue(new)
+		},
+		DeleteFunc: enqueue,
+	})
+}
+
+func (c *controller) enqueue(kind string, obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	klog.V(3).InfoS("Queued object", "kind", kind, "object", key)
+	c.queue.Add(queueKey{kind: kind, key: key})
+}
+
+// targets waits for the caches to fill and returns the targets of every
+// object in them, so that the targets of several controllers can be checked
+// in a single scan.
+func (c *controller) targets(ctx context.Context) ([]target, error) {
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		return nil, fmt.Errorf("timed out waiting for caches to sync")
+	}
+	targets := c.inCluster(c.static)
+	for _, src := range c.sources {
+		for _, obj := range src.informer.GetStore().List() {
+			targets = append(targets, c.inCluster(src.targets(obj))...)
+		}
+	}
+	return targets, nil
+}
+
+// inCluster returns a copy of targets in the cluster of the controller,
+// with the warning window of their namespace unless they have their own.
+// Targets in ignored namespaces are left out.
+func (c *controller) inCluster(targets []target) []target {
+	in := make([]target, 0, len(targets))
+	for _, t := range targets {
+		t.cluster = c.cluster
+		if c.namespaces != nil && t.namespace != "" {
+			annotations := namespaceAnnotations(c.namespaces, t.namespace)
+			if ignored("", t.namespace, annotations) {
+				continue
+			}
+			if t.warnBefore == 0 {
+				t.warnBefore = annotatedWarnBefore("", t.namespace, annotations)
+			}
+		}
+		in = append(in, t)
+	}
+	return in
+}
+
+// watchNamespaces sets the warning window of targets from the namespaces
+// of informer, and queues every object of a namespace whose warn-before or
+// ignore annotation changes.
+func (c *controller) watchNamespaces(informer cache.SharedIndexInformer) {
+	c.namespaces = informer
+	c.synced = append(c.synced, informer.HasSynced)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			oldNs, newNs := old.(*v1.Namespace), new.(*v1.Namespace)
+			if oldNs.Annotations[warnBeforeAnnotation] == newNs.Annotations[warnBeforeAnnotation] &&
+				oldNs.Annotations[ignoreAnnotation] == newNs.Annotations[ignoreAnnotation] {
+				return
+			}
+			for _, src := range c.sources {
+				objs, err := src.informer.GetIndexer().ByIndex(cache.NamespaceIndex, newNs.Name)
+				if err != nil {
+					continue
+				}
+				for _, obj := range objs {
+					c.enqueue(src.kind, obj)
+				}
+			}
+		},
+	})
+}
+
+// Run checks queued objects with the given number of workers until ctx is
+// done.
+func (c *controller) Run(ctx context.Context, workers int) {
+	defer runtime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
+		return
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(func() { c.runWorker(ctx) }, time.Second, ctx.Done())
+	}
+	if c.resync > 0 {
+		go wait.Until(func() { c.startRound(ctx) }, c.resync, ctx.Done())
+	} else {
+		c.startRound(ctx)
+	}
+
+	<-ctx.Done()
+}
+
+// startRound queues every object in the caches, and the static targets, for
+// a new scan round. A round still being checked when the next one is due is
+// left to finish instead.
+func (c *controller) startRound(ctx context.Context) {
+	c.mu.Lock()
+	if c.round != nil {
+		c.mu.Unlock()
+		klog.V(2).InfoS("Previous scan round not done yet, skipping this one", "pending", len(c.round.pending))
+		return
+	}
+	var keys []queueKey
+	if len(c.static) > 0 {
+		keys = append(keys, staticKey)
+	}
+	for _, src := range c.sources {
+		for _, key := range src.informer.GetStore().ListKeys() {
+			keys = append(keys, queueKey{kind: src.kind, key: key})
+		}
+	}
+	r := &scanRound{started: time.Now(), pending: map[queueKey]bool{}}
+	for _, key := range keys {
+		r.pending[key] = true
+	}
+	if len(keys) > 0 {
+		c.round = r
+	}
+	c.mu.Unlock()
+	if len(keys) == 0 {
+		c.scanner.notifyRound(ctx, r)
+		return
+	}
+	for _, key := range keys {
+		c.queue.Add(key)
+	}
+}
+
+// checked records the results of key in the scan round, if it is part of
+// it, and sends the summary of the round once it was the last one.
+func (c *controller) checked(ctx context.Context, key queueKey, results []result, sum *summary) {
+	c.mu.Lock()
+	r := c.round
+	if r == nil || !r.pending[key] {
+		c.mu.Unlock()
+		return
+	}
+	delete(r.pending, key)
+	r.results = append(r.results, results...)
+	if sum != nil && sum.changes != nil {
+		r.changes = append(r.changes, sum.changes.results...)
+	}
+	done := len(r.pending) == 0
+	if done {
+		c.round = nil
+	}
+	c.mu.Unlock()
+	if done && ctx.Err() == nil {
+		c.scanner.notifyRound(ctx, r)
+	}
+}
+
+func (c *controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *controller) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.sync(ctx, key.(queueKey))
+	if err == nil {
+		c.queue.Forget(key)
+		return true
+	}
+
+	// Retry a few times before giving up on the object; it will be queued
+	// again on its next update.
+	if c.queue.NumRequeues(key) < 5 {
+		klog.V(2).InfoS("Retrying object", "kind", key.(queueKey).kind, "object", key.(queueKey).key, "err", err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	runtime.HandleError(fmt.Errorf("dropping %s %q out of the queue: %v", key.(queueKey).kind, key.(queueKey).key, err))
+	return true
+}
+
+func (c *controller) sync(ctx context.Context, key queueKey) error {
+	if key == staticKey {
+		results, sum := c.scanner.scanObject(ctx, nil, c.inCluster(c.static))
+		c.checked(ctx, key, results, sum)
+		return nil
+	}
+	src, ok := c.sources[key.kind]
+	if !ok {
+		return fmt.Errorf("unknown kind %q", key.kind)
+	}
+	obj, exists, err := src.informer.GetIndexer().GetByKey(key.key)
+	if err != nil {
+		return err
+	}
+	object := src.object(c.cluster, key.key)
+	if !exists {
+		klog.InfoS("Object deleted, no longer checked", "kind", key.kind, "object", key.key)
+		c.scanner.forget(ctx, object)
+		c.checked(ctx, key, nil, nil)
+		return nil
+	}
+	results, sum := c.scanner.scanObject(ctx, &object, c.inCluster(src.targets(obj)))
+	c.checked(ctx, key, results, sum)
+	var after time.Duration
+	var requeue bool
+	if c.requeue {
+		after, requeue = nextCheck(results, c.scanner.policy, time.Now())
+	}
+	if recheck, scheduled := c.recheck.after(results, time.Now()); scheduled && (!requeue || recheck < after) {
+		after, requeue = recheck, true
+	}
+	// The queue only keeps the earliest time an object is queued again
+	// for.
+	if requeue {
+		klog.V(3).InfoS("Requeued object", "kind", key.kind, "object", key.key, "after", after)
+		c.queue.AddAfter(key, after)
+	}
+	return nil
+}
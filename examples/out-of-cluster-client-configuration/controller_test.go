@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// summaryRecorder records the summaries it is sent.
+type summaryRecorder struct {
+	mu        sync.Mutex
+	summaries []*summary
+}
+
+func (n *summaryRecorder) notify(ctx context.Context, s *summary) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.summaries = append(n.summaries, s)
+	return nil
+}
+
+func (n *summaryRecorder) recorded() []*summary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*summary(nil), n.summaries...)
+}
+
+// roundRecorder records the summaries of scan rounds.
+type roundRecorder struct {
+	summaryRecorder
+}
+
+func (n *roundRecorder) perRound() {}
+
+// objectRecorder records the summaries of single objects, with or without
+// findings.
+type objectRecorder struct {
+	summaryRecorder
+}
+
+func (n *objectRecorder) resolvesFindings() {}
+
+func TestControllerRounds(t *testing.T) {
+	ingress := func(name string) *v1beta1.Ingress {
+		return &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+			Spec:       v1beta1.IngressSpec{TLS: []v1beta1.IngressTLS{{Hosts: []string{name + ".example.com"}}}},
+		}
+	}
+	clientset := fake.NewSimpleClientset(ingress("web"), ingress("api"))
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	rounds := &roundRecorder{}
+	objects := &objectRecorder{}
+	s := &scanner{
+		check: func(ctx context.Context, t target) result {
+			return result{target: t, err: errors.New("connection refused")}
+		},
+		concurrency: 1,
+		policy:      policy{days: 30},
+		report:      func([]result, policy) {},
+		// The per-object summaries go to notifiers that are not round
+		// notifiers.
+		notifiers: []notifier{rounds, objects},
+	}
+	c := newController(s, ingressSource(factory.Extensions().V1beta1().Ingresses(), []int{defaultPort}, false))
+	c.static = []target{{kind: "APIServer", host: "kubernetes.default.svc"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	go c.Run(ctx, 1)
+
+	waitFor := func(what string, condition func() bool) {
+		if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) { return condition(), nil }); err != nil {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+	waitFor("the first round", func() bool { return len(rounds.recorded()) == 1 })
+	if got := rounds.recorded()[0]; len(got.results) != 3 || got.Findings != 3 || got.scope != scopeRound {
+		t.Errorf("expected a round of both ingresses and the static target, got %d results, %d findings", len(got.results), got.Findings)
+	}
+	if got := len(objects.recorded()); got != 3 {
+		t.Errorf("expected a summary per object, got %d", got)
+	}
+
+	// Objects checked outside of a round are not summarized as one.
+	if _, err := clientset.ExtensionsV1beta1().Ingresses("shop").Create(ingress("admin")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the new ingress to be checked", func() bool { return len(objects.recorded()) == 4 })
+	if got := len(rounds.recorded()); got != 1 {
+		t.Errorf("expected the check of a single object not to be a round, got %d rounds", got)
+	}
+
+	c.startRound(ctx)
+	waitFor("the second round", func() bool { return len(rounds.recorded()) == 2 })
+	if got := rounds.recorded()[1]; len(got.results) != 4 {
+		t.Errorf("expected a round of every ingress and the static target, got %d results", len(got.results))
+	}
+
+	// Deleted objects are summarized without results.
+	if err := clientset.ExtensionsV1beta1().Ingresses("shop").Delete("web", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the deleted ingress to be forgotten", func() bool { return len(objects.recorded()) == 9 })
+	got := objects.recorded()[8]
+	if want := (objectKey{kind: ingressKind, namespace: "shop", object: "web"}); got.object == nil || *got.object != want || len(got.results) != 0 {
+		t.Errorf("expected the summary of %v without results, got %v with %d results", want, got.object, len(got.results))
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// controlPlaneKind is the kind of the targets of control plane endpoints.
+const controlPlaneKind = "ControlPlane"
+
+// controlPlaneTargets returns the target of the API server config talks to,
+// verified against the certificate authority of config, followed by one for
+// every extra endpoint, given as [name=]host:port. Extra endpoints are
+// verified against -ca-file and -ca-dir; etcd and the other components
+// usually have their own certificate authority.
+func controlPlaneTargets(config *rest.Config, endpoints []string) ([]target, error) {
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API server URL %q: %v", config.Host, err)
+	}
+	apiserver := target{kind: controlPlaneKind, object: "apiserver", host: u.Hostname(), port: defaultPort}
+	if p := u.Port(); p != "" {
+		if apiserver.port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid API server URL %q: %v", config.Host, err)
+		}
+	}
+	if config.ServerName != "" {
+		apiserver.address = net.JoinHostPort(apiserver.host, strconv.Itoa(apiserver.port))
+		apiserver.host = config.ServerName
+	}
+	if apiserver.rootsPEM, err = clusterCA(config); err != nil {
+		return nil, err
+	}
+	targets := []target{apiserver}
+
+	for _, endpoint := range endpoints {
+		name := endpoint
+		if i := strings.Index(endpoint, "="); i >= 0 {
+			name, endpoint = endpoint[:i], endpoint[i+1:]
+		}
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid control plane endpoint %q: %v", endpoint, err)
+		}
+		t := target{kind: controlPlaneKind, object: name, host: host}
+		if t.port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid control plane endpoint %q: %v", endpoint, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// clusterCA returns the PEM encoded certificate authority config trusts for
+// the API server, if any.
+func clusterCA(config *rest.Config) ([]byte, error) {
+	if len(config.CAData) == 0 && config.CAFile != "" {
+		return ioutil.ReadFile(config.CAFile)
+	}
+	return config.CAData, nil
+}
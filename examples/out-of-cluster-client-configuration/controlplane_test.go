@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestControlPlaneTargets(t *testing.T) {
+	config := &rest.Config{Host: "https://10.0.0.1:6443"}
+	config.CAData = []byte("CA")
+	targets, err := controlPlaneTargets(config, []string{"etcd=10.0.0.1:2379", "10.0.0.1:10259"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.object+" "+target.name())
+	}
+	want := []string{"apiserver 10.0.0.1:6443", "etcd 10.0.0.1:2379", "10.0.0.1:10259 10.0.0.1:10259"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if string(targets[0].rootsPEM) != "CA" || targets[1].rootsPEM != nil {
+		t.Errorf("expected only the API server to be verified against the CA of the kubeconfig, got %+v", targets)
+	}
+
+	if _, err := controlPlaneTargets(config, []string{"etcd=10.0.0.1"}); err == nil {
+		t.Error("expected an error for an endpoint without a port")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// crdKind is the kind of the targets taken from custom resource definitions.
+const crdKind = "CustomResourceDefinition"
+
+// apiExtensionsGroup is the API group of custom resource definitions.
+const apiExtensionsGroup = "apiextensions.k8s.io"
+
+// crdVersions are the versions of the API extensions API the checker reads,
+// most preferred first.
+var crdVersions = []string{"v1", "v1beta1"}
+
+// crdSource returns a source checking the conversion webhooks of the custom
+// resource definitions served by informer. With dial the conversion service
+// itself is checked as well.
+func crdSource(informer cache.SharedIndexInformer, dial bool) *source {
+	return &source{
+		kind:     crdKind,
+		informer: informer,
+		targets: func(obj interface{}) []target {
+			return crdTargets(obj.(*unstructured.Unstructured), dial)
+		},
+		changed: func(old, new interface{}) bool {
+			oldConversion, _, _ := unstructured.NestedFieldNoCopy(old.(*unstructured.Unstructured).Object, "spec", "conversion")
+			newConversion, _, _ := unstructured.NestedFieldNoCopy(new.(*unstructured.Unstructured).Object, "spec", "conversion")
+			return !reflect.DeepEqual(oldConversion, newConversion)
+		},
+	}
+}
+
+// crdTargets returns the target of the CA bundle of the conversion webhook of
+// crd, if it has one. With dial it also returns a target for the webhook
+// itself, verified against that CA bundle.
+func crdTargets(crd *unstructured.Unstructured, dial bool) []target {
+	if strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy"); strategy != "Webhook" {
+		return nil
+	}
+	// v1 nests the client configuration under webhook, v1beta1 does not.
+	clientConfig, ok, _ := unstructured.NestedMap(crd.Object, "spec", "conversion", "webhook", "clientConfig")
+	if !ok {
+		clientConfig, ok, _ = unstructured.NestedMap(crd.Object, "spec", "conversion", "webhookClientConfig")
+	}
+	if !ok {
+		return nil
+	}
+
+	var targets []target
+	bundle, _, _ := unstructured.NestedString(clientConfig, "caBundle")
+	t, hasBundle := caBundleTarget(crdKind, crd, crd.GetName(), bundle)
+	if hasBundle {
+		targets = append(targets, t)
+	}
+	if !dial {
+		return targets
+	}
+
+	webhook := target{kind: crdKind, object: crd.GetName(), ref: objectReference(crd.GetAPIVersion(), crd.GetKind(), crd)}
+	if hasBundle {
+		webhook.rootsPEM = t.certificatePEM
+	}
+	if s, ok, _ := unstructured.NestedString(clientConfig, "url"); ok {
+		u, err := url.Parse(s)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid conversion webhook URL", "kind", crdKind, "object", klog.KObj(crd))
+			return targets
+		}
+		webhook.host = u.Hostname()
+		webhook.port = defaultPort
+		if p := u.Port(); p != "" {
+			webhook.port, _ = strconv.Atoi(p)
+		}
+	} else {
+		namespace, _, _ := unstructured.NestedString(clientConfig, "service", "namespace")
+		name, _, _ := unstructured.NestedString(clientConfig, "service", "name")
+		port, ok, _ := unstructured.NestedInt64(clientConfig, "service", "port")
+		if !ok {
+			port = defaultPort
+		}
+		// The API server sends the DNS name of the service as SNI and
+		// verifies the certificate for it.
+		webhook.host = name + "." + namespace + ".svc"
+		webhook.port = int(port)
+	}
+	if webhook.host != "" {
+		targets = append(targets, webhook)
+	}
+	return targets
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCRDTargets(t *testing.T) {
+	bundle := base64.StdEncoding.EncodeToString([]byte("CA"))
+	v1 := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "widgets.example.com"},
+		"spec": map[string]interface{}{
+			"conversion": map[string]interface{}{
+				"strategy": "Webhook",
+				"webhook": map[string]interface{}{
+					"clientConfig": map[string]interface{}{
+						"caBundle": bundle,
+						"service":  map[string]interface{}{"namespace": "widgets", "name": "converter", "port": int64(8443)},
+					},
+				},
+			},
+		},
+	}}
+	v1beta1 := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "gadgets.example.com"},
+		"spec": map[string]interface{}{
+			"conversion": map[string]interface{}{
+				"strategy": "Webhook",
+				"webhookClientConfig": map[string]interface{}{
+					"url": "https://convert.example.com/gadgets",
+				},
+			},
+		},
+	}}
+	none := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "things.example.com"},
+		"spec":     map[string]interface{}{"conversion": map[string]interface{}{"strategy": "None"}},
+	}}
+
+	targets := crdTargets(v1, false)
+	if len(targets) != 1 || !targets[0].caBundle || string(targets[0].certificatePEM) != "CA" {
+		t.Errorf("expected the CA bundle only, got %+v", targets)
+	}
+	targets = crdTargets(v1, true)
+	if len(targets) != 2 || targets[1].name() != "converter.widgets.svc:8443" || string(targets[1].rootsPEM) != "CA" || targets[1].caBundle {
+		t.Errorf("expected the CA bundle and the conversion service, got %+v", targets)
+	}
+	targets = crdTargets(v1beta1, true)
+	if len(targets) != 1 || targets[0].name() != "convert.example.com" || targets[0].rootsPEM != nil {
+		t.Errorf("expected the conversion webhook URL, got %+v", targets)
+	}
+	if targets := crdTargets(none, true); len(targets) != 0 {
+		t.Errorf("expected no target without a conversion webhook, got %+v", targets)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+// crdTarget is a -crd-target: a resource and the JSONPath of the certificates
+// stored in its objects.
+type crdTarget struct {
+	resource schema.GroupVersionResource
+	// path is the JSONPath as set, braces included.
+	path string
+
+	// mu guards jsonPath, which keeps state while evaluated.
+	mu       sync.Mutex
+	jsonPath *jsonpath.JSONPath
+}
+
+// parseCRDTarget parses a -crd-target, group/version/resource:jsonpath, e.g.
+// vault.example.com/v1/certificates:{.status.certificate}. The group is left
+// out for the core API group, and braces may be left out of the JSONPath as
+// with kubectl.
+func parseCRDTarget(s string) (*crdTarget, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid -crd-target %q: expected group/version/resource:jsonpath", s)
+	}
+	parts := strings.Split(s[:i], "/")
+	var resource schema.GroupVersionResource
+	switch len(parts) {
+	case 2:
+		resource = schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+	case 3:
+		resource = schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	}
+	if resource.Version == "" || resource.Resource == "" {
+		return nil, fmt.Errorf("invalid -crd-target %q: expected group/version/resource:jsonpath", s)
+	}
+	path := strings.TrimSpace(s[i+1:])
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	j := jsonpath.New(s).AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return nil, fmt.Errorf("invalid -crd-target %q: %v", s, err)
+	}
+	return &crdTarget{resource: resource, path: path, jsonPath: j}, nil
+}
+
+// values returns the strings found at the path of t in obj.
+func (t *crdTarget) values(obj *unstructured.Unstructured) ([]string, error) {
+	t.mu.Lock()
+	results, err := t.jsonPath.FindResults(obj.Object)
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			if v.Kind() == reflect.String && v.String() != "" {
+				values = append(values, v.String())
+			}
+		}
+	}
+	return values, nil
+}
+
+// crdTargetSource returns a source checking the certificates found at the
+// paths of targets in the objects of kind served by informer, all of the same
+// resource.
+func crdTargetSource(informer cache.SharedIndexInformer, kind string, targets []*crdTarget) *source {
+	return &source{
+		kind:       targets[0].resource.GroupResource().String(),
+		objectKind: kind,
+		informer:   informer,
+		targets: func(obj interface{}) []target {
+			return crdTargetTargets(obj.(*unstructured.Unstructured), targets)
+		},
+		changed: func(old, new interface{}) bool {
+			for _, t := range targets {
+				oldValues, _ := t.values(old.(*unstructured.Unstructured))
+				newValues, _ := t.values(new.(*unstructured.Unstructured))
+				if !reflect.DeepEqual(oldValues, newValues) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// crdTargetTargets returns a target for every certificate found at the paths
+// of targets in obj, named after the path and its index when the path has
+// several. Certificates are never dialed: like CA bundles, what is found at
+// a path is reported by the certificate expiring first.
+func crdTargetTargets(obj *unstructured.Unstructured, targets []*crdTarget) []target {
+	var found []target
+	for _, t := range targets {
+		values, err := t.values(obj)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid JSONPath", "kind", obj.GetKind(), "object", klog.KObj(obj), "path", t.path)
+			continue
+		}
+		for i, value := range values {
+			data, err := certificateMaterial(value)
+			if err != nil {
+				klog.ErrorS(err, "Ignoring invalid certificate", "kind", obj.GetKind(), "object", klog.KObj(obj), "path", t.path)
+				continue
+			}
+			host := t.path
+			if len(values) > 1 {
+				host = fmt.Sprintf("%s[%d]", t.path, i)
+			}
+			found = append(found, target{
+				namespace:      obj.GetNamespace(),
+				kind:           obj.GetKind(),
+				object:         obj.GetName(),
+				host:           host,
+				certificatePEM: data,
+				caBundle:       true,
+				ref:            objectReference(obj.GetAPIVersion(), obj.GetKind(), obj),
+			})
+		}
+	}
+	return found
+}
+
+// certificateMaterial returns value as PEM: value may be PEM already, or PEM
+// or DER encoded in base64.
+func certificateMaterial(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("neither PEM nor base64")
+	}
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		return data, nil
+	}
+	if _, err := x509.ParseCertificate(data); err != nil {
+		return nil, fmt.Errorf("neither PEM nor a DER certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data}), nil
+}
+
+// apiResource returns the served API resource of resource, e.g. to tell
+// whether it is namespaced.
+func apiResource(client discovery.DiscoveryInterface, resource schema.GroupVersionResource) (metav1.APIResource, error) {
+	resources, err := client.ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if err != nil {
+		return metav1.APIResource{}, fmt.Errorf("%s is not served: %v", resource.GroupVersion(), err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource.Resource {
+			return r, nil
+		}
+	}
+	return metav1.APIResource{}, fmt.Errorf("%s is not served by %s", resource.Resource, resource.GroupVersion())
+}
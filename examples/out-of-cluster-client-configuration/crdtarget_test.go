@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseCRDTarget(t *testing.T) {
+	tests := []struct {
+		flag     string
+		resource schema.GroupVersionResource
+		path     string
+	}{
+		{"vault.example.com/v1/certificates:{.status.certificate}", schema.GroupVersionResource{Group: "vault.example.com", Version: "v1", Resource: "certificates"}, "{.status.certificate}"},
+		{"v1/configmaps:.data.ca\\.crt", schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "{.data.ca\\.crt}"},
+	}
+	for _, test := range tests {
+		target, err := parseCRDTarget(test.flag)
+		if err != nil {
+			t.Errorf("%s: %v", test.flag, err)
+			continue
+		}
+		if target.resource != test.resource || target.path != test.path {
+			t.Errorf("%s: expected %v at %s, got %v at %s", test.flag, test.resource, test.path, target.resource, target.path)
+		}
+	}
+	for _, flag := range []string{"certificates", "v1:{.spec}", "a/b/c/d:{.spec}", "v1/configmaps:{.data[}"} {
+		if _, err := parseCRDTarget(flag); err == nil {
+			t.Errorf("%s: expected an error", flag)
+		}
+	}
+}
+
+func TestCRDTargetTargets(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Mesh CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, "identity.linkerd", false, ca, caKey)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "mesh.example.com/v1",
+		"kind":       "Identity",
+		"metadata":   map[string]interface{}{"namespace": "mesh", "name": "identity"},
+		"spec": map[string]interface{}{
+			"trustAnchors": []interface{}{
+				string(caPEM),
+				base64.StdEncoding.EncodeToString(caPEM),
+				"not a certificate",
+			},
+		},
+		"status": map[string]interface{}{"certificate": base64.StdEncoding.EncodeToString(leaf.Raw)},
+	}}
+	anchors, err := parseCRDTarget("mesh.example.com/v1/identities:{.spec.trustAnchors[*]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := parseCRDTarget("mesh.example.com/v1/identities:{.status.certificate}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := parseCRDTarget("mesh.example.com/v1/identities:{.status.missing}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := crdTargetTargets(obj, []*crdTarget{anchors, status, missing})
+	expected := []string{"{.spec.trustAnchors[*]}[0]", "{.spec.trustAnchors[*]}[1]", "{.status.certificate}"}
+	if len(targets) != len(expected) {
+		t.Fatalf("expected %d targets, got %+v", len(expected), targets)
+	}
+	check := caBundleChecker(func(ctx context.Context, t target) result {
+		return result{target: t}
+	})
+	for i, target := range targets {
+		if target.name() != expected[i] || target.kind != "Identity" || target.namespace != "mesh" || target.object != "identity" {
+			t.Errorf("unexpected target %+v", target)
+		}
+		r := check(context.Background(), target)
+		if r.err != nil || r.certificate == nil {
+			t.Errorf("%s: expected a certificate, got %v", target.name(), r.err)
+		}
+	}
+	if r := check(context.Background(), targets[2]); r.certificate == nil || r.certificate.CommonName != "identity.linkerd" {
+		t.Errorf("expected the DER certificate of the status, got %+v", r.certificate)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// maxCRLSize bounds how much of a CRL is downloaded.
+	maxCRLSize = 32 << 20
+	// crlMinTTL is how long a CRL without a next update is kept.
+	crlMinTTL = time.Hour
+	// crlFailureTTL is how long a CRL that could not be downloaded is not
+	// downloaded again, its error being returned instead.
+	crlFailureTTL = time.Minute
+)
+
+// crlCache downloads certificate revocation lists and keeps them, in memory
+// and optionally on disk, until their next update is due. Large scans
+// usually see only a handful of issuers, so every CRL is only downloaded once.
+type crlCache struct {
+	// dir, if set, is where downloaded CRLs are stored across runs.
+	dir    string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*crlEntry
+}
+
+type crlEntry struct {
+	// mu is held while the CRL is downloaded, so that concurrent checks of
+	// certificates of the same issuer wait for a single download.
+	mu   sync.Mutex
+	list *pkix.CertificateList
+	// err is why the CRL could not be downloaded.
+	err error
+	// expires is when list, or err, is no longer used.
+	expires time.Time
+}
+
+func newCRLCache(dir string, client *http.Client) *crlCache {
+	return &crlCache{dir: dir, client: client, now: time.Now, entries: map[string]*crlEntry{}}
+}
+
+// crlExpiry returns when list, fetched at fetched, is no longer used: its
+// next update, or crlMinTTL later if it has none.
+func crlExpiry(list *pkix.CertificateList, fetched time.Time) time.Time {
+	if next := list.TBSCertList.NextUpdate; !next.IsZero() {
+		return next
+	}
+	return fetched.Add(crlMinTTL)
+}
+
+// revocation returns the revocation status of leaf according to the first
+// CRL distribution point it lists, or nil if it lists none.
+func (c *crlCache) revocation(ctx context.Context, leaf, issuer *x509.Certificate) *revocation {
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return nil
+	}
+	url := leaf.CRLDistributionPoints[0]
+	list, err := c.get(ctx, url, issuer)
+	if err != nil {
+		return &revocation{Status: revocationUnknown, Source: url, Error: err.Error()}
+	}
+	for _, revoked := range list.TBSCertList.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			revokedAt := revoked.RevocationTime
+			return &revocation{Status: revocationRevoked, Source: url, RevokedAt: &revokedAt}
+		}
+	}
+	return &revocation{Status: revocationGood, Source: url}
+}
+
+// get returns the CRL at url signed by issuer, downloading it if it is not
+// cached or its next update is due. Failures are returned again for
+// crlFailureTTL.
+func (c *crlCache) get(ctx context.Context, url string, issuer *x509.Certificate) (*pkix.CertificateList, error) {
+	key := crlCacheKey(url, issuer)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &crlEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	now := c.now()
+	if now.Before(entry.expires) {
+		return entry.list, entry.err
+	}
+	if list, stored, err := c.load(key, issuer); err == nil {
+		if expires := crlExpiry(list, stored); now.Before(expires) {
+			entry.list, entry.err, entry.expires = list, nil, expires
+			return list, nil
+		}
+	}
+
+	list, err := c.fetch(ctx, url, issuer)
+	if err != nil {
+		// Downloads cut short by ctx say nothing about the CRL.
+		if ctx.Err() == nil {
+			entry.list, entry.err, entry.expires = nil, err, now.Add(crlFailureTTL)
+		}
+		return nil, err
+	}
+	entry.list, entry.err, entry.expires = list, nil, crlExpiry(list, now)
+	return list, nil
+}
+
+// fetch downloads the CRL at url signed by issuer and stores it in the cache
+// directory.
+func (c *crlCache) fetch(ctx context.Context, url string, issuer *x509.Certificate) (*pkix.CertificateList, error) {
+	raw, err := c.download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseCRL(raw, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("CRL %s: %v", url, err)
+	}
+	c.store(crlCacheKey(url, issuer), raw)
+	return list, nil
+}
+
+// crlCacheKey identifies the CRL at url of issuer.
+func crlCacheKey(url string, issuer *x509.Certificate) string {
+	sum := sha256.Sum256(append(append([]byte{}, issuer.Raw...), url...))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseCRL(raw []byte, issuer *x509.Certificate) (*pkix.CertificateList, error) {
+	list, err := x509.ParseCRL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := issuer.CheckCRLSignature(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *crlCache) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+}
+
+// load reads the CRL stored under key from the cache directory, and returns
+// when it was stored.
+func (c *crlCache) load(key string, issuer *x509.Certificate) (*pkix.CertificateList, time.Time, error) {
+	if c.dir == "" {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	file := filepath.Join(c.dir, key+".crl")
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	list, err := parseCRL(raw, issuer)
+	return list, info.ModTime(), err
+}
+
+// store writes raw to the cache directory. Failing to do so only costs a
+// download on the next run, so errors are ignored.
+func (c *crlCache) store(key string, raw []byte) {
+	if c.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	tmp := filepath.Join(c.dir, key+".crl.tmp")
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, filepath.Join(c.dir, key+".crl"))
+}
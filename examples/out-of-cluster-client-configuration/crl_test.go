@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCRLCache(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	good, _ := newTestCertificate(t, "good.example.com", false, ca, caKey)
+	revoked, _ := newTestCertificate(t, "revoked.example.com", false, ca, caKey)
+
+	crl, err := ca.CreateCRL(rand.Reader, caKey, []pkix.RevokedCertificate{
+		{SerialNumber: revoked.SerialNumber, RevocationTime: time.Now().Add(-time.Hour)},
+	}, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		downloads++
+		mu.Unlock()
+		w.Write(crl)
+	}))
+	defer server.Close()
+	good.CRLDistributionPoints = []string{server.URL}
+	revoked.CRLDistributionPoints = []string{server.URL}
+
+	dir, err := ioutil.TempDir("", "crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := newCRLCache(dir, server.Client())
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := cache.revocation(context.Background(), good, ca); r == nil || r.Status != revocationGood {
+				t.Errorf("expected good, got %+v", r)
+			}
+			if r := cache.revocation(context.Background(), revoked, ca); r == nil || r.Status != revocationRevoked {
+				t.Errorf("expected revoked, got %+v", r)
+			}
+		}()
+	}
+	wg.Wait()
+	if downloads != 1 {
+		t.Errorf("expected a single download, got %d", downloads)
+	}
+
+	// A new cache, as in the next run, finds the CRL on disk.
+	cache = newCRLCache(dir, server.Client())
+	if r := cache.revocation(context.Background(), revoked, ca); r == nil || r.Status != revocationRevoked {
+		t.Errorf("expected revoked, got %+v", r)
+	}
+	if downloads != 1 {
+		t.Errorf("expected the CRL to be read from disk, got %d downloads", downloads)
+	}
+
+	// A CRL signed by someone else is not trusted.
+	other, _ := newTestCertificate(t, "Other CA", true, nil, nil)
+	if r := newCRLCache("", server.Client()).revocation(context.Background(), good, other); r == nil || r.Status != revocationUnknown {
+		t.Errorf("expected unknown, got %+v", r)
+	}
+}
+
+func TestCRLCacheTTL(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, "shop.example.com", false, ca, caKey)
+
+	// A CRL without a next update.
+	crl, err := ca.CreateCRL(rand.Reader, caKey, nil, time.Now(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		downloads[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/broken.crl" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(crl)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	cache := newCRLCache("", server.Client())
+	cache.now = func() time.Time { return now }
+	check := func(url string, expected string, downloaded int) {
+		t.Helper()
+		leaf.CRLDistributionPoints = []string{server.URL + url}
+		if r := cache.revocation(context.Background(), leaf, ca); r == nil || r.Status != expected {
+			t.Errorf("%s: expected %s, got %+v", url, expected, r)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if downloads[url] != downloaded {
+			t.Errorf("%s: expected %d downloads, got %d", url, downloaded, downloads[url])
+		}
+	}
+
+	check("/ca.crl", revocationGood, 1)
+	check("/ca.crl", revocationGood, 1)
+	check("/broken.crl", revocationUnknown, 1)
+	check("/broken.crl", revocationUnknown, 1)
+
+	now = now.Add(crlFailureTTL)
+	check("/ca.crl", revocationGood, 1)
+	check("/broken.crl", revocationUnknown, 2)
+
+	now = now.Add(crlMinTTL)
+	check("/ca.crl", revocationGood, 2)
+}
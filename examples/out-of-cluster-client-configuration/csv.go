@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// csvColumns is the header of the CSV report. Columns are only ever added
+// at the end, so that imports keep working.
+var csvColumns = []string{"namespace", "kind", "object", "host", "subject", "issuer", "notAfter", "daysRemaining", "algorithm", "severity", "error", "tlsVersion", "cipherSuite", "coverage", "publicKey", "cluster", "change", "grade", "failure", "resolved"}
+
+// csvReporter returns a reporter writing the results of every scan to w as
+// CSV.
+func csvReporter(w io.Writer) reporter {
+	return func(results []result, p policy) {
+		if err := writeCSVReport(w, results, p, time.Now()); err != nil {
+			klog.ErrorS(err, "Writing the CSV report failed")
+		}
+	}
+}
+
+// writeCSVReport writes a header and a record per result to w.
+func writeCSVReport(w io.Writer, results []result, p policy, now time.Time) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write(csvRecord(r, p, now)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRecord returns the values of the csvColumns of r. The columns of hosts
+// without a certificate are left empty.
+func csvRecord(r result, p policy, now time.Time) []string {
+	sev, grade := p.grade(r, now)
+	record := []string{r.namespace, r.kind, r.object, r.name(), "", "", "", "", "", sev.String(), "", "", "", "", "", r.cluster, r.change, grade, r.failure(), strings.Join(r.resolved, " ")}
+	if c := r.certificate; c != nil {
+		record[4] = c.CommonName
+		record[5] = c.IssuerCommonName
+		record[6] = c.NotAfter.UTC().Format(time.RFC3339)
+		record[7] = strconv.Itoa(int(c.NotAfter.Sub(now).Hours() / 24))
+		record[8] = c.Algorithm
+		record[13] = c.Coverage
+		record[14] = c.PublicKey.String()
+		if c.Protocol != nil {
+			record[11] = c.Protocol.Version
+			record[12] = c.Protocol.CipherSuite
+		}
+	}
+	if r.err != nil {
+		record[10] = r.err.Error()
+	}
+	return record
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWriteCSVReport(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}, certificate: &certificate{
+			CommonName:       "shop.example.com",
+			IssuerCommonName: "Let's Encrypt Authority X3",
+			NotAfter:         now.AddDate(0, 0, 3),
+			Algorithm:        "SHA256-RSA",
+			Coverage:         coverageExact,
+			PublicKey:        publicKey{Algorithm: "RSA", Bits: 2048},
+			Protocol:         &protocol{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256"},
+		}},
+		{target: target{cluster: "prod", namespace: "shop", kind: ingressKind, object: "web", host: "api.example.com", port: 8443}, resolved: []string{"192.0.2.1", "2001:db8::1"}, err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused, twice")}},
+	}
+	var b bytes.Buffer
+	if err := writeCSVReport(&b, results, policy{days: 30}, now); err != nil {
+		t.Fatal(err)
+	}
+	expected := `namespace,kind,object,host,subject,issuer,notAfter,daysRemaining,algorithm,severity,error,tlsVersion,cipherSuite,coverage,publicKey,cluster,change,grade,failure,resolved
+shop,Ingress,web,shop.example.com,shop.example.com,Let's Encrypt Authority X3,2019-10-17T00:00:00Z,3,SHA256-RSA,WARNING,,TLS 1.3,TLS_AES_128_GCM_SHA256,exact,RSA 2048 bits,,,B,,
+shop,Ingress,web,api.example.com:8443,,,,,,ERROR,"dial tcp: connection refused, twice",,,,,prod,,F,connect,192.0.2.1 2001:db8::1
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
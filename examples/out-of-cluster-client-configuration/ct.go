@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// sctListExtension and ocspSCTListExtension are the extensions of
+// certificates and of OCSP responses carrying signed certificate timestamps,
+// see RFC 6962.
+var (
+	sctListExtension     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	ocspSCTListExtension = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+)
+
+// transparency tells how a served certificate was logged to Certificate
+// Transparency: the signed certificate timestamps (SCTs) delivered in each
+// way. Their signatures are not verified against the keys of the logs.
+type transparency struct {
+	// Embedded, TLS and OCSP count the well-formed SCTs embedded in the
+	// certificate, sent in the TLS extension and in the stapled OCSP
+	// response.
+	Embedded int `json:"embedded,omitempty"`
+	TLS      int `json:"tls,omitempty"`
+	OCSP     int `json:"ocsp,omitempty"`
+	// Invalid counts the SCTs that could not be parsed or are dated in the
+	// future.
+	Invalid int `json:"invalid,omitempty"`
+	// Missing is set for publicly trusted certificates without any SCT,
+	// which browsers reject.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// checkTransparency returns the SCTs of the leaf certificate of state. A
+// publicly trusted certificate without any is missing from CT.
+func checkTransparency(state tls.ConnectionState, publiclyTrusted bool, now time.Time) *transparency {
+	t := &transparency{}
+	leaf := state.PeerCertificates[0]
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(sctListExtension) {
+			t.Embedded = t.count(unwrapSCTList(ext.Value), now)
+		}
+	}
+	for _, sct := range state.SignedCertificateTimestamps {
+		t.TLS += t.count([][]byte{sct}, now)
+	}
+	if len(state.OCSPResponse) > 0 {
+		if resp, err := ocsp.ParseResponse(state.OCSPResponse, nil); err == nil {
+			for _, ext := range resp.Extensions {
+				if ext.Id.Equal(ocspSCTListExtension) {
+					t.OCSP = t.count(unwrapSCTList(ext.Value), now)
+				}
+			}
+		}
+	}
+	t.Missing = publiclyTrusted && t.Embedded+t.TLS+t.OCSP == 0
+	return t
+}
+
+// count returns how many of scts are valid, adding the others to Invalid.
+// A nil list is a list that could not be parsed.
+func (t *transparency) count(scts [][]byte, now time.Time) int {
+	if scts == nil {
+		t.Invalid++
+		return 0
+	}
+	valid := 0
+	for _, sct := range scts {
+		timestamp, err := parseSCT(sct)
+		if err != nil || timestamp.After(now) {
+			t.Invalid++
+			continue
+		}
+		valid++
+	}
+	return valid
+}
+
+// unwrapSCTList returns the SCTs of the value of an SCT list extension, an
+// OCTET STRING holding a SignedCertificateTimestampList, or nil if it is
+// malformed.
+func unwrapSCTList(value []byte) [][]byte {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) > 0 {
+		return nil
+	}
+	if len(list) < 2 || int(binary.BigEndian.Uint16(list)) != len(list)-2 {
+		return nil
+	}
+	scts := [][]byte{}
+	for list = list[2:]; len(list) > 0; {
+		if len(list) < 2 {
+			return nil
+		}
+		n := int(binary.BigEndian.Uint16(list))
+		if len(list) < 2+n {
+			return nil
+		}
+		scts = append(scts, list[2:2+n])
+		list = list[2+n:]
+	}
+	return scts
+}
+
+// errMalformedSCT is the error of SCTs that cannot be parsed.
+var errMalformedSCT = errors.New("malformed signed certificate timestamp")
+
+// parseSCT parses a v1 SCT and returns its timestamp:
+//
+//	version (1) | log id (32) | timestamp (8) | extensions (2 + n) | hash and signature algorithms (2) | signature (2 + n)
+func parseSCT(sct []byte) (time.Time, error) {
+	if len(sct) < 1+32+8+2 || sct[0] != 0 {
+		return time.Time{}, errMalformedSCT
+	}
+	ms := binary.BigEndian.Uint64(sct[33:41])
+	rest := sct[41:]
+	n := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+n+2+2 {
+		return time.Time{}, errMalformedSCT
+	}
+	rest = rest[2+n+2:]
+	if n = int(binary.BigEndian.Uint16(rest)); n == 0 || len(rest) != 2+n {
+		return time.Time{}, errMalformedSCT
+	}
+	return time.Unix(int64(ms/1000), int64(ms%1000)*int64(time.Millisecond)), nil
+}
+
+// publiclyTrusted reports whether a certificate verified through chains
+// chains up to the system roots, rather than to a private CA.
+func (d *dialer) publiclyTrusted(chains [][]*x509.Certificate) bool {
+	return d.roots == nil && len(chains) > 0
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// newTestSCT returns a v1 SCT dated at.
+func newTestSCT(at time.Time) []byte {
+	sct := make([]byte, 1+32+8)
+	binary.BigEndian.PutUint64(sct[33:], uint64(at.UnixNano()/int64(time.Millisecond)))
+	sct = append(sct, 0, 0)       // no extensions
+	sct = append(sct, 4, 3)       // SHA-256, ECDSA
+	sct = append(sct, 0, 2, 1, 2) // a 2 byte signature
+	return sct
+}
+
+// newTestSCTList returns the value of an SCT list extension holding scts.
+func newTestSCTList(t *testing.T, scts ...[]byte) []byte {
+	var list []byte
+	for _, sct := range scts {
+		list = append(list, byte(len(sct)>>8), byte(len(sct)))
+		list = append(list, sct...)
+	}
+	list = append([]byte{byte(len(list) >> 8), byte(len(list))}, list...)
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestCheckTransparency(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{Extensions: []pkix.Extension{{
+		Id:    sctListExtension,
+		Value: newTestSCTList(t, newTestSCT(now.Add(-time.Hour)), newTestSCT(now.Add(-time.Hour)), newTestSCT(now.Add(time.Hour))),
+	}}}
+	state := tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf},
+		SignedCertificateTimestamps: [][]byte{newTestSCT(now.Add(-time.Minute)), {0, 1, 2}},
+	}
+
+	got := checkTransparency(state, true, now)
+	if expected := (transparency{Embedded: 2, TLS: 1, Invalid: 2}); *got != expected {
+		t.Errorf("expected %+v, got %+v", expected, *got)
+	}
+
+	state = tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+	if got := checkTransparency(state, true, now); !got.Missing {
+		t.Errorf("expected a publicly trusted certificate without SCTs to be missing, got %+v", got)
+	}
+	if got := checkTransparency(state, false, now); got.Missing {
+		t.Errorf("expected certificates of private CAs not to need SCTs, got %+v", got)
+	}
+	severity := policy{days: 30}.severity(result{certificate: &certificate{NotAfter: now.AddDate(1, 0, 0), Transparency: &transparency{Missing: true}}}, now)
+	if severity != severityWarning {
+		t.Errorf("expected a certificate missing from CT to be a warning, got %v", severity)
+	}
+}
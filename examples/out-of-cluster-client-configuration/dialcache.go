@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialCache shares the result of dialing a host among the targets that dial
+// it the same way, so that a host declared by many objects is only dialed
+// once.
+type dialCache struct {
+	dial checker
+	// ttl is how long a result is reused after the check that returned it.
+	// Results are reused for as long as the cache exists when it is
+	// negative, and only while the check is in flight when it is 0.
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[dialKey]*dialEntry
+}
+
+// dialKey is what the result of dialing a target depends on.
+type dialKey struct {
+	host, address, rootsPEM, alpn, protocol string
+	// clientSecret is the namespace/name of the Secret of the client
+	// certificate, if any.
+	clientSecret string
+	port         int
+}
+
+// dialEntry is the result of a check, available once done is closed.
+type dialEntry struct {
+	done    chan struct{}
+	result  result
+	checked time.Time
+}
+
+func newDialCache(dial checker, ttl time.Duration) *dialCache {
+	return &dialCache{dial: dial, ttl: ttl, now: time.Now, entries: map[dialKey]*dialEntry{}}
+}
+
+// check is a checker returning the result of another target with the same
+// key if it is still fresh, or checking t otherwise.
+func (c *dialCache) check(ctx context.Context, t target) result {
+	port := t.port
+	if port == 0 {
+		port = defaultPort
+	}
+	key := dialKey{host: t.host, address: t.address, rootsPEM: string(t.rootsPEM), alpn: strings.Join(t.alpn, ","), protocol: t.protocol, port: port}
+	if t.clientSecret != "" {
+		key.clientSecret = t.namespace + "/" + t.clientSecret
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !c.fresh(e) {
+		ok = false
+	}
+	if !ok {
+		e = &dialEntry{done: make(chan struct{})}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return result{target: t, err: ctx.Err()}
+		}
+		r := e.result
+		// The result is the one of another target, only what the check
+		// found out about the host is kept.
+		t.address, t.port = r.address, r.port
+		r.target = t
+		return r
+	}
+
+	e.result = c.dial(ctx, t)
+	c.mu.Lock()
+	e.checked = c.now()
+	// Results cut short by ctx say nothing about the host.
+	if (c.ttl == 0 || ctx.Err() != nil) && c.entries[key] == e {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(e.done)
+	return e.result
+}
+
+// fresh reports whether the result of e may still be reused. It must be
+// called with mu held.
+func (c *dialCache) fresh(e *dialEntry) bool {
+	select {
+	case <-e.done:
+	default:
+		return true
+	}
+	return c.ttl < 0 || c.now().Sub(e.checked) < c.ttl
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialCache(t *testing.T) {
+	var dials int32
+	dial := func(ctx context.Context, t target) result {
+		atomic.AddInt32(&dials, 1)
+		// Give the other checks of the host the time to wait for this one.
+		time.Sleep(10 * time.Millisecond)
+		t.port = defaultPort
+		return result{target: t, certificate: &certificate{CommonName: t.host}}
+	}
+	targets := []target{
+		{namespace: "a", object: "web", host: "shop.example.com"},
+		{namespace: "b", object: "web", host: "shop.example.com", port: 443},
+		{namespace: "c", object: "api", host: "shop.example.com"},
+		{namespace: "c", object: "api", host: "shop.example.com", port: 8443},
+		{namespace: "d", object: "web", host: "api.example.com"},
+	}
+
+	c := newDialCache(dial, -1)
+	results := checkTargets(context.Background(), targets, len(targets), c.check)
+	if dials != 3 {
+		t.Errorf("expected every host and port to be dialed once, got %d dials", dials)
+	}
+	for i, r := range results {
+		if r.namespace != targets[i].namespace || r.object != targets[i].object || r.certificate == nil || r.certificate.CommonName != targets[i].host {
+			t.Errorf("unexpected result %+v for %+v", r, targets[i])
+		}
+	}
+
+	// Results are only reused within their TTL.
+	now := time.Now()
+	c = newDialCache(dial, time.Minute)
+	c.now = func() time.Time { return now }
+	atomic.StoreInt32(&dials, 0)
+	c.check(context.Background(), targets[0])
+	c.check(context.Background(), targets[1])
+	now = now.Add(2 * time.Minute)
+	c.check(context.Background(), targets[2])
+	if dials != 2 {
+		t.Errorf("expected the host to be dialed again after the TTL, got %d dials", dials)
+	}
+
+	// Without a TTL only checks in flight are shared.
+	c = newDialCache(dial, 0)
+	atomic.StoreInt32(&dials, 0)
+	c.check(context.Background(), targets[0])
+	c.check(context.Background(), targets[1])
+	if dials != 2 {
+		t.Errorf("expected the host to be dialed for every check, got %d dials", dials)
+	}
+}
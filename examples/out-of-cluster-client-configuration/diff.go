@@ -0,0 +1,199 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// The changes of a host since the previous scan. Other changes are the name
+// of the severity the host changed to, in lower case.
+const (
+	changeNew      = "new"
+	changeRenewed  = "renewed"
+	changeResolved = "resolved"
+)
+
+// scanDiff compares every scan with the previous one, kept in file across
+// runs, so that only what changed gets reported.
+type scanDiff struct {
+	file   string
+	policy policy
+	// complete is set if every scan covers every host, so that hosts
+	// missing from a scan are no longer checked. In watch mode only the
+	// hosts of the objects scanned are compared.
+	complete bool
+
+	mu    sync.Mutex
+	hosts map[string]hostState
+}
+
+// hostState is what is kept of a host between scans.
+type hostState struct {
+	Severity    string    `json:"severity"`
+	Fingerprint string    `json:"sha256,omitempty"`
+	NotAfter    time.Time `json:"expires,omitempty"`
+}
+
+// scanState is the content of the file of a scanDiff.
+type scanState struct {
+	Time  time.Time            `json:"time"`
+	Hosts map[string]hostState `json:"hosts"`
+}
+
+// loadScanDiff reads the previous scan from file. Without it, every host of
+// the first scan is new.
+func loadScanDiff(file string, p policy, complete bool) (*scanDiff, error) {
+	d := &scanDiff{file: file, policy: p, complete: complete, hosts: map[string]hostState{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state scanState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid previous scan %s: %v", file, err)
+	}
+	if state.Hosts != nil {
+		d.hosts = state.Hosts
+	}
+	return d, nil
+}
+
+// update sets the change of every result since the previous scan, saves
+// results as the previous scan and returns the ones that changed. Hosts
+// that are no longer checked are logged; the ones an interrupted scan did not
+// check keep their previous state.
+func (d *scanDiff) update(results []result, now time.Time) []result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var changed []result
+	seen := map[string]bool{}
+	objects := map[string]bool{}
+	for i := range results {
+		r := &results[i]
+		key := hostKey(r.target)
+		seen[key] = true
+		objects[objectPrefix(r.target)] = true
+		if _, ok := r.err.(interruptedError); ok {
+			continue
+		}
+		state := hostState{Severity: d.policy.severity(*r, now).String()}
+		if r.certificate != nil {
+			state.Fingerprint, state.NotAfter = r.certificate.Fingerprint, r.certificate.NotAfter
+		}
+		previous, ok := d.hosts[key]
+		switch {
+		case !ok:
+			r.change = changeNew
+		case state.Severity != previous.Severity && state.Severity == severityOK.String():
+			r.change = changeResolved
+		case state.Severity != previous.Severity:
+			r.change = strings.ToLower(state.Severity)
+		case state.Fingerprint != "" && previous.Fingerprint != "" && state.Fingerprint != previous.Fingerprint:
+			r.change = changeRenewed
+		}
+		d.hosts[key] = state
+		if r.change != "" {
+			changed = append(changed, *r)
+		}
+	}
+	for key := range d.hosts {
+		if seen[key] || !d.complete && !objects[objectPrefix(parseHostKey(key))] {
+			continue
+		}
+		klog.InfoS("Host no longer checked", "host", key)
+		delete(d.hosts, key)
+	}
+	if err := d.save(now); err != nil {
+		klog.ErrorS(err, "Saving the scan failed", "file", d.file)
+	}
+	return changed
+}
+
+// save writes the hosts to file, through a temporary file so that an
+// interrupted write does not lose the previous scan.
+func (d *scanDiff) save(now time.Time) error {
+	data, err := json.MarshalIndent(scanState{Time: now, Hosts: d.hosts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(d.file), filepath.Base(d.file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.file)
+}
+
+// hostKey identifies the host of t in the previous scan:
+// cluster/namespace/kind/object/name, every field escaped so that the
+// slashes within them, e.g. of EKS contexts, do not separate fields.
+func hostKey(t target) string {
+	return joinKey(t.cluster, t.namespace, t.kind, t.object, t.name())
+}
+
+// parseHostKey returns the target of key, with its name as host.
+func parseHostKey(key string) target {
+	parts := strings.SplitN(key, "/", 5)
+	for len(parts) < 5 {
+		parts = append(parts, "")
+	}
+	for i := range parts {
+		parts[i] = keyUnescaper.Replace(parts[i])
+	}
+	return target{cluster: parts[0], namespace: parts[1], kind: parts[2], object: parts[3], host: parts[4]}
+}
+
+// keyEscaper escapes the fields of keys, which are left as they are unless
+// they contain a slash or a percent sign, so that the keys of previous scans
+// still match.
+var (
+	keyEscaper   = strings.NewReplacer("%", "%25", "/", "%2F")
+	keyUnescaper = strings.NewReplacer("%25", "%", "%2F", "/")
+)
+
+// joinKey joins fields into a key, escaped.
+func joinKey(fields ...string) string {
+	for i := range fields {
+		fields[i] = keyEscaper.Replace(fields[i])
+	}
+	return strings.Join(fields, "/")
+}
+
+// objectPrefix identifies the object of t.
+func objectPrefix(t target) string {
+	return joinKey(t.cluster, t.namespace, t.kind, t.object)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScanDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "scan.json")
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	p := policy{days: 30}
+	ok := func(host, fingerprint string) result {
+		return result{target: target{namespace: "shop", kind: ingressKind, object: "web", host: host}, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0), Fingerprint: fingerprint}}
+	}
+	changes := func(results []result) map[string]string {
+		m := map[string]string{}
+		for _, r := range results {
+			m[r.host] = r.change
+		}
+		return m
+	}
+
+	d, err := loadScanDiff(file, p, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := []result{ok("shop.example.com", "a"), ok("api.example.com", "b"), ok("old.example.com", "c")}
+	if got := changes(d.update(first, now)); len(got) != 3 || got["shop.example.com"] != changeNew {
+		t.Errorf("expected every host of the first scan to be new, got %v", got)
+	}
+
+	// The next run reads the previous scan back from the file.
+	if d, err = loadScanDiff(file, p, true); err != nil {
+		t.Fatal(err)
+	}
+	down := ok("api.example.com", "")
+	down.certificate, down.err = nil, errors.New("connection refused")
+	second := []result{ok("shop.example.com", "d"), down, ok("www.example.com", "e")}
+	expected := map[string]string{"shop.example.com": changeRenewed, "api.example.com": "error", "www.example.com": changeNew}
+	if got := changes(d.update(second, now)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected changes %v, got %v", expected, got)
+	}
+	if _, ok := d.hosts[hostKey(first[2].target)]; ok {
+		t.Error("expected the host that is no longer checked to be forgotten")
+	}
+
+	third := []result{ok("shop.example.com", "d"), ok("api.example.com", "f"), ok("www.example.com", "e")}
+	if got := changes(d.update(third, now)); !reflect.DeepEqual(got, map[string]string{"api.example.com": changeResolved}) {
+		t.Errorf("expected only api.example.com to be resolved, got %v", got)
+	}
+
+	// A host left unchecked by an interrupted scan keeps its previous state.
+	unchecked := ok("api.example.com", "")
+	unchecked.certificate, unchecked.err = nil, interruptedError{context.Canceled}
+	if got := changes(d.update([]result{ok("shop.example.com", "d"), unchecked, ok("www.example.com", "e")}, now)); len(got) != 0 {
+		t.Errorf("expected no changes, got %v", got)
+	}
+	if state, ok := d.hosts[hostKey(unchecked.target)]; !ok || state.Fingerprint != "f" {
+		t.Errorf("expected api.example.com to keep its previous state, got %+v", state)
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	plain := target{cluster: "prod", namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}
+	if got := hostKey(plain); got != "prod/shop/Ingress/web/shop.example.com" {
+		t.Errorf("expected the keys of previous scans to be kept, got %q", got)
+	}
+	eks := target{cluster: "arn:aws:eks:eu-west-1:123456789012:cluster/prod", namespace: "shop", kind: ingressKind, object: "web", host: "100%.example.com"}
+	if got := parseHostKey(hostKey(eks)); !reflect.DeepEqual(got, eks) {
+		t.Errorf("expected %+v, got %+v", eks, got)
+	}
+	if got := objectPrefix(parseHostKey(hostKey(eks))); got != objectPrefix(eks) {
+		t.Errorf("expected the object of the key to be %q, got %q", objectPrefix(eks), got)
+	}
+}
+
+func TestNotifyAllChanges(t *testing.T) {
+	full := &summary{Findings: 3}
+	full.changes = &summary{Findings: 1}
+	plain := &recordingNotifier{}
+	notifyAll(context.Background(), []notifier{plain}, full)
+	if len(plain.summaries) != 1 || plain.summaries[0].Findings != 1 {
+		t.Errorf("expected notifiers to only be sent the changes, got %+v", plain.summaries)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// servedResource returns the first of candidates served by the server, so
+// that checking a kind of object the cluster does not know about fails the
+// run instead of waiting for an informer that never syncs.
+func servedResource(client discovery.DiscoveryInterface, candidates ...schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	var errs []string
+	for _, candidate := range candidates {
+		resources, err := client.ServerResourcesForGroupVersion(candidate.GroupVersion().String())
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s is not served: %v", candidate.GroupVersion(), err))
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == candidate.Resource {
+				return candidate, nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s is not served by %s", candidate.Resource, candidate.GroupVersion()))
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// resourceVersions returns resource of group in each of versions.
+func resourceVersions(group, resource string, versions ...string) []schema.GroupVersionResource {
+	var resources []schema.GroupVersionResource
+	for _, version := range versions {
+		resources = append(resources, schema.GroupVersionResource{Group: group, Version: version, Resource: resource})
+	}
+	return resources
+}
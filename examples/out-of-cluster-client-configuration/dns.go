@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// The failures of hosts that could not be checked, by the step that failed.
+const (
+	failureDNS     = "dns"
+	failureConnect = "connect"
+	failureTLS     = "tls"
+	failureVerify  = "verify"
+)
+
+// newResolver returns the resolver hosts are looked up with: the one of the
+// system, unless server, the ip:port of a DNS server, is set or it is asked
+// over TCP. over is udp or tcp.
+func newResolver(server, over string) (*net.Resolver, error) {
+	switch over {
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("invalid -dns-over %q: must be udp or tcp", over)
+	}
+	if server != "" {
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			host = server
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid -resolver %q: must be ip[:port]", server)
+		}
+		if err != nil {
+			// The port of DNS is the default.
+			server = net.JoinHostPort(server, "53")
+		}
+	}
+	if server == "" && over == "udp" {
+		return net.DefaultResolver, nil
+	}
+	return &net.Resolver{
+		// Only the resolver of Go dials DNS servers with Dial.
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if server != "" {
+				address = server
+			}
+			if over == "tcp" {
+				network = "tcp"
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}, nil
+}
+
+// resolve returns the addresses of the family of d that host resolves to, in
+// the order they are dialed.
+func (d *dialer) resolve(ctx context.Context, host string) ([]string, error) {
+	resolver := d.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	version := ipVersionBoth
+	switch d.network {
+	case "tcp4":
+		version = ipVersion4
+	case "tcp6":
+		version = ipVersion6
+	}
+	var ips []string
+	for _, addr := range addrs {
+		if version.matches(addr.IP) {
+			ips = append(ips, addr.IP.String())
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no address of the IP version dialed", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// failure returns the step checking the host of r failed at: looking it up,
+// connecting to it, the handshake, or verifying the certificate it served.
+// It returns "" for hosts that were checked, or not at all.
+func (r result) failure() string {
+	if r.err == nil {
+		return ""
+	}
+	if _, ok := r.err.(interruptedError); ok {
+		return ""
+	}
+	if r.certificate != nil {
+		return failureVerify
+	}
+	var dnsErr *net.DNSError
+	if errors.As(r.err, &dnsErr) {
+		return failureDNS
+	}
+	var opErr *net.OpError
+	if errors.As(r.err, &opErr) && opErr.Op == "dial" {
+		return failureConnect
+	}
+	return failureTLS
+}
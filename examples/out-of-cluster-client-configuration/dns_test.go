@@ -0,0 +1,215 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer returns the response to the DNS query q: the address of names for
+// their A records, nothing for the other records of known names, and
+// NXDOMAIN for unknown names.
+func answer(t *testing.T, q []byte, names map[string]net.IP) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(q)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	question, err := p.Question()
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	ip, ok := names[question.Name.String()]
+	h.Response = true
+	if !ok {
+		h.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, h)
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(question)
+	b.StartAnswers()
+	if ok && question.Type == dnsmessage.TypeA {
+		var a dnsmessage.AResource
+		copy(a.A[:], ip.To4())
+		b.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}, a)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Error(err)
+	}
+	return msg
+}
+
+// serveDNSOverTCP answers the queries sent to l over TCP until it is closed.
+func serveDNSOverTCP(t *testing.T, l net.Listener, names map[string]net.IP) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				q := make([]byte, length)
+				if _, err := io.ReadFull(conn, q); err != nil {
+					return
+				}
+				msg := answer(t, q, names)
+				binary.Write(conn, binary.BigEndian, uint16(len(msg)))
+				conn.Write(msg)
+			}
+		}()
+	}
+}
+
+// serveDNSOverUDP answers the queries sent to conn until it is closed.
+func serveDNSOverUDP(t *testing.T, conn net.PacketConn, names map[string]net.IP) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(answer(t, buf[:n], names), addr)
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	r, err := newResolver("", "udp")
+	if err != nil || r != net.DefaultResolver {
+		t.Errorf("expected the resolver of the system, got %v, %v", r, err)
+	}
+	for _, valid := range [][2]string{{"", "tcp"}, {"10.96.0.10", "udp"}, {"10.96.0.10:5353", "tcp"}, {"[fd00::10]:53", "udp"}} {
+		if _, err := newResolver(valid[0], valid[1]); err != nil {
+			t.Errorf("expected -resolver %q -dns-over %q to be valid, got %v", valid[0], valid[1], err)
+		}
+	}
+	for _, invalid := range [][2]string{{"", "tls"}, {"kube-dns", "udp"}, {"kube-dns:53", "udp"}, {"10.96.0.10", ""}} {
+		if _, err := newResolver(invalid[0], invalid[1]); err == nil {
+			t.Errorf("expected -resolver %q -dns-over %q to be invalid", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestDialerResolver(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	names := map[string]net.IP{"shop.example.test.": net.ParseIP("127.0.0.1")}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go serveDNSOverUDP(t, udp, names)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	go serveDNSOverTCP(t, tcp, names)
+
+	for _, test := range []struct {
+		over   string
+		server string
+	}{
+		{over: "udp", server: udp.LocalAddr().String()},
+		{over: "tcp", server: tcp.Addr().String()},
+	} {
+		resolver, err := newResolver(test.server, test.over)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &dialer{timeout: 5 * time.Second, resolver: resolver}
+		p, _ := strconv.Atoi(port)
+
+		r := d.check(context.Background(), target{host: "shop.example.test", port: p})
+		if r.certificate == nil {
+			t.Fatalf("over %s: expected the certificate of the server, got %v", test.over, r.err)
+		}
+		if !reflect.DeepEqual(r.resolved, []string{"127.0.0.1"}) {
+			t.Errorf("over %s: expected the host to resolve to 127.0.0.1, got %v", test.over, r.resolved)
+		}
+		if got := r.failure(); got != failureVerify {
+			t.Errorf("over %s: expected the self-signed certificate to fail verification, got %q", test.over, got)
+		}
+
+		r = d.check(context.Background(), target{host: "missing.example.test", port: p})
+		if got := r.failure(); got != failureDNS {
+			t.Errorf("over %s: expected an unknown host to fail at %s, got %q (%v)", test.over, failureDNS, got, r.err)
+		}
+		if r.attempts != 1 {
+			t.Errorf("over %s: expected an unknown host not to be retried, got %d attempts", test.over, r.attempts)
+		}
+
+		// The dials of addresses are limited by the addresses the resolver
+		// of the dialer returns.
+		if got := d.dialedIPs(context.Background(), "shop.example.test:443"); !reflect.DeepEqual(got, []string{"127.0.0.1"}) {
+			t.Errorf("over %s: expected the host to be dialed at 127.0.0.1, got %v", test.over, got)
+		}
+	}
+
+	d := &dialer{timeout: 5 * time.Second, ips: []string{"10.0.0.1"}}
+	if got := d.dialedIPs(context.Background(), "shop.example.test:443"); !reflect.DeepEqual(got, []string{"10.0.0.1"}) {
+		t.Errorf("expected the addresses the host resolved to, got %v", got)
+	}
+	d.ips = nil
+	if got := d.dialedIPs(context.Background(), "10.0.0.2:443"); !reflect.DeepEqual(got, []string{"10.0.0.2"}) {
+		t.Errorf("expected the address dialed, got %v", got)
+	}
+}
+
+func TestResultFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		result result
+		want   string
+	}{
+		{name: "checked", result: result{certificate: &certificate{}}},
+		{name: "unchecked", result: result{err: interruptedError{}}},
+		{name: "not found", result: result{err: &net.DNSError{Err: "no such host", Name: "shop.example.com", IsNotFound: true}}, want: failureDNS},
+		{name: "not found while dialing", result: result{err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "shop.example.com"}}}, want: failureDNS},
+		{name: "refused", result: result{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, want: failureConnect},
+		{name: "reset", result: result{err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}, want: failureTLS},
+		{name: "handshake", result: result{err: errors.New("tls: handshake failure")}, want: failureTLS},
+		{name: "unverified", result: result{certificate: &certificate{}, err: errors.New("x509: certificate signed by unknown authority")}, want: failureVerify},
+	}
+	for _, test := range tests {
+		if got := test.result.failure(); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// certDumper writes the chain served by every host to a PEM file of its own
+// in dir, so that the exact certificates seen can be inspected afterwards.
+// Dumping is not a notification about findings, hosts that are fine are
+// written too.
+type certDumper struct {
+	dir string
+}
+
+func (d *certDumper) resolvesFindings() {}
+
+func (d *certDumper) notify(ctx context.Context, s *summary) error {
+	var failed int
+	var last error
+	for _, r := range s.results {
+		if r.certificate == nil || len(r.certificate.served) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		for _, crt := range r.certificate.served {
+			pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		}
+		file := filepath.Join(d.dir, dumpFile(r.target))
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(file, buf.Bytes(), 0644)
+		}
+		if err != nil {
+			failed++
+			last = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("writing %d certificate dumps: %v", failed, last)
+	}
+	return nil
+}
+
+// dumpFile returns the path of the PEM file of t within the dump directory:
+// host[_port][_backend].pem, in a directory named after its cluster when
+// several clusters are scanned. Characters that are not safe in file names,
+// such as the * of wildcard hosts, are replaced by underscores.
+func dumpFile(t target) string {
+	name := t.host
+	if t.port != defaultPort && t.port != 0 {
+		name += "_" + strconv.Itoa(t.port)
+	}
+	if t.backend != "" {
+		name += "_" + t.backend
+	}
+	name = sanitizeFileName(name) + ".pem"
+	if t.cluster != "" {
+		return filepath.Join(sanitizeFileName(t.cluster), name)
+	}
+	return name
+}
+
+// sanitizeFileName replaces the characters of s other than letters, digits,
+// dots, dashes and underscores by underscores.
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestCertDumper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root, rootKey := newTestCertificate(t, "Test Root", true, nil, nil)
+	leaf, _ := newTestCertificate(t, "shop.example.com", false, root, rootKey)
+
+	d := &certDumper{dir: dir}
+	s := &summary{results: []result{
+		{target: target{host: "*.example.com", port: 8443}, certificate: &certificate{served: []*x509.Certificate{leaf, root}}},
+		{target: target{cluster: "prod", host: "shop.example.com", port: 443}, certificate: &certificate{served: []*x509.Certificate{leaf}}},
+		{target: target{host: "down.example.com"}, err: errors.New("connection refused")},
+	}}
+	if err := d.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := certutil.CertsFromFile(filepath.Join(dir, "_.example.com_8443.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || !chain[0].Equal(leaf) || !chain[1].Equal(root) {
+		t.Errorf("expected the served chain, got %d certificates", len(chain))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prod", "shop.example.com.pem")); err != nil {
+		t.Errorf("expected the chain of the prod cluster in a directory of its own: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "down.example.com.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected no file for a host without a certificate, got %v", err)
+	}
+}
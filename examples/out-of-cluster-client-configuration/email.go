@@ -0,0 +1,265 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultEmailTextTemplate renders plain text e-mails.
+const defaultEmailTextTemplate = `{{.Findings}} TLS certificates need attention.{{if .Interrupted}} The scan was interrupted, {{.Unchecked}} hosts were not checked.{{end}}
+{{range .Namespaces}}
+Namespace {{with .Cluster}}{{.}}/{{end}}{{.Namespace}}:
+{{range .Findings}}  {{.Host}} ({{.Kind}} {{.Object}}): {{.Severity}}{{if .Error}} {{.Error}}{{else}} expires {{.NotAfter.Format "2006-01-02"}}, in {{.DaysRemaining}} days{{end}}{{with .CertManager}}{{if .RenewalTime}}, renewal scheduled {{.RenewalTime.Format "2006-01-02"}}{{else}}, not renewed by cert-manager{{end}}{{end}}
+{{end}}{{end}}`
+
+// defaultEmailHTMLTemplate renders HTML e-mails.
+const defaultEmailHTMLTemplate = `<html><body>
+<p>{{.Findings}} TLS certificates need attention.{{if .Interrupted}} The scan was interrupted, {{.Unchecked}} hosts were not checked.{{end}}</p>
+{{range .Namespaces}}<h3>Namespace {{with .Cluster}}{{.}}/{{end}}{{.Namespace}}</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Host</th><th>Object</th><th>Severity</th><th>Expires</th><th>Error</th></tr>
+{{range .Findings}}<tr><td>{{.Host}}</td><td>{{.Kind}} {{.Object}}</td><td>{{.Severity}}</td><td>{{if not .Error}}{{.NotAfter.Format "2006-01-02"}} ({{.DaysRemaining}} days){{end}}{{with .CertManager}}{{if .RenewalTime}}<br>renewal scheduled {{.RenewalTime.Format "2006-01-02"}}{{else}}<br>not renewed by cert-manager{{end}}{{end}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>`
+
+// emailNotifier sends summaries through an SMTP server, in watch mode the
+// ones of scan rounds. Every recipient only receives the findings of the
+// namespaces mapped to it.
+type emailNotifier struct {
+	// addr is the host:port of the SMTP server.
+	addr string
+	auth smtp.Auth
+	from string
+	// recipients maps addresses to the namespaces they receive findings
+	// for. A nil list means all namespaces.
+	recipients map[string][]string
+	html       bool
+	template   executor
+	// send is sendMail, replaced in tests.
+	send func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newEmailNotifier(addr, username, password, from string, to []string, format, templateFile string) (*emailNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server %q: %v", addr, err)
+	}
+	if from == "" {
+		return nil, fmt.Errorf("e-mail notifications need a sender address")
+	}
+	recipients, err := parseRecipients(to)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("e-mail notifications need at least one recipient")
+	}
+	if format != "text" && format != "html" {
+		return nil, fmt.Errorf("invalid e-mail format %q: must be text or html", format)
+	}
+	n := &emailNotifier{
+		addr:       addr,
+		from:       from,
+		recipients: recipients,
+		html:       format == "html",
+		send:       sendMail,
+	}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	if n.template, err = loadEmailTemplate(templateFile, n.html); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// parseRecipients parses values of the -notify-email-to flag. Each is either
+// an address, receiving the findings of all namespaces, or
+// namespace=address[,address...].
+func parseRecipients(values []string) (map[string][]string, error) {
+	recipients := map[string][]string{}
+	all := map[string]bool{}
+	for _, v := range values {
+		namespace, addresses := "", v
+		if i := strings.Index(v, "="); i >= 0 {
+			namespace, addresses = v[:i], v[i+1:]
+			if namespace == "" {
+				return nil, fmt.Errorf("invalid recipient %q: empty namespace", v)
+			}
+		}
+		for _, address := range strings.Split(addresses, ",") {
+			address = strings.TrimSpace(address)
+			if address == "" || !strings.Contains(address, "@") {
+				return nil, fmt.Errorf("invalid recipient %q: bad address %q", v, address)
+			}
+			if namespace == "" {
+				all[address] = true
+				continue
+			}
+			recipients[address] = append(recipients[address], namespace)
+		}
+	}
+	for address := range all {
+		recipients[address] = nil
+	}
+	return recipients, nil
+}
+
+func (n *emailNotifier) perRound() {}
+
+func (n *emailNotifier) notify(ctx context.Context, s *summary) error {
+	addresses := make([]string, 0, len(n.recipients))
+	for address := range n.recipients {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var failed []string
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		filtered := s.only(n.recipients[address])
+		if filtered == nil {
+			continue
+		}
+		msg, err := n.message(address, filtered)
+		if err == nil {
+			err = n.send(ctx, n.addr, n.auth, n.from, []string{address}, msg)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", address, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("sending e-mail failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// sendMail is smtp.SendMail bounded by ctx, or by notifyTimeout if it has no
+// deadline: the connection is closed once ctx is done, so that a server that
+// stopped responding does not hang the notification.
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("%s does not support authentication", addr)
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (n *emailNotifier) message(to string, s *summary) ([]byte, error) {
+	body, err := render(n.template, s)
+	if err != nil {
+		return nil, err
+	}
+	contentType := "text/plain"
+	if n.html {
+		contentType = "text/html"
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %d TLS certificates need attention\r\n", s.Findings)
+	fmt.Fprintf(&msg, "Date: %s\r\n", s.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n\r\n", contentType)
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return msg.Bytes(), nil
+}
+
+// loadEmailTemplate parses the e-mail template in file, or the default one for
+// the format when file is empty.
+func loadEmailTemplate(file string, html bool) (executor, error) {
+	if !html {
+		return loadTemplate("email", file, defaultEmailTextTemplate)
+	}
+	if file != "" {
+		return htmltemplate.ParseFiles(file)
+	}
+	return htmltemplate.New("email").Parse(defaultEmailHTMLTemplate)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	n, err := newEmailNotifier("smtp.example.com:25", "", "", "certs@example.com", []string{
+		"ops@example.com",
+		"payments=pay@example.com,pay-oncall@example.com",
+		"search=search@example.com",
+	}, "text", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := map[string]string{}
+	n.send = func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent[to[0]] = string(msg)
+		return nil
+	}
+
+	s := &summary{
+		Time:     time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC),
+		Findings: 2,
+		Namespaces: []namespaceSummary{
+			{Namespace: "payments", Findings: []finding{{Host: "pay.example.com", Severity: "EXPIRED"}}},
+			{Namespace: "shop", Findings: []finding{{Host: "shop.example.com", Severity: "WARNING"}}},
+		},
+	}
+	if err := n.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for to := range sent {
+		got = append(got, to)
+	}
+	want := map[string]bool{"ops@example.com": true, "pay@example.com": true, "pay-oncall@example.com": true}
+	if len(sent) != len(want) {
+		t.Fatalf("expected e-mails to %v, got %v", want, got)
+	}
+	for to, msg := range sent {
+		if !want[to] {
+			t.Errorf("unexpected e-mail to %s", to)
+		}
+		if !strings.Contains(msg, "pay.example.com") {
+			t.Errorf("e-mail to %s misses the payments finding", to)
+		}
+		if to != "ops@example.com" && strings.Contains(msg, "shop.example.com") {
+			t.Errorf("e-mail to %s contains findings of another namespace", to)
+		}
+	}
+	if !strings.Contains(sent["ops@example.com"], "Subject: 2 TLS certificates need attention\r\n") {
+		t.Errorf("unexpected e-mail %q", sent["ops@example.com"])
+	}
+}
+
+func TestSendMail(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := textproto.NewConn(conn)
+		c.PrintfLine("220 localhost")
+		var data string
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				c.PrintfLine("250 localhost")
+			case line == "DATA":
+				c.PrintfLine("354 go ahead")
+				b, _ := c.ReadDotBytes()
+				data = string(b)
+				c.PrintfLine("250 queued")
+			case line == "QUIT":
+				c.PrintfLine("221 bye")
+				received <- data
+				return
+			default:
+				c.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	if err := sendMail(context.Background(), l.Addr().String(), nil, "certs@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; !strings.Contains(got, "body") {
+		t.Errorf("expected the message to be sent, got %q", got)
+	}
+}
+
+func TestSendMailTimeout(t *testing.T) {
+	// The server accepts connections and never greets.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := sendMail(ctx, l.Addr().String(), nil, "certs@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\n")); err == nil {
+		t.Error("expected sending to a server that never replies to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected sending to give up once the context is done, took %v", elapsed)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	got, err := parseRecipients([]string{"a=x@example.com", "b=x@example.com", "y@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"x@example.com": {"a", "b"}, "y@example.com": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, invalid := range []string{"=x@example.com", "a=", "a=x@example.com,", "nobody"} {
+		if _, err := parseRecipients([]string{invalid}); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
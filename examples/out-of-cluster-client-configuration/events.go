@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source of the events recorded by the checker.
+const eventComponent = "cert-check"
+
+// eventReasons are the reasons of the events recorded for each severity.
+var eventReasons = map[string]string{
+	severityWarning.String(): "CertificateExpiring",
+	severityExpired.String(): "CertificateExpired",
+	severityRevoked.String(): "CertificateRevoked",
+	severityError.String():   "CertificateCheckFailed",
+}
+
+// eventNotifier records a Warning Event on the object of every finding, so
+// that it shows up in kubectl describe and in event pipelines. Events are
+// correlated the way an event recorder does: the same event recorded again
+// on the same object only updates the count and the last timestamp of the
+// first one.
+//
+// Events are written synchronously rather than through an event
+// broadcaster, which would drop the events still queued when a one-shot
+// run exits.
+type eventNotifier struct {
+	events     typedcorev1.EventsGetter
+	correlator *record.EventCorrelator
+}
+
+func newEventNotifier(events typedcorev1.EventsGetter) *eventNotifier {
+	return &eventNotifier{events: events, correlator: record.NewEventCorrelator(clock.RealClock{})}
+}
+
+func (n *eventNotifier) notify(ctx context.Context, s *summary) error {
+	var failed int
+	var last error
+	for _, ns := range s.Namespaces {
+		for _, f := range ns.Findings {
+			if f.ref == nil {
+				continue
+			}
+			if err := n.record(ctx, f, s.Time); err != nil {
+				failed++
+				last = err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("recording %d events: %v", failed, last)
+	}
+	return nil
+}
+
+// record records the event of f, found at now.
+func (n *eventNotifier) record(ctx context.Context, f finding, now time.Time) error {
+	event := newEvent(f, now)
+	result, err := n.correlator.EventCorrelate(event)
+	if err != nil {
+		return err
+	}
+	if result.Skip {
+		return nil
+	}
+	events := n.events.Events(result.Event.Namespace)
+	recorded := make(chan *v1.Event, 1)
+	err = callAPI(ctx, func() error {
+		var event *v1.Event
+		var err error
+		if result.Event.Count > 1 {
+			event, err = events.Patch(result.Event.Name, types.StrategicMergePatchType, result.Patch)
+		}
+		if result.Event.Count <= 1 || errors.IsNotFound(err) {
+			result.Event.ResourceVersion = ""
+			event, err = events.Create(result.Event)
+		}
+		if err == nil {
+			recorded <- event
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	n.correlator.UpdateState(<-recorded)
+	return nil
+}
+
+// newEvent returns the event of f, found at now.
+func newEvent(f finding, now time.Time) *v1.Event {
+	message := fmt.Sprintf("%s: certificate expires %s, in %d days", f.Host, f.NotAfter.Format("2006-01-02"), f.DaysRemaining)
+	if f.Error != "" {
+		message = fmt.Sprintf("%s: %s", f.Host, f.Error)
+	}
+	// Events about cluster scoped objects live in the default namespace.
+	namespace := f.ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	t := metav1.NewTime(now)
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", f.ref.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: *f.ref,
+		Reason:         eventReasons[f.Severity],
+		Message:        message,
+		FirstTimestamp: t,
+		LastTimestamp:  t,
+		Count:          1,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: eventComponent},
+	}
+}
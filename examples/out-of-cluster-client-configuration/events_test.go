@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventNotifier(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	n := newEventNotifier(clientset.CoreV1())
+	now := time.Now()
+	ref := &v1.ObjectReference{APIVersion: "extensions/v1beta1", Kind: "Ingress", Namespace: "shop", Name: "web", UID: "1234"}
+	s := &summary{
+		Time:     now,
+		Findings: 2,
+		Namespaces: []namespaceSummary{{Namespace: "shop", Findings: []finding{
+			{Host: "shop.example.com", Severity: severityWarning.String(), NotAfter: now.Add(72 * time.Hour), DaysRemaining: 3, ref: ref},
+			// Not taken from an object, no event.
+			{Host: "10.0.0.1:6443", Severity: severityError.String(), Error: "connection refused"},
+		}}},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := n.notify(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := clientset.CoreV1().Events("shop").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expected a single event, got %+v", events.Items)
+	}
+	e := events.Items[0]
+	if e.InvolvedObject != *ref || e.Type != v1.EventTypeWarning || e.Reason != "CertificateExpiring" || e.Count != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
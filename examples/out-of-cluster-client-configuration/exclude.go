@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"k8s.io/klog/v2"
+)
+
+// exclusion lists the hosts and namespaces that are never checked, for
+// hosts that are known to be bad on purpose, e.g. self-signed or
+// decommissioned ones. Both are shell patterns, e.g. *.staging.example.com.
+type exclusion struct {
+	hosts      []string
+	namespaces []string
+}
+
+// newExclusion returns the exclusion of hosts and namespaces, or nil if both
+// are empty.
+func newExclusion(hosts, namespaces []string) (*exclusion, error) {
+	if len(hosts) == 0 && len(namespaces) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, hosts...), namespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return &exclusion{hosts: hosts, namespaces: namespaces}, nil
+}
+
+// apply returns the targets that are not excluded by e.
+func (e *exclusion) apply(targets []target) []target {
+	if e == nil {
+		return targets
+	}
+	kept := targets[:0:0]
+	for _, t := range targets {
+		if matchAny(e.hosts, t.host) || t.namespace != "" && matchAny(e.namespaces, t.namespace) {
+			klog.V(3).InfoS("Excluded host", "namespace", t.namespace, "kind", t.kind, "object", t.object, "host", t.name())
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept
+}
+
+// matchAny reports whether name matches any of patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored reports whether the ignore annotation of the object namespace/name
+// is set to true.
+func ignored(namespace, name string, annotations map[string]string) bool {
+	value, ok := annotations[ignoreAnnotation]
+	if !ok {
+		return false
+	}
+	ignore, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", ignoreAnnotation)
+		return false
+	}
+	return ignore
+}
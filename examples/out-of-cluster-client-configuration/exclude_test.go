@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExclusion(t *testing.T) {
+	e, err := newExclusion([]string{"*.staging.example.com", "legacy.example.com"}, []string{"sandbox-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := []target{
+		{namespace: "shop", host: "shop.example.com"},
+		{namespace: "shop", host: "shop.staging.example.com"},
+		{namespace: "shop", host: "legacy.example.com", port: 8443},
+		{namespace: "sandbox-alice", host: "alice.example.com"},
+		// Cluster scoped objects have no namespace to exclude.
+		{kind: nodeKind, host: "node-1"},
+	}
+	var got []string
+	for _, t := range e.apply(targets) {
+		got = append(got, t.name())
+	}
+	if want := []string{"shop.example.com", "node-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if len(targets) != 5 || targets[1].host != "shop.staging.example.com" {
+		t.Errorf("expected the targets to be left unchanged, got %+v", targets)
+	}
+
+	if e, err := newExclusion(nil, nil); e != nil || err != nil || len(e.apply(targets)) != len(targets) {
+		t.Errorf("expected no exclusion, got %+v, %v", e, err)
+	}
+	if _, err := newExclusion([]string{"[a-"}, nil); err == nil {
+		t.Errorf("expected an invalid pattern to fail")
+	}
+}
+
+func TestIgnoreAnnotation(t *testing.T) {
+	ing := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec:       v1beta1.IngressSpec{TLS: []v1beta1.IngressTLS{{Hosts: []string{"shop.example.com"}}}},
+	}
+	for value, want := range map[string]int{"true": 0, "false": 1, "yes please": 1} {
+		ing.Annotations = map[string]string{ignoreAnnotation: value}
+		if got := len(ingressTargets(ing, []int{defaultPort}, false)); got != want {
+			t.Errorf("%s=%q: expected %d targets, got %d", ignoreAnnotation, value, want, got)
+		}
+	}
+}
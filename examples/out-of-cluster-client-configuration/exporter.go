@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// metricsExporter keeps the latest results of every object checked in watch
+// mode and serves their metrics to Prometheus. Scans in watch mode only
+// cover the objects that changed, so the results of an object replace the
+// ones it had before and the results of other objects are kept. Objects
+// that were deleted or have no hosts left are dropped.
+type metricsExporter struct {
+	policy policy
+	now    func() time.Time
+	// client, if set, are the metrics of the requests to the API servers,
+	// served along with the ones of the results.
+	client *restClientMetrics
+
+	mu      sync.Mutex
+	results map[objectKey][]result
+	// duration is how long the latest scan took.
+	duration time.Duration
+	// lastSuccess is when the latest scan that was not interrupted ended.
+	lastSuccess time.Time
+}
+
+// objectKey identifies the object of a target in the cluster it is taken
+// from. Targets that are not taken from an object all share the same key.
+type objectKey struct {
+	cluster, kind, namespace, object string
+}
+
+func newMetricsExporter(p policy) *metricsExporter {
+	return &metricsExporter{policy: p, now: time.Now, results: map[objectKey][]result{}}
+}
+
+func (e *metricsExporter) resolvesFindings() {}
+
+func (e *metricsExporter) notify(ctx context.Context, s *summary) error {
+	byObject := map[objectKey][]result{}
+	if s.object != nil {
+		// An interrupted scan of the object may not have checked it.
+		if len(s.results) > 0 || !s.Interrupted {
+			byObject[*s.object] = s.results
+		}
+	} else {
+		for _, r := range s.results {
+			key := objectKey{r.cluster, r.kind, r.namespace, r.object}
+			byObject[key] = append(byObject[key], r)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, results := range byObject {
+		if len(results) == 0 {
+			delete(e.results, key)
+			continue
+		}
+		e.results[key] = results
+	}
+	e.duration = s.duration
+	if !s.Interrupted {
+		e.lastSuccess = s.Time
+	}
+	return nil
+}
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	keys := make([]objectKey, 0, len(e.results))
+	for key := range e.results {
+		keys = append(keys, key)
+	}
+	// Results are sorted within an object already, sorting the objects
+	// keeps the output stable between scrapes.
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.object < b.object
+	})
+	var results []result
+	for _, key := range keys {
+		results = append(results, e.results[key]...)
+	}
+	duration, lastSuccess := e.duration, e.lastSuccess
+	e.mu.Unlock()
+
+	families := scanMetrics(results, e.policy, e.now(), duration, lastSuccess)
+	if e.client != nil {
+		families = append(families, e.client.families()...)
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	if err := writeMetrics(w, families); err != nil {
+		klog.V(2).InfoS("Writing metrics failed", "err", err)
+	}
+}
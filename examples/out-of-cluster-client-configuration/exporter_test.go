@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsExporter(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	e := newMetricsExporter(policy{days: 30})
+	e.now = func() time.Time { return now }
+
+	web := []result{
+		{target: target{namespace: "shop", kind: "Ingress", object: "web", host: "a.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 2, 0)}},
+		{target: target{namespace: "shop", kind: "Ingress", object: "web", host: "b.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+	}
+	api := []result{
+		{target: target{namespace: "shop", kind: "Ingress", object: "api", host: "api.example.com"}, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}, err: errors.New("x509: certificate signed by unknown authority")},
+	}
+	admin := []result{
+		{target: target{namespace: "shop", kind: "Ingress", object: "admin", host: "admin.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 5)}},
+	}
+	for _, results := range [][]result{web, api, admin} {
+		s := newSummary(results, e.policy, now)
+		s.duration = time.Second
+		if err := e.notify(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Deleting admin drops its results.
+	s := newSummary(nil, e.policy, now)
+	s.object = &objectKey{kind: "Ingress", namespace: "shop", object: "admin"}
+	if err := e.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	// Checking web again after b.example.com was renewed replaces its
+	// results, an interrupted scan does not count as a success.
+	web[1].certificate = &certificate{NotAfter: now.AddDate(0, 3, 0)}
+	s = newSummary(web, e.policy, now.Add(time.Hour))
+	s.object = &objectKey{kind: "Ingress", namespace: "shop", object: "web"}
+	s.duration = 2 * time.Second
+	s.Interrupted = true
+	if err := e.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`cert_check_certificate_expiry_timestamp_seconds{host="b.example.com",kind="Ingress",namespace="shop",object="web"} ` + fmt.Sprint(now.AddDate(0, 3, 0).Unix()) + "\n",
+		`cert_check_host_grade{grade="A",host="b.example.com",kind="Ingress",namespace="shop",object="web"} 1` + "\n",
+		"cert_check_hosts 3\n",
+		"cert_check_hosts_failing 1\n",
+		"cert_check_errors 1\n",
+		fmt.Sprintf("cert_check_soonest_expiry_timestamp_seconds %d\n", now.AddDate(0, 2, 0).Unix()),
+		"cert_check_scan_duration_seconds 2\n",
+		fmt.Sprintf("cert_check_last_success_timestamp_seconds %d\n", now.Unix()),
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `object="admin"`) {
+		t.Errorf("expected the deleted object to be dropped:\n%s", body)
+	}
+	if got := w.Header().Get("Content-Type"); got != metricsContentType {
+		t.Errorf("unexpected content type %q", got)
+	}
+	if api, web := strings.Index(body, `object="api"`), strings.Index(body, `object="web"`); api < 0 || api > web {
+		t.Errorf("expected objects to be sorted:\n%s", body)
+	}
+}
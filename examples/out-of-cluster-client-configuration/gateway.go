@@ -0,0 +1,267 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// gatewayKind is the kind of the targets taken from Gateway API gateways.
+const gatewayKind = "Gateway"
+
+// gatewayGroup is the API group of the Gateway API.
+const gatewayGroup = "gateway.networking.k8s.io"
+
+// gatewayIndex indexes HTTPRoutes by the namespace/name keys of the gateways
+// they are attached to.
+const gatewayIndex = "gateway"
+
+// gatewayVersions are the versions of the Gateway API the checker reads,
+// most preferred first.
+var gatewayVersions = []string{"v1", "v1beta1"}
+
+// listener is a listener of a gateway terminating or passing through TLS.
+type listener struct {
+	name     string
+	hostname string
+	port     int
+	// secretNamespace and secretName are the Secret of the first
+	// certificateRef of the listener, if any.
+	secretNamespace string
+	secretName      string
+}
+
+// gatewaySource returns a source checking the TLS listeners of the gateways
+// served by gateways. The hosts of listeners without a hostname, or with a
+// wildcard one, are taken from the HTTPRoutes attached to them, served by
+// httpRoutes.
+func gatewaySource(gateways, httpRoutes cache.SharedIndexInformer) (*source, error) {
+	if err := httpRoutes.AddIndexers(cache.Indexers{gatewayIndex: func(obj interface{}) ([]string, error) {
+		return parentGateways(obj.(*unstructured.Unstructured)), nil
+	}}); err != nil {
+		return nil, err
+	}
+
+	return &source{
+		kind:     gatewayKind,
+		informer: gateways,
+		targets: func(obj interface{}) []target {
+			gw := obj.(*unstructured.Unstructured)
+			key, err := cache.MetaNamespaceKeyFunc(gw)
+			if err != nil {
+				klog.ErrorS(err, "Invalid object", "kind", gatewayKind, "object", klog.KObj(gw))
+				return nil
+			}
+			routes, err := httpRoutes.GetIndexer().ByIndex(gatewayIndex, key)
+			if err != nil {
+				klog.ErrorS(err, "Listing attached HTTPRoutes", "kind", gatewayKind, "object", klog.KObj(gw))
+			}
+			var attached []*unstructured.Unstructured
+			for _, route := range routes {
+				attached = append(attached, route.(*unstructured.Unstructured))
+			}
+			return gatewayTargets(gw, attached)
+		},
+		changed: func(old, new interface{}) bool {
+			oldListeners, _, _ := unstructured.NestedFieldNoCopy(old.(*unstructured.Unstructured).Object, "spec", "listeners")
+			newListeners, _, _ := unstructured.NestedFieldNoCopy(new.(*unstructured.Unstructured).Object, "spec", "listeners")
+			return !reflect.DeepEqual(oldListeners, newListeners)
+		},
+		dependencies: []dependency{{
+			informer: httpRoutes,
+			keys: func(obj interface{}) []string {
+				route, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					return nil
+				}
+				return parentGateways(route)
+			},
+		}},
+	}, nil
+}
+
+// gatewayTargets returns a target for every host of every TLS listener of gw.
+// Listeners with a hostname are checked on it; the others are checked on
+// the hostnames of the routes attached to them that they accept. Listeners
+// are checked on their own port rather than the ones given by -port.
+func gatewayTargets(gw *unstructured.Unstructured, routes []*unstructured.Unstructured) []target {
+	seen := map[string]bool{}
+	var targets []target
+	add := func(l listener, host string) {
+		t := target{
+			namespace:       gw.GetNamespace(),
+			kind:            gatewayKind,
+			object:          gw.GetName(),
+			host:            host,
+			port:            l.port,
+			secretName:      l.secretName,
+			secretNamespace: l.secretNamespace,
+			ref:             objectReference(gw.GetAPIVersion(), gw.GetKind(), gw),
+		}
+		if !seen[t.name()] {
+			seen[t.name()] = true
+			targets = append(targets, t)
+		}
+	}
+
+	for _, l := range gatewayListeners(gw) {
+		if l.hostname != "" && !strings.HasPrefix(l.hostname, "*") {
+			add(l, l.hostname)
+			continue
+		}
+		for _, route := range routes {
+			if !attachedTo(route, gw, l) {
+				continue
+			}
+			hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+			for _, h := range hostnames {
+				if acceptsHostname(l.hostname, h) {
+					add(l, h)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// gatewayListeners returns the listeners of gw speaking HTTPS or TLS.
+func gatewayListeners(gw *unstructured.Unstructured) []listener {
+	items, _, err := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid spec.listeners", "kind", gatewayKind, "object", klog.KObj(gw))
+		return nil
+	}
+
+	var listeners []listener
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		protocol, _, _ := unstructured.NestedString(m, "protocol")
+		if protocol != "HTTPS" && protocol != "TLS" {
+			continue
+		}
+		l := listener{}
+		l.name, _, _ = unstructured.NestedString(m, "name")
+		l.hostname, _, _ = unstructured.NestedString(m, "hostname")
+		port, _, _ := unstructured.NestedInt64(m, "port")
+		l.port = int(port)
+
+		refs, _, _ := unstructured.NestedSlice(m, "tls", "certificateRefs")
+		for _, ref := range refs {
+			r, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _, _ := unstructured.NestedString(r, "group")
+			kind, _, _ := unstructured.NestedString(r, "kind")
+			if group != "" || (kind != "" && kind != "Secret") {
+				continue
+			}
+			l.secretName, _, _ = unstructured.NestedString(r, "name")
+			l.secretNamespace, _, _ = unstructured.NestedString(r, "namespace")
+			if l.secretNamespace == gw.GetNamespace() {
+				l.secretNamespace = ""
+			}
+			break
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// parentRefs returns the parentRefs of route referencing gateways.
+func parentRefs(route *unstructured.Unstructured) []map[string]interface{} {
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	var gateways []map[string]interface{}
+	for _, ref := range refs {
+		r, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, ok, _ := unstructured.NestedString(r, "group")
+		if ok && group != gatewayGroup {
+			continue
+		}
+		kind, ok, _ := unstructured.NestedString(r, "kind")
+		if ok && kind != gatewayKind {
+			continue
+		}
+		gateways = append(gateways, r)
+	}
+	return gateways
+}
+
+// parentGateways returns the namespace/name keys of the gateways route is
+// attached to.
+func parentGateways(route *unstructured.Unstructured) []string {
+	var keys []string
+	for _, ref := range parentRefs(route) {
+		keys = append(keys, parentKey(route, ref))
+	}
+	return keys
+}
+
+// parentKey returns the namespace/name key of the gateway referenced by the
+// parentRef ref of route.
+func parentKey(route *unstructured.Unstructured, ref map[string]interface{}) string {
+	namespace, _, _ := unstructured.NestedString(ref, "namespace")
+	if namespace == "" {
+		namespace = route.GetNamespace()
+	}
+	name, _, _ := unstructured.NestedString(ref, "name")
+	return namespace + "/" + name
+}
+
+// attachedTo reports whether route is attached to the listener l of gw,
+// either to the whole gateway or to l by its name or port.
+func attachedTo(route, gw *unstructured.Unstructured, l listener) bool {
+	for _, ref := range parentRefs(route) {
+		if parentKey(route, ref) != gw.GetNamespace()+"/"+gw.GetName() {
+			continue
+		}
+		if section, ok, _ := unstructured.NestedString(ref, "sectionName"); ok && section != l.name {
+			continue
+		}
+		if port, ok, _ := unstructured.NestedInt64(ref, "port"); ok && int(port) != l.port {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// acceptsHostname reports whether a listener with hostname accepts the route
+// hostname h. A listener without a hostname accepts every one, and a
+// wildcard one every hostname with at least one more label. Wildcard route
+// hostnames cannot be dialed.
+func acceptsHostname(hostname, h string) bool {
+	if h == "" || strings.HasPrefix(h, "*") {
+		return false
+	}
+	if hostname == "" {
+		return true
+	}
+	suffix := strings.TrimPrefix(hostname, "*")
+	return strings.HasSuffix(h, suffix) && len(h) > len(suffix)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGatewayTargets(t *testing.T) {
+	gw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"namespace": "infra", "name": "edge"},
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80)},
+				map[string]interface{}{
+					"name": "shop", "protocol": "HTTPS", "port": int64(443), "hostname": "shop.example.com",
+					"tls": map[string]interface{}{"certificateRefs": []interface{}{
+						map[string]interface{}{"kind": "Secret", "name": "shop-tls", "namespace": "shop"},
+					}},
+				},
+				map[string]interface{}{
+					"name": "wildcard", "protocol": "HTTPS", "port": int64(8443), "hostname": "*.example.com",
+					"tls": map[string]interface{}{"certificateRefs": []interface{}{
+						map[string]interface{}{"name": "wildcard-tls"},
+					}},
+				},
+			},
+		},
+	}}
+	route := func(namespace string, hostnames []interface{}, parentRef map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"namespace": namespace, "name": "web"},
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{parentRef},
+				"hostnames":  hostnames,
+			},
+		}}
+	}
+	routes := []*unstructured.Unstructured{
+		route("api", []interface{}{"api.example.com", "api.example.org"}, map[string]interface{}{"namespace": "infra", "name": "edge"}),
+		// Another gateway.
+		route("infra", []interface{}{"other.example.com"}, map[string]interface{}{"name": "internal"}),
+		// Another listener.
+		route("infra", []interface{}{"shop2.example.com"}, map[string]interface{}{"name": "edge", "sectionName": "shop"}),
+	}
+
+	type host struct {
+		name   string
+		secret string
+	}
+	var got []host
+	for _, target := range gatewayTargets(gw, routes) {
+		namespace, name := target.secretRef()
+		got = append(got, host{target.name(), namespace + "/" + name})
+		if target.namespace != "infra" || target.kind != gatewayKind || target.object != "edge" {
+			t.Errorf("unexpected target %+v", target)
+		}
+	}
+	want := []host{
+		{"shop.example.com", "shop/shop-tls"},
+		{"api.example.com:8443", "infra/wildcard-tls"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAcceptsHostname(t *testing.T) {
+	tests := []struct {
+		listener, route string
+		want            bool
+	}{
+		{"", "shop.example.com", true},
+		{"*.example.com", "shop.example.com", true},
+		{"*.example.com", "a.shop.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "shop.example.org", false},
+		{"", "*.example.com", false},
+	}
+	for _, test := range tests {
+		if got := acceptsHostname(test.listener, test.route); got != test.want {
+			t.Errorf("listener %q, route %q: expected %v, got %v", test.listener, test.route, test.want, got)
+		}
+	}
+}
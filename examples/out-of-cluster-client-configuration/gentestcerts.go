@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/pathcl/client-go/examples/out-of-cluster-client-configuration/testcerts"
+)
+
+// genTestCertsCommand is the subcommand issuing test certificates instead of
+// checking any.
+const genTestCertsCommand = "gen-test-certs"
+
+// testCertOptions are the flags of gen-test-certs.
+type testCertOptions struct {
+	commonName         string
+	sans               string
+	expiresIn          string
+	key                string
+	signatureAlgorithm string
+	selfSigned         bool
+}
+
+// genTestCerts runs gen-test-certs with args: it issues a certificate, by a
+// private certificate authority unless -self-signed, writes them to -out and
+// serves the certificate on -listen until interrupted.
+func genTestCerts(args []string) {
+	fs := flag.NewFlagSet(genTestCertsCommand, flag.ExitOnError)
+	var o testCertOptions
+	fs.StringVar(&o.commonName, "cn", "localhost", "common name of the certificate")
+	fs.StringVar(&o.sans, "san", "", "comma separated DNS names and IP addresses the certificate is valid for (the common name if empty)")
+	fs.StringVar(&o.expiresIn, "expires-in", "90d", "how long until the certificate expires, e.g. 10d to be warned about, or negative, e.g. -1d, for an expired one")
+	fs.StringVar(&o.key, "key", testcerts.DefaultKey, "key of the certificate: rsa-<bits>, e.g. rsa-1024 for a weak one, ecdsa-p256, ecdsa-p384, ecdsa-p521 or ed25519")
+	fs.StringVar(&o.signatureAlgorithm, "signature-algorithm", "", "algorithm the certificate is signed with, as Go names them, e.g. SHA1-RSA (with an RSA key) for a sunset one; the default of the key of the issuer if empty")
+	fs.BoolVar(&o.selfSigned, "self-signed", false, "sign the certificate with its own key instead of a private certificate authority")
+	out := fs.String("out", ".", "directory the certificate and its key are written to, as tls.crt and tls.key, along with the private certificate authority as ca.crt")
+	listen := fs.String("listen", "", "serve the certificate on this address until interrupted, e.g. 127.0.0.1:8443")
+	fs.Parse(args)
+
+	ca, leaf, err := issueTestCertificate(o, time.Now())
+	if err != nil {
+		fatal(err, "Invalid flags")
+	}
+	if err := writeTestCertificate(*out, ca, leaf); err != nil {
+		fatal(err, "Writing the test certificate failed", "dir", *out)
+	}
+	klog.InfoS("Issued test certificate", "dir", *out, "commonName", leaf.Certificate.Subject.CommonName, "expires", leaf.Certificate.NotAfter, "algorithm", leaf.Certificate.SignatureAlgorithm.String())
+	if *listen == "" {
+		return
+	}
+	s, err := testcerts.Serve(*listen, leaf)
+	if err != nil {
+		fatal(err, "Serving the test certificate failed", "addr", *listen)
+	}
+	klog.InfoS("Serving test certificate", "addr", s.Addr)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	s.Close()
+}
+
+// issueTestCertificate returns the certificate of o, issued at now, and the
+// private certificate authority that issued it, nil if it is self-signed.
+func issueTestCertificate(o testCertOptions, now time.Time) (ca, leaf *testcerts.KeyPair, err error) {
+	negative := strings.HasPrefix(o.expiresIn, "-")
+	expiresIn, err := parseDuration(strings.TrimPrefix(o.expiresIn, "-"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid -expires-in: %v", err)
+	}
+	if negative {
+		expiresIn = -expiresIn
+	}
+	options := testcerts.Options{
+		CommonName: o.commonName,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(expiresIn),
+		Key:        o.key,
+	}
+	if options.NotAfter.Before(options.NotBefore) {
+		// Expired certificates were valid for a day.
+		options.NotBefore = options.NotAfter.AddDate(0, 0, -1)
+	}
+	if o.sans != "" {
+		for _, san := range strings.Split(o.sans, ",") {
+			options.SANs = append(options.SANs, strings.TrimSpace(san))
+		}
+	}
+	if o.signatureAlgorithm != "" {
+		alg, ok := signatureAlgorithmsByName()[o.signatureAlgorithm]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown -signature-algorithm %q", o.signatureAlgorithm)
+		}
+		options.SignatureAlgorithm = alg
+	}
+	if o.selfSigned {
+		leaf, err = testcerts.SelfSigned(options)
+		return nil, leaf, err
+	}
+	// The authority outlives the certificates it issues, and signs them with
+	// the algorithm asked for, so its key is of the same algorithm.
+	if ca, err = testcerts.NewAuthority("cert-check test CA", testcerts.Options{
+		NotBefore: options.NotBefore,
+		NotAfter:  now.AddDate(10, 0, 0),
+		Key:       authorityKey(o.key),
+	}); err != nil {
+		return nil, nil, err
+	}
+	if leaf, err = ca.Issue(options); err != nil {
+		return nil, nil, err
+	}
+	return ca, leaf, nil
+}
+
+// authorityKey returns the key of the authority issuing certificates with
+// key: one of the same algorithm, so that the signature algorithms of key
+// can be asked for.
+func authorityKey(key string) string {
+	if strings.HasPrefix(key, "rsa-") {
+		return "rsa-2048"
+	}
+	return key
+}
+
+// writeTestCertificate writes leaf to dir as tls.crt and tls.key, and ca as
+// ca.crt unless it is nil.
+func writeTestCertificate(dir string, ca, leaf *testcerts.KeyPair) error {
+	key, err := leaf.KeyPEM()
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{"tls.crt": leaf.CertificatePEM(), "tls.key": key}
+	if ca != nil {
+		files["ca.crt"] = ca.CertificatePEM()
+	}
+	for name, b := range files {
+		mode := os.FileMode(0644)
+		if name == "tls.key" {
+			mode = 0600
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
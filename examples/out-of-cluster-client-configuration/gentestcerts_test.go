@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/pathcl/client-go/examples/out-of-cluster-client-configuration/testcerts"
+)
+
+func TestGenTestCerts(t *testing.T) {
+	now := time.Now()
+	p := policy{days: 30, minRSABits: defaultMinRSABits, minECBits: defaultMinECBits}
+	tests := []struct {
+		name     string
+		options  testCertOptions
+		severity severity
+		failure  string
+	}{
+		{name: "fine", options: testCertOptions{commonName: "shop.test", expiresIn: "90d"}, severity: severityOK},
+		{name: "expiring", options: testCertOptions{commonName: "shop.test", expiresIn: "10d"}, severity: severityWarning},
+		{name: "expired", options: testCertOptions{commonName: "shop.test", expiresIn: "-1d"}, severity: severityExpired, failure: failureVerify},
+		{name: "weak key", options: testCertOptions{commonName: "shop.test", expiresIn: "90d", key: "rsa-1024"}, severity: severityWarning},
+		{name: "sunset algorithm", options: testCertOptions{commonName: "shop.test", expiresIn: "90d", key: "rsa-2048", signatureAlgorithm: "SHA1-RSA"}, severity: severityError, failure: failureVerify},
+		{name: "other name", options: testCertOptions{commonName: "shop.test", sans: "api.test, 127.0.0.1", expiresIn: "90d"}, severity: severityError, failure: failureVerify},
+		{name: "self-signed", options: testCertOptions{commonName: "shop.test", expiresIn: "90d", selfSigned: true}, severity: severityError, failure: failureVerify},
+	}
+	for _, test := range tests {
+		ca, leaf, err := issueTestCertificate(test.options, now)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		dir, err := ioutil.TempDir("", "gen-test-certs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := writeTestCertificate(dir, ca, leaf); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := tls.LoadX509KeyPair(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err != nil {
+			t.Errorf("%s: expected the written key pair to load, got %v", test.name, err)
+		}
+		// Certificates are checked against the authority that issued them,
+		// as with -ca-file.
+		roots, err := loadRoots(filepath.Join(dir, "ca.crt"), "")
+		if test.options.selfSigned {
+			if _, statErr := os.Stat(filepath.Join(dir, "ca.crt")); !os.IsNotExist(statErr) {
+				t.Errorf("%s: expected no ca.crt for a self-signed certificate", test.name)
+			}
+			roots, err = loadRoots("", "")
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		s, err := testcerts.Serve("127.0.0.1:0", leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &dialer{timeout: wait.ForeverTestTimeout, roots: roots}
+		r := d.check(context.Background(), target{host: "shop.test", address: s.Addr})
+		s.Close()
+		if r.certificate == nil {
+			t.Fatalf("%s: expected the served certificate, got %v", test.name, r.err)
+		}
+		if sev := p.severity(r, now); sev != test.severity {
+			t.Errorf("%s: expected %s, got %s (%v)", test.name, test.severity, sev, r.err)
+		}
+		if got := r.failure(); got != test.failure {
+			t.Errorf("%s: expected failure %q, got %q", test.name, test.failure, got)
+		}
+		if test.options.signatureAlgorithm != "" && (r.certificate.Algorithm != test.options.signatureAlgorithm || r.certificate.Sunset == nil) {
+			t.Errorf("%s: expected a sunset %s certificate, got %+v", test.name, test.options.signatureAlgorithm, r.certificate)
+		}
+	}
+
+	for _, invalid := range []testCertOptions{
+		{expiresIn: "soon"},
+		{expiresIn: "90d", key: "dsa"},
+		{expiresIn: "90d", signatureAlgorithm: "SHA3-RSA"},
+		{expiresIn: "90d", signatureAlgorithm: "SHA1-RSA"},
+	} {
+		if _, _, err := issueTestCertificate(invalid, now); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
@@ -3,6 +3,12 @@ module github.com/pathcl/client-go/examples/out-of-cluster-client-configuration
 go 1.13
 
 require (
-	github.com/pathcl/go-check-ssl-certificates v0.0.0-20191014191825-af31ca3d466f // indirect
-	k8s.io/client-go v0.0.0-20190819141724-e14f31a72a77 // indirect
+	golang.org/x/crypto v0.0.0-20181025213731-e84da0312774
+	golang.org/x/net v0.0.0-20190812203447-cdfb69ac37fc
+	golang.org/x/time v0.0.0-20161028155119-f51c12702a4d
+	k8s.io/api v0.0.0-20190819141258-3544db3b9e44
+	k8s.io/apimachinery v0.0.0-20190817020851-f2f3a405f61d
+	k8s.io/client-go v0.0.0-20190819141724-e14f31a72a77
+	k8s.io/klog/v2 v2.4.0
+	sigs.k8s.io/yaml v1.1.0
 )
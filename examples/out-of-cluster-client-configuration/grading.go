@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultGrades are the grades of the hosts no grading rule matches, by
+// severity.
+var defaultGrades = map[severity]string{
+	severityOK:      "A",
+	severityWarning: "B",
+	severityExpired: "F",
+	severityRevoked: "F",
+	severityError:   "F",
+}
+
+// gradingRule grades the hosts matching every condition of When, and
+// overrides their severity if Severity is set. Rules are read from the rules
+// of the policy file, and the first one a host matches applies:
+//
+//	rules:
+//	- name: legacy-tls
+//	  grade: C
+//	  severity: warning
+//	  when:
+//	    tlsVersionBelow: TLS 1.2
+//	- name: internal-sha1
+//	  grade: B
+//	  severity: ok
+//	  when:
+//	    signatureAlgorithms: [SHA1-RSA]
+type gradingRule struct {
+	Name     string           `json:"name"`
+	Grade    string           `json:"grade"`
+	Severity string           `json:"severity"`
+	When     gradingCondition `json:"when"`
+
+	severity            severity
+	expiresWithin       time.Duration
+	signatureAlgorithms map[string]bool
+	tlsVersionBelow     uint16
+}
+
+// gradingCondition are the conditions of a grading rule, the unset ones
+// match every host. Conditions about the certificate never match hosts that
+// could not be checked.
+type gradingCondition struct {
+	// Error matches the hosts whose certificate could not be checked, or
+	// failed verification.
+	Error bool `json:"error"`
+	// ExpiresWithin matches the certificates expiring within it, e.g. 14d,
+	// including the ones that expired.
+	ExpiresWithin string `json:"expiresWithin"`
+	// RSABitsBelow and ECBitsBelow match the leaf certificates with a
+	// smaller key.
+	RSABitsBelow int `json:"rsaBitsBelow"`
+	ECBitsBelow  int `json:"ecBitsBelow"`
+	// SignatureAlgorithms match the leaf certificates signed with one of
+	// them, named as Go names them.
+	SignatureAlgorithms []string `json:"signatureAlgorithms"`
+	// TLSVersionBelow matches the hosts negotiating an older TLS version,
+	// e.g. TLS 1.2.
+	TLSVersionBelow string `json:"tlsVersionBelow"`
+	// ChainIssues matches the certificates served with an incomplete chain,
+	// a chain expiring first, or failing a tolerated verification.
+	ChainIssues bool `json:"chainIssues"`
+}
+
+// aboutCertificate reports whether any condition of w is about the
+// certificate.
+func (w gradingCondition) aboutCertificate() bool {
+	return w.ExpiresWithin != "" || w.RSABitsBelow > 0 || w.ECBitsBelow > 0 || len(w.SignatureAlgorithms) > 0 || w.TLSVersionBelow != "" || w.ChainIssues
+}
+
+// compile checks the fields of r and parses them.
+func (r *gradingRule) compile() error {
+	if r.Grade == "" && r.Severity == "" {
+		return fmt.Errorf("rule %q sets neither a grade nor a severity", r.Name)
+	}
+	if r.Severity != "" {
+		sev, ok := severityNamed(r.Severity)
+		if !ok {
+			return fmt.Errorf("rule %q: invalid severity %q: must be ok, warning, expired, revoked or error", r.Name, r.Severity)
+		}
+		r.severity = sev
+	}
+	w := r.When
+	if !w.Error && !w.aboutCertificate() {
+		return fmt.Errorf("rule %q has no condition", r.Name)
+	}
+	if w.ExpiresWithin != "" {
+		within, err := parseDuration(w.ExpiresWithin)
+		if err != nil {
+			return fmt.Errorf("rule %q: %v", r.Name, err)
+		}
+		r.expiresWithin = within
+	}
+	if len(w.SignatureAlgorithms) > 0 {
+		algorithms := signatureAlgorithmsByName()
+		r.signatureAlgorithms = map[string]bool{}
+		for _, name := range w.SignatureAlgorithms {
+			if _, ok := algorithms[name]; !ok {
+				return fmt.Errorf("rule %q: unknown signature algorithm %q", r.Name, name)
+			}
+			r.signatureAlgorithms[name] = true
+		}
+	}
+	if w.TLSVersionBelow != "" {
+		if r.tlsVersionBelow = tlsVersionNamed(w.TLSVersionBelow); r.tlsVersionBelow == 0 {
+			return fmt.Errorf("rule %q: unknown TLS version %q", r.Name, w.TLSVersionBelow)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the host of res matches every condition of r at
+// now.
+func (r *gradingRule) matches(res result, now time.Time) bool {
+	w := r.When
+	if w.Error && res.certificate != nil && res.err == nil {
+		return false
+	}
+	if !w.aboutCertificate() {
+		return true
+	}
+	c := res.certificate
+	if c == nil {
+		return false
+	}
+	if r.expiresWithin > 0 && !c.NotAfter.Before(now.Add(r.expiresWithin)) {
+		return false
+	}
+	if w.RSABitsBelow > 0 && (c.PublicKey.Algorithm != x509.RSA.String() || c.PublicKey.Bits >= w.RSABitsBelow) {
+		return false
+	}
+	if w.ECBitsBelow > 0 && (c.PublicKey.Algorithm != x509.ECDSA.String() || c.PublicKey.Bits >= w.ECBitsBelow) {
+		return false
+	}
+	if r.signatureAlgorithms != nil && !r.signatureAlgorithms[c.Algorithm] {
+		return false
+	}
+	if r.tlsVersionBelow > 0 && (c.Protocol == nil || tlsVersionNamed(c.Protocol.Version) >= r.tlsVersionBelow) {
+		return false
+	}
+	if w.ChainIssues && c.IncompleteChain == "" && !c.chainExpiresFirst() && c.VerifyError == "" {
+		return false
+	}
+	return true
+}
+
+// grade returns the severity and the grade of r at now: the ones of the
+// first grading rule it matches, or the severity of the built-in policy and
+// its default grade. A rule without a severity keeps the built-in one.
+func (p policy) grade(r result, now time.Time) (severity, string) {
+	sev := p.builtinSeverity(r, now)
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !rule.matches(r, now) {
+			continue
+		}
+		if rule.Severity != "" {
+			sev = rule.severity
+		}
+		if rule.Grade != "" {
+			return sev, rule.Grade
+		}
+		return sev, defaultGrades[sev]
+	}
+	return sev, defaultGrades[sev]
+}
+
+// severityNamed returns the severity named s, in any case.
+func severityNamed(s string) (severity, bool) {
+	for sev := severityOK; sev <= severityError; sev++ {
+		if strings.EqualFold(sev.String(), s) {
+			return sev, true
+		}
+	}
+	return severityOK, false
+}
+
+// tlsVersionNamed returns the version of the TLS version name, 0 if it is
+// unknown.
+func tlsVersionNamed(name string) uint16 {
+	for version, n := range tlsVersionNames {
+		if n == name {
+			return version
+		}
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestGrade(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	f := &policyFile{}
+	if err := yaml.UnmarshalStrict([]byte(`
+rules:
+- name: unreachable
+  grade: E
+  when:
+    error: true
+- name: legacy-tls
+  grade: C
+  severity: warning
+  when:
+    tlsVersionBelow: TLS 1.2
+- name: internal-sha1
+  grade: B
+  severity: ok
+  when:
+    signatureAlgorithms: [SHA1-RSA]
+- name: small-rsa-expiring
+  grade: D
+  when:
+    rsaBitsBelow: 3072
+    expiresWithin: 14d
+- name: broken-chain
+  severity: error
+  when:
+    chainIssues: true
+`), f); err != nil {
+		t.Fatal(err)
+	}
+	p := policy{days: 30, minRSABits: defaultMinRSABits, minECBits: defaultMinECBits}
+	if err := f.apply(&p, nil); err != nil {
+		t.Fatal(err)
+	}
+	valid := func(days int) *certificate {
+		return &certificate{
+			NotAfter:  now.AddDate(0, 0, days),
+			Algorithm: "SHA256-RSA",
+			PublicKey: publicKey{Algorithm: "RSA", Bits: 2048},
+			Protocol:  &protocol{Version: "TLS 1.3"},
+		}
+	}
+	tests := []struct {
+		name     string
+		result   result
+		severity severity
+		grade    string
+	}{
+		{name: "fine", result: result{certificate: valid(90)}, severity: severityOK, grade: "A"},
+		{name: "expiring", result: result{certificate: valid(20)}, severity: severityWarning, grade: "B"},
+		{name: "unreachable", result: result{err: errors.New("connection refused")}, severity: severityError, grade: "E"},
+		{name: "legacy TLS", result: result{certificate: func() *certificate { c := valid(90); c.Protocol.Version = "TLS 1.1"; return c }()}, severity: severityWarning, grade: "C"},
+		{name: "accepted SHA-1", result: result{certificate: func() *certificate { c := valid(90); c.Algorithm = "SHA1-RSA"; return c }()}, severity: severityOK, grade: "B"},
+		{name: "small key expiring", result: result{certificate: valid(10)}, severity: severityWarning, grade: "D"},
+		{name: "small EC key expiring", result: result{certificate: func() *certificate {
+			c := valid(10)
+			c.PublicKey = publicKey{Algorithm: "ECDSA", Bits: 256}
+			return c
+		}()}, severity: severityWarning, grade: "B"},
+		{name: "broken chain", result: result{certificate: func() *certificate { c := valid(90); c.VerifyError = "unknown authority"; return c }()}, severity: severityError, grade: "F"},
+	}
+	for _, test := range tests {
+		sev, grade := p.grade(test.result, now)
+		if sev != test.severity || grade != test.grade {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.severity, test.grade, sev, grade)
+		}
+		if got := p.severity(test.result, now); got != sev {
+			t.Errorf("%s: expected the severity of the grade, got %s", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// health tells Kubernetes probes whether the checker is ready, once a scan
+// round succeeded, and healthy, until too many rounds in a row failed. A
+// round fails when it checked targets and every check failed, which is what
+// a broken network or resolver looks like; the checks of single objects, and
+// scans interrupted on shutdown, do not count.
+type health struct {
+	// unhealthyAfter is the number of failed rounds in a row after which the
+	// checker is unhealthy.
+	unhealthyAfter int
+
+	mu    sync.Mutex
+	ready bool
+	// failures is the number of failed rounds since the last one that
+	// succeeded, and lastErr the error of the last check of the last one.
+	failures int
+	lastErr  error
+}
+
+func newHealth(unhealthyAfter int) *health {
+	return &health{unhealthyAfter: unhealthyAfter}
+}
+
+func (h *health) perRound() {}
+
+// resolvesFindings has health sent every summary, whether it has findings or
+// not, of every result rather than of the ones that changed.
+func (h *health) resolvesFindings() {}
+
+func (h *health) notify(ctx context.Context, s *summary) error {
+	if s.Interrupted {
+		return nil
+	}
+	var lastErr error
+	failed := len(s.results) > 0
+	for _, r := range s.results {
+		if r.err == nil {
+			failed = false
+			break
+		}
+		lastErr = r.err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if failed {
+		h.failures++
+		h.lastErr = lastErr
+		return nil
+	}
+	h.ready = true
+	h.failures = 0
+	h.lastErr = nil
+	return nil
+}
+
+// idle makes the checker ready without any scan round, as a standby replica
+// while another one holds the Lease.
+func (h *health) idle() {
+	h.mu.Lock()
+	h.ready = true
+	h.mu.Unlock()
+}
+
+// serveHealthz serves /healthz, failing once unhealthyAfter rounds in a row
+// failed.
+func (h *health) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	failures, lastErr := h.failures, h.lastErr
+	h.mu.Unlock()
+	if h.unhealthyAfter > 0 && failures >= h.unhealthyAfter {
+		http.Error(w, fmt.Sprintf("the last %d scan rounds failed: %v", failures, lastErr), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadyz serves /readyz, failing until a scan succeeded.
+func (h *health) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	ready := h.ready
+	h.mu.Unlock()
+	if !ready {
+		http.Error(w, "no scan round succeeded yet", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
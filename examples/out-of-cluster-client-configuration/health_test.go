@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	h := newHealth(2)
+	status := func(handler http.HandlerFunc) (int, string) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code, w.Body.String()
+	}
+	failed := &summary{results: []result{
+		{target: target{host: "shop.example.com"}, err: errors.New("i/o timeout")},
+		{target: target{host: "api.example.com"}, err: errors.New("no such host")},
+	}}
+	succeeded := &summary{results: []result{
+		{target: target{host: "shop.example.com"}, err: errors.New("i/o timeout")},
+		{target: target{host: "api.example.com"}, certificate: &certificate{}},
+	}}
+
+	if code, _ := status(h.serveReadyz); code != http.StatusServiceUnavailable {
+		t.Errorf("expected not to be ready before any scan, got %d", code)
+	}
+	h.notify(context.Background(), failed)
+	if code, _ := status(h.serveReadyz); code != http.StatusServiceUnavailable {
+		t.Errorf("expected not to be ready after a failed scan, got %d", code)
+	}
+	if code, _ := status(h.serveHealthz); code != http.StatusOK {
+		t.Errorf("expected to be healthy after a single failed scan, got %d", code)
+	}
+	h.notify(context.Background(), succeeded)
+	if code, _ := status(h.serveReadyz); code != http.StatusOK {
+		t.Errorf("expected to be ready after a scan with a certificate, got %d", code)
+	}
+
+	// Interrupted scans do not count.
+	h.notify(context.Background(), failed)
+	h.notify(context.Background(), &summary{results: failed.results, Interrupted: true})
+	if code, _ := status(h.serveHealthz); code != http.StatusOK {
+		t.Errorf("expected interrupted scans not to count, got %d", code)
+	}
+	h.notify(context.Background(), failed)
+	if code, body := status(h.serveHealthz); code != http.StatusInternalServerError || !strings.Contains(body, "the last 2 scan rounds failed: no such host") {
+		t.Errorf("expected to be unhealthy after 2 failed scans, got %d %q", code, body)
+	}
+	// Readiness is never lost, restarting the checker is up to liveness.
+	if code, _ := status(h.serveReadyz); code != http.StatusOK {
+		t.Errorf("expected to stay ready, got %d", code)
+	}
+	h.notify(context.Background(), &summary{})
+	if code, _ := status(h.serveHealthz); code != http.StatusOK {
+		t.Errorf("expected an empty scan to succeed, got %d", code)
+	}
+
+	// In watch mode only the summaries of whole scan rounds count, with or
+	// without findings.
+	h = newHealth(1)
+	notifyAll(context.Background(), []notifier{h}, &summary{results: failed.results, Findings: 2, scope: scopeObject})
+	if code, _ := status(h.serveHealthz); code != http.StatusOK {
+		t.Errorf("expected the checks of single objects not to count, got %d", code)
+	}
+	notifyAll(context.Background(), []notifier{h}, &summary{results: succeeded.results[1:], scope: scopeRound})
+	if code, _ := status(h.serveReadyz); code != http.StatusOK {
+		t.Errorf("expected a round without findings to make the checker ready, got %d", code)
+	}
+
+	// Standby replicas are ready without scanning anything.
+	h = newHealth(0)
+	h.idle()
+	if code, _ := status(h.serveReadyz); code != http.StatusOK {
+		t.Errorf("expected to be ready with nothing to scan, got %d", code)
+	}
+	for i := 0; i < 10; i++ {
+		h.notify(context.Background(), failed)
+	}
+	if code, _ := status(h.serveHealthz); code != http.StatusOK {
+		t.Errorf("expected to stay healthy without -unhealthy-after, got %d", code)
+	}
+}
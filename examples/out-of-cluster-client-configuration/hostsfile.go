@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hostKind is the kind of the targets read from -hosts-file.
+const hostKind = "Host"
+
+// readHostsFile returns the targets of the hosts listed in file, or in stdin
+// when file is "-", checked on port unless their line has another one.
+func readHostsFile(file string, stdin io.Reader, port int) ([]target, error) {
+	if file == "-" {
+		return parseHosts(stdin, "stdin", port)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseHosts(f, file, port)
+}
+
+// parseHosts returns a target for every host listed in r, one per line as
+// host, host:port or a URL, reported as objects of the file named name.
+// Blank lines and comments starting with # are skipped.
+func parseHosts(r io.Reader, name string, port int) ([]target, error) {
+	var targets []target
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := scanner.Text()
+		if i := strings.Index(s, "#"); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		t, err := parseHost(s, port)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		t.kind = hostKind
+		t.object = name
+		targets = append(targets, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", name, err)
+	}
+	return targets, nil
+}
+
+// parseHost returns the target of a line of a hosts file, checked on port
+// unless it has another one. URLs are https:// ones or use the scheme of a
+// STARTTLS protocol, e.g. smtp://mail.example.com, checked on the port of
+// the protocol by default.
+func parseHost(s string, port int) (target, error) {
+	var protocol string
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return target{}, err
+		}
+		if u.Scheme == "https" {
+			port = defaultPort
+		} else if p, ok := startTLSPorts[u.Scheme]; ok {
+			protocol, port = u.Scheme, p
+		} else {
+			return target{}, fmt.Errorf("invalid host %q: only https:// URLs and the ones of STARTTLS protocols are checked", s)
+		}
+		if u.Hostname() == "" {
+			return target{}, fmt.Errorf("invalid host %q", s)
+		}
+		s = u.Host
+	}
+	t := target{host: s, port: port, protocol: protocol}
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		t.host = host
+		if t.port, err = strconv.Atoi(port); err != nil || t.port <= 0 || t.port > 65535 {
+			return target{}, fmt.Errorf("invalid port in %q", s)
+		}
+	}
+	if t.host == "" || strings.ContainsAny(t.host, " \t/") {
+		return target{}, fmt.Errorf("invalid host %q", s)
+	}
+	return t, nil
+}
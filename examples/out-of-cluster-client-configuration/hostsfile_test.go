@@ -0,0 +1,67 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHosts(t *testing.T) {
+	hosts := `
+# staging endpoints, before they get an Ingress
+shop.staging.example.com
+api.staging.example.com:8443   # gRPC gateway
+https://admin.staging.example.com/login
+https://[2001:db8::1]:9443
+10.0.0.1
+smtp://mail.staging.example.com
+ldap://ldap.staging.example.com:1389
+`
+	targets, err := readHostsFile("-", strings.NewReader(hosts), defaultPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		host     string
+		port     int
+		protocol string
+	}{
+		{"shop.staging.example.com", 443, ""},
+		{"api.staging.example.com", 8443, ""},
+		{"admin.staging.example.com", 443, ""},
+		{"2001:db8::1", 9443, ""},
+		{"10.0.0.1", 443, ""},
+		{"mail.staging.example.com", 25, "smtp"},
+		{"ldap.staging.example.com", 1389, "ldap"},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("expected %d targets, got %+v", len(expected), targets)
+	}
+	for i, e := range expected {
+		if targets[i].host != e.host || targets[i].port != e.port || targets[i].protocol != e.protocol || targets[i].kind != hostKind || targets[i].object != "stdin" {
+			t.Errorf("expected %s on port %d, got %+v", e.host, e.port, targets[i])
+		}
+	}
+
+	for _, invalid := range []string{"http://shop.example.com", "shop.example.com:https", "shop.example.com:0", "shop example com"} {
+		_, err := parseHosts(strings.NewReader("ok.example.com\n"+invalid), "hosts.txt", defaultPort)
+		if err == nil || !strings.HasPrefix(err.Error(), "hosts.txt:2: ") {
+			t.Errorf("%s: expected an error on line 2, got %v", invalid, err)
+		}
+	}
+}
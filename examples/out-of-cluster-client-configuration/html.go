@@ -0,0 +1,184 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// htmlReportTemplate renders the standalone page written by -o html.
+const htmlReportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TLS certificates {{.Time.Format "2006-01-02 15:04"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+ul { margin: 0; padding-left: 1.2em; }
+.ok { background: #e6f4e6; }
+.warning { background: #fff4d6; }
+.expired, .revoked, .error { background: #fbe0e0; }
+.detail { color: #555; font-size: smaller; }
+</style>
+</head>
+<body>
+<h1>TLS certificates</h1>
+<p>Checked {{.Time.Format "2006-01-02 15:04:05 MST"}}:{{range .Counts}} <span class="{{.Class}}">{{.Count}} {{.Severity}}</span>{{end}}</p>
+{{range .Namespaces}}<h2>{{with .Cluster}}{{.}}: {{end}}{{if .Namespace}}Namespace {{.Namespace}}{{else}}Cluster scoped{{end}}</h2>
+<table>
+<tr><th>Host</th><th>Object</th><th>Severity</th><th>Expires</th><th>Certificate</th><th>Chain</th></tr>
+{{range .Rows}}<tr class="{{.Class}}">
+<td>{{.Host}}</td>
+<td>{{.Kind}} {{.Object}}</td>
+<td>{{.Severity}}<div class="detail">grade {{.Grade}}</div>{{if .Error}}<div class="detail">{{.Error}}</div>{{end}}{{range .WeakKeys}}<div class="detail">{{.}}</div>{{end}}{{if .Mismatch}}<div class="detail">does not match the certificate in {{.Mismatch}}</div>{{end}}</td>
+<td>{{with .Certificate}}{{.NotAfter.Format "2006-01-02"}} ({{$.DaysUntil .NotAfter}} days){{end}}{{with .CertManager}}<div class="detail">{{.}}</div>{{end}}</td>
+<td>{{with .Certificate}}{{.CommonName}}<div class="detail">issuer {{.IssuerCommonName}}, {{.Algorithm}}, {{.PublicKey}}, serial {{.SerialNumber}}</div>{{with .Protocol}}<div class="detail">{{.Version}}, {{.CipherSuite}}{{with .ALPN}}, {{.}}{{end}}{{if .Weak}} ({{.Weak}}){{end}}</div>{{end}}{{if eq .Coverage "wildcard"}}<div class="detail">host only covered by a wildcard</div>{{else if eq .Coverage "commonName"}}<div class="detail">host only covered by the CommonName</div>{{else if eq .Coverage "none"}}<div class="detail">host not covered</div>{{end}}{{if or .DNSNames .IPAddresses}}<ul>{{range .DNSNames}}<li>{{.}}</li>{{end}}{{range .IPAddresses}}<li>{{.}}</li>{{end}}</ul>{{end}}{{end}}</td>
+<td>{{with .Certificate}}{{if .Chain}}<ul>{{range .Chain}}<li>{{.CommonName}}<div class="detail">issuer {{.IssuerCommonName}}, {{.PublicKey}}, expires {{.NotAfter.Format "2006-01-02"}}</div></li>{{end}}</ul>{{end}}{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Parse(htmlReportTemplate))
+
+// htmlPage is what the HTML report is executed against.
+type htmlPage struct {
+	Time       time.Time
+	Counts     []htmlCount
+	Namespaces []htmlNamespace
+}
+
+// DaysUntil returns the number of days from the time of the report to t.
+func (p *htmlPage) DaysUntil(t time.Time) int {
+	return int(t.Sub(p.Time).Hours() / 24)
+}
+
+// htmlCount is the number of hosts of a severity.
+type htmlCount struct {
+	Severity string
+	Class    string
+	Count    int
+}
+
+type htmlNamespace struct {
+	Cluster   string
+	Namespace string
+	Rows      []htmlRow
+}
+
+// htmlRow is a single host of the report.
+type htmlRow struct {
+	Host, Kind, Object string
+	Severity, Class    string
+	Grade              string
+	Error              string
+	// Mismatch is where the stored certificate the served one differs
+	// from was read.
+	Mismatch string
+	// WeakKeys are why the keys of the certificate and its chain are weak.
+	WeakKeys    []string
+	Certificate *certificate
+	CertManager *certManagerStatus
+	severity    severity
+}
+
+// htmlReporter returns a reporter writing the results of every scan to w
+// as a standalone HTML page.
+func htmlReporter(w io.Writer) reporter {
+	return func(results []result, p policy) {
+		if err := writeHTMLReport(w, results, p, time.Now()); err != nil {
+			klog.ErrorS(err, "Writing the HTML report failed")
+		}
+	}
+}
+
+// writeHTMLReport writes results to w as an HTML page with a section per
+// namespace of every cluster. Within a section the worst hosts come first,
+// then the ones expiring soonest.
+func writeHTMLReport(w io.Writer, results []result, p policy, now time.Time) error {
+	page := &htmlPage{Time: now}
+	counts := map[severity]int{}
+	byNamespace := map[namespaceKey][]htmlRow{}
+	for _, r := range results {
+		sev, grade := p.grade(r, now)
+		counts[sev]++
+		row := htmlRow{
+			Host:        r.name(),
+			Kind:        r.kind,
+			Object:      r.object,
+			Severity:    sev.String(),
+			Grade:       grade,
+			Class:       strings.ToLower(sev.String()),
+			Certificate: r.certificate,
+			CertManager: r.certManager,
+			severity:    sev,
+		}
+		if r.err != nil {
+			row.Error = r.err.Error()
+		}
+		if r.mismatch() {
+			row.Mismatch = r.storedIn()
+		}
+		if r.certificate != nil {
+			row.WeakKeys = p.weakKeys(r.certificate)
+		}
+		key := namespaceKey{r.cluster, r.namespace}
+		byNamespace[key] = append(byNamespace[key], row)
+	}
+
+	for sev := severityError; sev >= severityOK; sev-- {
+		if counts[sev] > 0 {
+			page.Counts = append(page.Counts, htmlCount{Severity: sev.String(), Class: strings.ToLower(sev.String()), Count: counts[sev]})
+		}
+	}
+	for key, rows := range byNamespace {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].severity != rows[j].severity {
+				return rows[i].severity > rows[j].severity
+			}
+			return expiresBefore(rows[i].Certificate, rows[j].Certificate)
+		})
+		page.Namespaces = append(page.Namespaces, htmlNamespace{Cluster: key.cluster, Namespace: key.namespace, Rows: rows})
+	}
+	sort.Slice(page.Namespaces, func(i, j int) bool {
+		a, b := page.Namespaces[i], page.Namespaces[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Namespace < b.Namespace
+	})
+	return htmlReport.Execute(w, page)
+}
+
+// expiresBefore reports whether a expires before b. Missing certificates
+// come last.
+func expiresBefore(a, b *certificate) bool {
+	if a == nil || b == nil {
+		return a != nil
+	}
+	return a.NotAfter.Before(b.NotAfter)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "shop.example.com"}, certificate: &certificate{
+			CommonName:  "shop.example.com",
+			NotAfter:    now.AddDate(1, 0, 0),
+			DNSNames:    []string{"shop.example.com", "www.shop.example.com"},
+			IPAddresses: []string{"10.0.0.1"},
+			PublicKey:   publicKey{Algorithm: "ECDSA", Bits: 256, Curve: "P-256"},
+			Chain:       []chainCertificate{{CommonName: "Example Intermediate", NotAfter: now.AddDate(5, 0, 0), IssuerCommonName: "Example Root", PublicKey: publicKey{Algorithm: "RSA", Bits: 4096}}},
+		}},
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "api.example.com"}, certificate: &certificate{CommonName: "api.example.com", NotAfter: now.AddDate(0, 0, 3)}},
+		{target: target{namespace: "shop", kind: ingressKind, object: "web", host: "<script>.example.com"}, err: errors.New("connection refused")},
+		{target: target{kind: nodeKind, object: "node-1", host: "10.0.0.2", port: 10250}, certificate: &certificate{CommonName: "node-1", NotAfter: now.AddDate(0, 0, -1)}},
+	}
+	var b bytes.Buffer
+	if err := writeHTMLReport(&b, results, policy{days: 30}, now); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+
+	for _, want := range []string{
+		`<span class="error">1 ERROR</span> <span class="expired">1 EXPIRED</span> <span class="warning">1 WARNING</span> <span class="ok">1 OK</span>`,
+		`<h2>Cluster scoped</h2>`,
+		`<h2>Namespace shop</h2>`,
+		`<li>www.shop.example.com</li>`,
+		`<li>10.0.0.1</li>`,
+		`Example Intermediate<div class="detail">issuer Example Root, RSA 4096 bits, expires 2024-10-14</div>`,
+		`2019-10-17 (3 days)`,
+		`&lt;script&gt;.example.com`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the report to contain %s, got:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Errorf("expected hosts to be escaped")
+	}
+
+	// The cluster scoped section comes first, then the failed, expiring
+	// and fine hosts of shop.
+	var order []int
+	for _, host := range []string{"10.0.0.2:10250", "&lt;script&gt;.example.com", "api.example.com", "<td>shop.example.com"} {
+		order = append(order, strings.Index(page, host))
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i-1] < 0 || order[i-1] > order[i] {
+			t.Errorf("unexpected order of hosts %v in:\n%s", order, page)
+			break
+		}
+	}
+}
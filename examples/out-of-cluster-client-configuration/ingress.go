@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+
+	"k8s.io/api/extensions/v1beta1"
+	extensionsinformers "k8s.io/client-go/informers/extensions/v1beta1"
+)
+
+// ingressKind is the kind of the targets taken from ingresses.
+const ingressKind = "Ingress"
+
+// ingressSource returns a source checking the TLS hosts of the ingresses
+// served by informer on ports, unless an ingress says otherwise. With
+// viaStatus the hosts are dialed at the load balancer of the ingress.
+func ingressSource(informer extensionsinformers.IngressInformer, ports []int, viaStatus bool) *source {
+	return &source{
+		kind:     ingressKind,
+		informer: informer.Informer(),
+		targets: func(obj interface{}) []target {
+			return ingressTargets(obj.(*v1beta1.Ingress), ports, viaStatus)
+		},
+		changed: func(old, new interface{}) bool {
+			oldIng := old.(*v1beta1.Ingress)
+			newIng := new.(*v1beta1.Ingress)
+			return !reflect.DeepEqual(oldIng.Spec.TLS, newIng.Spec.TLS) ||
+				checkerAnnotationsChanged(oldIng.Annotations, newIng.Annotations) ||
+				viaStatus && loadBalancerAddress(oldIng) != loadBalancerAddress(newIng)
+		},
+	}
+}
+
+// ingressTargets returns a target for every port of every host of every TLS
+// entry of ing. The ports are taken from the port annotation of ing, or are
+// ports if it has none, the warning window from its warn-before annotation
+// the ALPN protocols offered and the protocol STARTTLS is sent in from its
+// alpn and protocol annotations, and the Secret of the client certificate
+// from its client-cert-secret annotation. An ingress with the ignore annotation
+// has none. With viaStatus the hosts are dialed at the address of the load
+// balancer in the status of ing, or at their own address until it has one.
+func ingressTargets(ing *v1beta1.Ingress, ports []int, viaStatus bool) []target {
+	if ignored(ing.Namespace, ing.Name, ing.Annotations) {
+		return nil
+	}
+	ports = annotatedPorts(ing.Namespace, ing.Name, ing.Annotations, ports)
+	warnBefore := annotatedWarnBefore(ing.Namespace, ing.Name, ing.Annotations)
+	alpn := annotatedALPN(ing.Namespace, ing.Name, ing.Annotations)
+	protocol := annotatedProtocol(ing.Namespace, ing.Name, ing.Annotations)
+	clientSecret := annotatedClientCertSecret(ing.Namespace, ing.Name, ing.Annotations)
+	var address string
+	if viaStatus {
+		address = loadBalancerAddress(ing)
+	}
+
+	var targets []target
+	for _, tls := range ing.Spec.TLS {
+		for _, h := range tls.Hosts {
+			for _, port := range ports {
+				targets = append(targets, target{
+					namespace:    ing.Namespace,
+					kind:         ingressKind,
+					object:       ing.Name,
+					host:         h,
+					port:         port,
+					secretName:   tls.SecretName,
+					ref:          objectReference("extensions/v1beta1", ingressKind, ing),
+					address:      address,
+					warnBefore:   warnBefore,
+					alpn:         alpn,
+					protocol:     protocol,
+					clientSecret: clientSecret,
+				})
+			}
+		}
+	}
+	return targets
+}
+
+// loadBalancerAddress returns the IP, or else the hostname, of the first
+// load balancer ing is exposed through, if any.
+func loadBalancerAddress(ing *v1beta1.Ingress) string {
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			return lb.IP
+		}
+		if lb.Hostname != "" {
+			return lb.Hostname
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressTargets(t *testing.T) {
+	ing := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{
+				{Hosts: []string{"shop.example.com", "www.example.com"}, SecretName: "shop-tls"},
+				{Hosts: []string{"api.example.com"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		annotation string
+		want       []string
+	}{
+		{want: []string{"shop.example.com", "www.example.com", "api.example.com"}},
+		{annotation: "8443", want: []string{"shop.example.com:8443", "www.example.com:8443", "api.example.com:8443"}},
+		{annotation: "443, 8443", want: []string{
+			"shop.example.com", "shop.example.com:8443",
+			"www.example.com", "www.example.com:8443",
+			"api.example.com", "api.example.com:8443",
+		}},
+		// An invalid annotation falls back to the default ports.
+		{annotation: "https", want: []string{"shop.example.com", "www.example.com", "api.example.com"}},
+	}
+	for _, test := range tests {
+		ing.Annotations = nil
+		if test.annotation != "" {
+			ing.Annotations = map[string]string{portAnnotation: test.annotation}
+		}
+		var got []string
+		for _, target := range ingressTargets(ing, []int{defaultPort}, false) {
+			got = append(got, target.name())
+			if target.namespace != "shop" || target.kind != ingressKind || target.object != "web" {
+				t.Errorf("unexpected target %+v", target)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("annotation %q: expected %v, got %v", test.annotation, test.want, got)
+		}
+	}
+}
+
+func TestIngressTargetsViaStatus(t *testing.T) {
+	ing := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{Hosts: []string{"shop.example.com"}}},
+		},
+	}
+	tests := []struct {
+		lbs  []v1.LoadBalancerIngress
+		want string
+	}{
+		// Dialed at its own address until it has a load balancer.
+		{want: ""},
+		{lbs: []v1.LoadBalancerIngress{{IP: "203.0.113.10"}, {IP: "203.0.113.11"}}, want: "203.0.113.10"},
+		{lbs: []v1.LoadBalancerIngress{{Hostname: "lb-1234.elb.example.com"}}, want: "lb-1234.elb.example.com"},
+	}
+	for _, test := range tests {
+		ing.Status.LoadBalancer.Ingress = test.lbs
+		targets := ingressTargets(ing, []int{defaultPort}, true)
+		if len(targets) != 1 || targets[0].host != "shop.example.com" || targets[0].address != test.want {
+			t.Errorf("%v: expected shop.example.com dialed at %q, got %+v", test.lbs, test.want, targets)
+		}
+	}
+}
+
+func TestIngressTargetsClientCertSecret(t *testing.T) {
+	ing := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api", Annotations: map[string]string{clientCertSecretAnnotation: "scanner-client"}},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{Hosts: []string{"api.example.com"}}},
+		},
+	}
+	if targets := ingressTargets(ing, []int{defaultPort}, false); len(targets) != 1 || targets[0].clientSecret != "scanner-client" {
+		t.Errorf("expected the client certificate to be read from scanner-client, got %+v", targets)
+	}
+	ing.Annotations[clientCertSecretAnnotation] = "Scanner Client"
+	if targets := ingressTargets(ing, []int{defaultPort}, false); len(targets) != 1 || targets[0].clientSecret != "" {
+		t.Errorf("expected an invalid Secret name to be ignored, got %+v", targets)
+	}
+}
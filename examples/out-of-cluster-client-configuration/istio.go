@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// istioGatewayKind is the kind of the targets taken from Istio gateways. It
+// differs from their actual kind, Gateway, so that they are not mistaken for
+// Gateway API gateways.
+const istioGatewayKind = "IstioGateway"
+
+// istioGroup is the API group of Istio networking resources.
+const istioGroup = "networking.istio.io"
+
+// istioGatewayIndex indexes virtual services by the namespace/name keys of
+// the Istio gateways they are bound to.
+const istioGatewayIndex = "istio-gateway"
+
+// istioVersions are the versions of the Istio networking API the checker
+// reads, most preferred first.
+var istioVersions = []string{"v1", "v1beta1", "v1alpha3"}
+
+// istioServer is a server block of an Istio gateway terminating or passing
+// through TLS.
+type istioServer struct {
+	// hosts are the hosts of the server, without their namespace.
+	hosts []string
+	// namespaces are the namespaces of the virtual services that may bind to
+	// each of hosts, "*" for any.
+	namespaces []string
+	port       int
+	// credentialName is the Secret holding the certificate of the server,
+	// in the namespace of the gateway.
+	credentialName string
+}
+
+// istioGatewaySource returns a source checking the TLS servers of the Istio
+// gateways served by gateways. The hosts of wildcard servers are taken from
+// the virtual services bound to them, served by virtualServices.
+func istioGatewaySource(gateways, virtualServices cache.SharedIndexInformer) (*source, error) {
+	if err := virtualServices.AddIndexers(cache.Indexers{istioGatewayIndex: func(obj interface{}) ([]string, error) {
+		return virtualServiceGateways(obj.(*unstructured.Unstructured)), nil
+	}}); err != nil {
+		return nil, err
+	}
+
+	return &source{
+		kind:     istioGatewayKind,
+		informer: gateways,
+		targets: func(obj interface{}) []target {
+			gw := obj.(*unstructured.Unstructured)
+			key, err := cache.MetaNamespaceKeyFunc(gw)
+			if err != nil {
+				klog.ErrorS(err, "Invalid object", "kind", istioGatewayKind, "object", klog.KObj(gw))
+				return nil
+			}
+			services, err := virtualServices.GetIndexer().ByIndex(istioGatewayIndex, key)
+			if err != nil {
+				klog.ErrorS(err, "Listing bound virtual services", "kind", istioGatewayKind, "object", klog.KObj(gw))
+			}
+			var bound []*unstructured.Unstructured
+			for _, vs := range services {
+				bound = append(bound, vs.(*unstructured.Unstructured))
+			}
+			return istioGatewayTargets(gw, bound)
+		},
+		changed: func(old, new interface{}) bool {
+			oldServers, _, _ := unstructured.NestedFieldNoCopy(old.(*unstructured.Unstructured).Object, "spec", "servers")
+			newServers, _, _ := unstructured.NestedFieldNoCopy(new.(*unstructured.Unstructured).Object, "spec", "servers")
+			return !reflect.DeepEqual(oldServers, newServers)
+		},
+		dependencies: []dependency{{
+			informer: virtualServices,
+			keys: func(obj interface{}) []string {
+				vs, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					return nil
+				}
+				return virtualServiceGateways(vs)
+			},
+		}},
+	}, nil
+}
+
+// istioGatewayTargets returns a target for every host of every TLS server of
+// gw. Servers are checked on their concrete hosts and, for their wildcard
+// hosts, on the hosts of the virtual services bound to gw that match them.
+// The credentialName Secret of a server is looked up in the namespace of gw,
+// which is expected to be the namespace of the gateway workload.
+func istioGatewayTargets(gw *unstructured.Unstructured, virtualServices []*unstructured.Unstructured) []target {
+	seen := map[string]bool{}
+	var targets []target
+	add := func(s istioServer, host string) {
+		t := target{
+			namespace:  gw.GetNamespace(),
+			kind:       istioGatewayKind,
+			object:     gw.GetName(),
+			host:       host,
+			port:       s.port,
+			secretName: s.credentialName,
+			ref:        objectReference(gw.GetAPIVersion(), gw.GetKind(), gw),
+		}
+		if !seen[t.name()] {
+			seen[t.name()] = true
+			targets = append(targets, t)
+		}
+	}
+
+	for _, s := range istioServers(gw) {
+		for i, host := range s.hosts {
+			if !strings.HasPrefix(host, "*") {
+				add(s, host)
+				continue
+			}
+			for _, vs := range virtualServices {
+				if s.namespaces[i] != "*" && s.namespaces[i] != vs.GetNamespace() {
+					continue
+				}
+				hosts, _, _ := unstructured.NestedStringSlice(vs.Object, "spec", "hosts")
+				for _, h := range hosts {
+					if acceptsHostname(host, h) {
+						add(s, h)
+					}
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// istioServers returns the servers of gw terminating or passing through TLS.
+// Servers using Istio mutual TLS only serve the mesh and are left out.
+func istioServers(gw *unstructured.Unstructured) []istioServer {
+	items, _, err := unstructured.NestedSlice(gw.Object, "spec", "servers")
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid spec.servers", "kind", istioGatewayKind, "object", klog.KObj(gw))
+		return nil
+	}
+
+	var servers []istioServer
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		protocol, _, _ := unstructured.NestedString(m, "port", "protocol")
+		protocol = strings.ToUpper(protocol)
+		if protocol != "HTTPS" && protocol != "TLS" {
+			continue
+		}
+		if mode, _, _ := unstructured.NestedString(m, "tls", "mode"); mode == "ISTIO_MUTUAL" {
+			continue
+		}
+		s := istioServer{}
+		port, _, _ := unstructured.NestedInt64(m, "port", "number")
+		s.port = int(port)
+		s.credentialName, _, _ = unstructured.NestedString(m, "tls", "credentialName")
+		hosts, _, _ := unstructured.NestedStringSlice(m, "hosts")
+		for _, h := range hosts {
+			namespace := "*"
+			if i := strings.Index(h, "/"); i >= 0 {
+				namespace, h = h[:i], h[i+1:]
+				if namespace == "." {
+					namespace = gw.GetNamespace()
+				}
+			}
+			s.hosts = append(s.hosts, h)
+			s.namespaces = append(s.namespaces, namespace)
+		}
+		servers = append(servers, s)
+	}
+	return servers
+}
+
+// virtualServiceGateways returns the namespace/name keys of the gateways vs
+// is bound to. The reserved mesh gateway is left out.
+func virtualServiceGateways(vs *unstructured.Unstructured) []string {
+	gateways, _, _ := unstructured.NestedStringSlice(vs.Object, "spec", "gateways")
+	var keys []string
+	for _, gw := range gateways {
+		switch {
+		case gw == "mesh":
+		case strings.Contains(gw, "/"):
+			keys = append(keys, gw)
+		default:
+			keys = append(keys, vs.GetNamespace()+"/"+gw)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIstioGatewayTargets(t *testing.T) {
+	gw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"namespace": "istio-system", "name": "edge"},
+		"spec": map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{
+					"port":  map[string]interface{}{"number": int64(80), "protocol": "HTTP"},
+					"hosts": []interface{}{"*"},
+				},
+				map[string]interface{}{
+					"port":  map[string]interface{}{"number": int64(443), "protocol": "HTTPS"},
+					"hosts": []interface{}{"shop/shop.example.com", "api/*.example.com"},
+					"tls":   map[string]interface{}{"mode": "SIMPLE", "credentialName": "example-tls"},
+				},
+				map[string]interface{}{
+					"port":  map[string]interface{}{"number": int64(15443), "protocol": "TLS"},
+					"hosts": []interface{}{"*.local"},
+					"tls":   map[string]interface{}{"mode": "ISTIO_MUTUAL"},
+				},
+			},
+		},
+	}}
+	virtualService := func(namespace string, hosts, gateways []interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"namespace": namespace, "name": "web"},
+			"spec":     map[string]interface{}{"hosts": hosts, "gateways": gateways},
+		}}
+	}
+	services := []*unstructured.Unstructured{
+		virtualService("api", []interface{}{"api.example.com"}, []interface{}{"istio-system/edge"}),
+		// Not allowed to bind to *.example.com from this namespace.
+		virtualService("shop", []interface{}{"cart.example.com"}, []interface{}{"istio-system/edge"}),
+	}
+
+	var got []string
+	for _, target := range istioGatewayTargets(gw, services) {
+		got = append(got, target.name())
+		if target.namespace != "istio-system" || target.kind != istioGatewayKind || target.object != "edge" || target.secretName != "example-tls" {
+			t.Errorf("unexpected target %+v", target)
+		}
+	}
+	if want := []string{"shop.example.com", "api.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestVirtualServiceGateways(t *testing.T) {
+	vs := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "shop", "name": "web"},
+		"spec":     map[string]interface{}{"gateways": []interface{}{"mesh", "edge", "istio-system/edge"}},
+	}}
+	if got, want := virtualServiceGateways(vs), []string{"shop/edge", "istio-system/edge"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
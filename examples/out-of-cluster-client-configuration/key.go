@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// Default minimum key sizes: NIST deprecated RSA keys shorter than 2048 bits
+// and elliptic curves smaller than P-256.
+const (
+	defaultMinRSABits = 2048
+	defaultMinECBits  = 256
+)
+
+// publicKey is the algorithm and size of the public key of a certificate.
+type publicKey struct {
+	Algorithm string `json:"algorithm"`
+	Bits      int    `json:"bits"`
+	// Curve is the name of the curve of ECDSA keys.
+	Curve string `json:"curve,omitempty"`
+}
+
+func newPublicKey(crt *x509.Certificate) publicKey {
+	k := publicKey{Algorithm: crt.PublicKeyAlgorithm.String()}
+	switch pub := crt.PublicKey.(type) {
+	case *rsa.PublicKey:
+		k.Bits = pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		k.Bits = pub.Curve.Params().BitSize
+		k.Curve = pub.Curve.Params().Name
+	case *dsa.PublicKey:
+		k.Bits = pub.P.BitLen()
+	case ed25519.PublicKey:
+		k.Bits = 256
+	}
+	return k
+}
+
+func (k publicKey) String() string {
+	if k.Curve != "" {
+		return fmt.Sprintf("%s %s", k.Algorithm, k.Curve)
+	}
+	return fmt.Sprintf("%s %d bits", k.Algorithm, k.Bits)
+}
+
+// weakKey returns why k is weak by the thresholds of p, if it is. DSA keys
+// are always weak. A zero threshold disables its check.
+func (p policy) weakKey(k publicKey) string {
+	switch {
+	case k.Algorithm == x509.DSA.String():
+		return "DSA keys are deprecated"
+	case k.Algorithm == x509.RSA.String() && k.Bits < p.minRSABits:
+		return fmt.Sprintf("%s is shorter than %d bits", k, p.minRSABits)
+	case k.Algorithm == x509.ECDSA.String() && k.Bits < p.minECBits:
+		return fmt.Sprintf("%s is smaller than %d bits", k, p.minECBits)
+	}
+	return ""
+}
+
+// weakKeys returns why the keys of c and of the chain served along with it
+// are weak, if they are.
+func (p policy) weakKeys(c *certificate) []string {
+	var weak []string
+	if w := p.weakKey(c.PublicKey); w != "" {
+		weak = append(weak, w)
+	}
+	for _, crt := range c.Chain {
+		if w := p.weakKey(crt.PublicKey); w != "" {
+			weak = append(weak, fmt.Sprintf("%s: %s", crt.CommonName, w))
+		}
+	}
+	return weak
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		crt  *x509.Certificate
+		want publicKey
+	}{
+		{&x509.Certificate{PublicKeyAlgorithm: x509.RSA, PublicKey: &rsaKey.PublicKey}, publicKey{Algorithm: "RSA", Bits: 1024}},
+		{&x509.Certificate{PublicKeyAlgorithm: x509.ECDSA, PublicKey: &ecKey.PublicKey}, publicKey{Algorithm: "ECDSA", Bits: 224, Curve: "P-224"}},
+	}
+	for _, test := range tests {
+		if got := newPublicKey(test.crt); got != test.want {
+			t.Errorf("expected %+v, got %+v", test.want, got)
+		}
+	}
+}
+
+func TestWeakKeys(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	p := policy{days: 30, minRSABits: defaultMinRSABits, minECBits: defaultMinECBits}
+	c := &certificate{
+		NotAfter:  now.AddDate(1, 0, 0),
+		PublicKey: publicKey{Algorithm: "RSA", Bits: 1024},
+		Chain: []chainCertificate{
+			{CommonName: "Example Intermediate", PublicKey: publicKey{Algorithm: "ECDSA", Bits: 224, Curve: "P-224"}},
+			{CommonName: "Example Root", PublicKey: publicKey{Algorithm: "RSA", Bits: 4096}},
+		},
+	}
+	want := []string{
+		"RSA 1024 bits is shorter than 2048 bits",
+		"Example Intermediate: ECDSA P-224 is smaller than 256 bits",
+	}
+	if got := p.weakKeys(c); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := p.severity(result{certificate: c}, now); got != severityWarning {
+		t.Errorf("expected %v, got %v", severityWarning, got)
+	}
+
+	// Zero thresholds disable the checks.
+	if got := (policy{days: 30}).severity(result{certificate: c}, now); got != severityOK {
+		t.Errorf("expected %v, got %v", severityOK, got)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// userKind is the kind of the targets taken from the users of kubeconfig
+// contexts.
+const userKind = "User"
+
+// kubeconfigTargets returns a target for the client certificate of every
+// context of the kubeconfig files of rules, named after the context and
+// reported under its user. Certificates are either embedded in the files or
+// read from the files they reference, relative to them. Contexts
+// authenticating otherwise, e.g. with a token or an exec plugin, have no
+// target.
+func kubeconfigTargets(rules *clientcmd.ClientConfigLoadingRules) ([]target, error) {
+	config, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []target
+	for name, context := range config.Contexts {
+		user, ok := config.AuthInfos[context.AuthInfo]
+		if !ok {
+			continue
+		}
+		t := target{
+			kind:            userKind,
+			object:          context.AuthInfo,
+			host:            name,
+			certificatePEM:  user.ClientCertificateData,
+			keyPEM:          user.ClientKeyData,
+			certificateFile: user.ClientCertificate,
+			keyFile:         user.ClientKey,
+		}
+		if len(t.certificatePEM) == 0 && t.certificateFile == "" {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
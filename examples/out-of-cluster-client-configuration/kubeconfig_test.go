@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKubeconfigTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	admin, _ := newTestCertificate(t, "admin", false, nil, nil)
+	ops, _ := newTestCertificate(t, "ops", false, nil, nil)
+	if err := ioutil.WriteFile(filepath.Join(dir, "ops.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ops.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+contexts:
+- name: prod-admin
+  context: {user: admin}
+- name: prod-ops
+  context: {user: ops}
+- name: prod-ci
+  context: {user: ci}
+users:
+- name: admin
+  user: {client-certificate-data: %s}
+- name: ops
+  user: {client-certificate: ops.crt}
+- name: ci
+  user: {token: secret}
+`, base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: admin.Raw})))
+	file := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := kubeconfigTargets(loadingRules(file, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	check := secretChecker(nil)
+	for _, target := range targets {
+		r := check(context.Background(), target)
+		if r.err != nil {
+			t.Fatalf("%s: %v", target.host, r.err)
+		}
+		got[target.host] = r.certificate.CommonName
+	}
+	if len(got) != 2 || got["prod-admin"] != "admin" || got["prod-ops"] != "ops" {
+		t.Errorf("expected the certificates of prod-admin and prod-ops, got %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clientFlags are the flags of kubectl changing how the client
+// configuration is loaded, so that the command behaves the same when run as
+// kubectl cert-expiry.
+type clientFlags struct {
+	// context replaces the current context of kubeconfig.
+	context string
+	// as and asGroups are the user and groups to impersonate.
+	as       string
+	asGroups stringSlice
+	// requestTimeout bounds every request to the API server, 0 waits
+	// forever.
+	requestTimeout time.Duration
+	// qps and burst limit the requests of every client to the API server,
+	// the defaults of client-go when 0. A negative qps leaves throttling to
+	// the API server.
+	qps   float64
+	burst int
+	// contentType is how built-in objects are encoded in the requests to
+	// the API server and its responses: protobuf or json.
+	contentType string
+	// metrics, if set, records the waits of the clients for their rate
+	// limiter.
+	metrics *restClientMetrics
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.context, "context", "", "kubeconfig context to use instead of the current one")
+	fs.StringVar(&f.as, "as", "", "user to impersonate for the requests to the API server")
+	fs.Var(&f.asGroups, "as-group", "group to impersonate for the requests to the API server; may be repeated")
+	fs.DurationVar(&f.requestTimeout, "request-timeout", 0, "how long a single request to the API server may take (0 waits forever)")
+	fs.Float64Var(&f.qps, "kube-api-qps", 0, fmt.Sprintf("how many requests per second may be sent to the API server (%v by default, %v in watch mode; negative disables the client side limit)", rest.DefaultQPS, watchQPS))
+	fs.StringVar(&f.contentType, "kube-api-content-type", "protobuf", "how built-in objects are read from the API server: protobuf, or json for proxies and API servers that do not serve protobuf; custom resources are always read as JSON")
+	fs.IntVar(&f.burst, "kube-api-burst", 0, fmt.Sprintf("how many requests may be sent to the API server at once above -kube-api-qps (%d by default, %d in watch mode)", rest.DefaultBurst, watchBurst))
+}
+
+// watchQPS and watchBurst are the limits of the requests to the API server in
+// watch mode, where notifiers write to every object checked and objects are
+// checked again all at once on every resync.
+const (
+	watchQPS   = 20
+	watchBurst = 40
+)
+
+// watchDefaults sets the limits of f left to their defaults to the ones of
+// watch mode.
+func (f *clientFlags) watchDefaults() {
+	if f.qps == 0 {
+		f.qps = watchQPS
+	}
+	if f.burst == 0 {
+		f.burst = watchBurst
+	}
+}
+
+// apply sets the impersonation, timeout, limits and content type of f on
+// config. The dynamic client always uses JSON, whatever config says.
+func (f *clientFlags) apply(config *rest.Config) {
+	if f.contentType == "protobuf" {
+		config.ContentType = runtime.ContentTypeProtobuf
+		config.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	}
+	if f.as != "" || len(f.asGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{UserName: f.as, Groups: f.asGroups}
+	}
+	if f.requestTimeout > 0 {
+		config.Timeout = f.requestTimeout
+	}
+	if f.qps != 0 {
+		config.QPS = float32(f.qps)
+	}
+	if f.burst != 0 {
+		config.Burst = f.burst
+	}
+	if f.metrics != nil {
+		f.metrics.observeRateLimiter(config)
+	}
+}
+
+// loadingRules returns the rules loading kubeconfig if it was set explicitly,
+// else the files of $KUBECONFIG, as kubectl does, or kubeconfig when it is
+// unset.
+func loadingRules(kubeconfig string, explicit bool) *clientcmd.ClientConfigLoadingRules {
+	if !explicit {
+		if env := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); env != "" {
+			return &clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(env)}
+		}
+	}
+	return &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+}
+
+// kubeconfigName names the files loaded by rules in messages.
+func kubeconfigName(rules *clientcmd.ClientConfigLoadingRules) string {
+	if rules.ExplicitPath != "" {
+		return rules.ExplicitPath
+	}
+	return "$" + clientcmd.RecommendedConfigPathEnvVar
+}
+
+// buildConfig loads kubeconfig, or the files of $KUBECONFIG, as kubectl
+// does, unless kubeconfig was set explicitly. Without either, a missing
+// kubeconfig falls back to the in-cluster configuration built from the
+// mounted service account token and CA.
+func buildConfig(kubeconfig string, explicit bool, f *clientFlags) (*rest.Config, error) {
+	rules := loadingRules(kubeconfig, explicit)
+	if !explicit && rules.Precedence == nil {
+		if _, err := os.Stat(kubeconfig); (kubeconfig == "" || os.IsNotExist(err)) && f.context == "" {
+			config, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, err
+			}
+			f.apply(config)
+			return config, nil
+		}
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: f.context}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	f.apply(config)
+	return config, nil
+}
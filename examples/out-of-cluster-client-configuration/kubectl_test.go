@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(file, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+
+	os.Setenv("KUBECONFIG", file)
+	config, err := buildConfig(filepath.Join(dir, "missing"), false, &clientFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://prod.example.com" {
+		t.Errorf("expected the current context of $KUBECONFIG, got %s", config.Host)
+	}
+
+	os.Setenv("KUBECONFIG", "")
+	f := &clientFlags{context: "staging", as: "jane", asGroups: stringSlice{"ops"}, requestTimeout: 5 * time.Second}
+	if config, err = buildConfig(file, true, f); err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://staging.example.com" {
+		t.Errorf("expected the server of staging, got %s", config.Host)
+	}
+	if config.Impersonate.UserName != "jane" || !reflect.DeepEqual(config.Impersonate.Groups, []string{"ops"}) {
+		t.Errorf("expected to impersonate jane in ops, got %+v", config.Impersonate)
+	}
+	if config.Timeout != 5*time.Second {
+		t.Errorf("expected a timeout of 5s, got %v", config.Timeout)
+	}
+	if config.QPS != 0 || config.Burst != 0 {
+		t.Errorf("expected the limits of client-go, got %v and %d", config.QPS, config.Burst)
+	}
+	if config.ContentType != "" {
+		t.Errorf("expected the content type of client-go, got %s", config.ContentType)
+	}
+
+	f = &clientFlags{burst: 100, contentType: "protobuf"}
+	f.watchDefaults()
+	if config, err = buildConfig(file, true, f); err != nil {
+		t.Fatal(err)
+	}
+	if config.QPS != watchQPS || config.Burst != 100 {
+		t.Errorf("expected the QPS of watch mode and a burst of 100, got %v and %d", config.QPS, config.Burst)
+	}
+	if config.ContentType != "application/vnd.kubernetes.protobuf" || config.AcceptContentTypes != "application/vnd.kubernetes.protobuf,application/json" {
+		t.Errorf("expected to read protobuf, falling back to JSON, got %s and %s", config.ContentType, config.AcceptContentTypes)
+	}
+
+	if _, err := buildConfig(file, true, &clientFlags{context: "dev"}); err == nil {
+		t.Error("expected an error for a missing context")
+	}
+}
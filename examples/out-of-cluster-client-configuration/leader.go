@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// serviceAccountNamespace is where pods find the namespace they run in.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// leaderElection configures running the watch mode in several replicas, of
+// which only the one holding a Lease scans and notifies.
+type leaderElection struct {
+	// namespace and name are those of the Lease.
+	namespace string
+	name      string
+	// leaseDuration is how long standby replicas wait before taking over
+	// a Lease that was not renewed, renewDeadline how long the leader keeps
+	// trying to renew it before giving up and retryPeriod how often both
+	// try.
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+	// standby, if set, is called whenever another replica holds the Lease.
+	standby func()
+}
+
+// runAsLeader waits until this replica holds the Lease of le and then calls
+// run, until ctx is done. The Lease is released when ctx is done; losing it
+// otherwise is an error, after which the replica must stop checking
+// anything.
+func runAsLeader(ctx context.Context, client kubernetes.Interface, le leaderElection, run func(ctx context.Context)) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	id += "_" + string(uuid.NewUUID())
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, le.namespace, le.name, client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		return err
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            le.name,
+		LeaseDuration:   le.leaseDuration,
+		RenewDeadline:   le.renewDeadline,
+		RetryPeriod:     le.retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.InfoS("Started leading", "lease", klog.KRef(le.namespace, le.name), "identity", id)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					klog.InfoS("Another replica is leading", "lease", klog.KRef(le.namespace, le.name), "leader", identity)
+					if le.standby != nil {
+						le.standby()
+					}
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	elector.Run(ctx)
+	if ctx.Err() == nil {
+		return errors.New("lost the leader lease " + le.namespace + "/" + le.name)
+	}
+	return nil
+}
+
+// podNamespace returns the namespace of the service account the application
+// runs as in a pod, or the default namespace outside of one.
+func podNamespace() string {
+	if b, err := ioutil.ReadFile(serviceAccountNamespace); err == nil {
+		if ns := strings.TrimSpace(string(b)); ns != "" {
+			return ns
+		}
+	}
+	return "default"
+}
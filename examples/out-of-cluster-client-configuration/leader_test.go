@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestRunAsLeader(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	renewed := make(chan struct{})
+	clientset.PrependReactor("update", "leases", func(clienttesting.Action) (bool, runtime.Object, error) {
+		select {
+		case renewed <- struct{}{}:
+		default:
+		}
+		return false, nil, nil
+	})
+	le := leaderElection{
+		namespace:     "monitoring",
+		name:          "cert-check",
+		leaseDuration: 3 * time.Second,
+		renewDeadline: 2 * time.Second,
+		retryPeriod:   time.Second,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leading := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- runAsLeader(ctx, clientset, le, func(ctx context.Context) {
+			close(leading)
+			<-ctx.Done()
+		})
+	}()
+
+	select {
+	case <-leading:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to lead")
+	}
+	lease, err := clientset.CoordinationV1().Leases("monitoring").Get("cert-check", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		t.Errorf("expected the lease to be held, got %+v", lease.Spec)
+	}
+
+	// The elector races with itself if canceled while renewing, so it is
+	// canceled halfway between two renewals.
+	select {
+	case <-renewed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to renew")
+	}
+	time.Sleep(le.retryPeriod / 2)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected no error once canceled, got %v", err)
+	}
+	lease, err = clientset.CoordinationV1().Leases("monitoring").Get("cert-check", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" {
+		t.Errorf("expected the lease to be released, held by %s", *lease.Spec.HolderIdentity)
+	}
+}
@@ -18,57 +18,778 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	certs "github.com/pathcl/go-check-ssl-certificates"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
+// exitThresholdExceeded is the exit code of a one-shot run whose results
+// exceed -fail-on.
+const exitThresholdExceeded = 1
+
+// exitFailure is the exit code of runs that could not scan at all, e.g.
+// because of invalid flags or an unreachable cluster.
+const exitFailure = 2
+
+// exitInterrupted is the exit code of one-shot runs interrupted by a signal,
+// whose results are partial.
+const exitInterrupted = 3
+
+// defaultWarningDays is the warning window used when none of -days, -months
+// and -years is set.
+const defaultWarningDays = 30
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == genTestCertsCommand {
+		genTestCerts(os.Args[2:])
+		klog.Flush()
+		return
+	}
 	var kubeconfig *string
 	if home := homeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
+		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file, the in-cluster configuration is used if it does not exist")
 	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file, the in-cluster configuration is used if not set")
 	}
+	var client clientFlags
+	client.register(flag.CommandLine)
+	configFlag := flag.String("config", "", "YAML file mapping flag names to their values; flags set on the command line take precedence, then $"+configEnvPrefix+"<FLAG> environment variables, e.g. $"+configEnv("notify-slack-webhook")+"; in watch mode SIGHUP reloads it")
+	watch := flag.Bool("watch", false, "keep running and re-check the hosts of ingresses as they are added or updated")
+	listPageSize := flag.Int64("list-page-size", 0, "list objects from the API server in pages of this many, read from etcd instead of the watch cache of the API server (0 lists them all at once)")
+	resync := flag.Duration("resync", 0, "in watch mode, how often every ingress is re-checked even if unchanged (0 disables)")
+	recheckFlag := flag.String("recheck-schedule", "", "in watch mode, check every object again depending on how soon its first certificate expires, as window:interval tiers by increasing window optionally followed by the interval beyond them, e.g. 7d:1h,24h to check certificates expiring within 7 days every hour and the others every day; failed hosts are checked on the first tier")
+	var le leaderElection
+	leaderElect := flag.Bool("leader-elect", false, "in watch mode, only scan and notify while holding a Lease, so that several replicas can run with one of them active at a time")
+	flag.StringVar(&le.namespace, "leader-elect-namespace", "", "namespace of the leader election Lease (the namespace of the pod, or default outside of one)")
+	flag.StringVar(&le.name, "leader-elect-name", "cert-check", "name of the leader election Lease")
+	flag.DurationVar(&le.leaseDuration, "leader-elect-lease-duration", 15*time.Second, "how long standby replicas wait before taking over a Lease that was not renewed")
+	flag.DurationVar(&le.renewDeadline, "leader-elect-renew-deadline", 10*time.Second, "how long the leader keeps trying to renew its Lease before it stops checking")
+	flag.DurationVar(&le.retryPeriod, "leader-elect-retry-period", 2*time.Second, "how often replicas try to acquire or renew the Lease")
+	workers := flag.Int("workers", 1, "in watch mode, number of ingresses checked in parallel")
+	concurrency := flag.Int("concurrency", 10, "number of hosts checked in parallel")
+	timeout := flag.Duration("timeout", 10*time.Second, "how long connecting to a single host and completing the TLS handshake may take")
+	retries := flag.Int("retries", 2, "how many times a host is dialed again after a transient failure, such as a timeout or a reset connection")
+	retryBackoff := flag.Duration("retry-backoff", time.Second, "how long to wait before the first retry, doubled on every following one")
+	retryJitter := flag.Float64("retry-jitter", 0.5, "up to which fraction of the backoff is randomly added to it, so that retries of many hosts are spread out")
+	maxDialsPerSecond := flag.Float64("max-dials-per-second", 0, "how many times per second every address hosts resolve to may be dialed, so that large scans do not trip the rate limits of shared load balancers (0 is unlimited)")
+	perHostInterval := flag.Duration("per-host-interval", 0, "how long to wait between two dials of the same host, including retries (0 does not wait)")
+	dialCacheTTL := flag.Duration("dial-cache-ttl", 0, "in watch mode, how long the result of dialing a host is reused for the other objects declaring it (0 only shares checks in flight); one-shot scans dial every host once")
+	overallDeadline := flag.Duration("overall-deadline", 0, "how long a one-shot scan may take in total, hosts not checked by then are reported as failed (0 disables)")
+	flushTimeout := flag.Duration("flush-timeout", 10*time.Second, "how long the notifications of a scan interrupted by a signal or -overall-deadline may take, sent with the results gathered so far (0 does not send them)")
+	var p policy
+	flag.IntVar(&p.years, "years", 0, "warn if the certificate will expire within this many years")
+	flag.IntVar(&p.months, "months", 0, "warn if the certificate will expire within this many months")
+	flag.IntVar(&p.days, "days", 0, fmt.Sprintf("warn if the certificate will expire within this many days (%d if none of -days, -months and -years is set)", defaultWarningDays))
+	flag.IntVar(&p.minRSABits, "min-rsa-bits", defaultMinRSABits, "warn if the certificate or its chain has an RSA key shorter than this many bits (0 disables)")
+	flag.IntVar(&p.minECBits, "min-ec-bits", defaultMinECBits, "warn if the certificate or its chain has an elliptic curve key smaller than this many bits, e.g. 256 for P-256 (0 disables)")
+	policyFileFlag := flag.String("policy-file", "", "YAML file with the warning thresholds, weak key sizes and signature algorithm sunset dates; flags set on the command line take precedence")
+	failOnFlag := flag.String("fail-on", "", "exit non-zero after a one-shot scan if any host fails this threshold: error (unreachable or expired), warning (also within the warning window) or expiring<duration>, e.g. expiring7d (errors or expiring within the duration)")
+	var notifyFlags stringSlice
+	flag.Var(&notifyFlags, "notify", "send a summary of the hosts that need attention after each scan to a notifier: kind[,key=value...] with kind slack (url, template), webhook (url, template), email (server, username, from, to, format, template), alertmanager (url, resolve-after) or stdout (template), e.g. slack,url=https://hooks.slack.com/...,min-severity=expired; min-severity (warning, expired, revoked or error) only notifies about findings at least that severe; may be repeated")
+	slackWebhook := flag.String("notify-slack-webhook", "", "post a summary of the hosts that need attention to this Slack incoming webhook after each scan")
+	slackTemplate := flag.String("notify-slack-template", "", "file with a text/template for the Slack message, executed against the scan summary")
+	webhookURL := flag.String("notify-webhook-url", "", "POST a summary of the hosts that need attention to this URL after each scan")
+	webhookTemplate := flag.String("notify-webhook-template", "", "file with a text/template for the webhook request body, the summary is sent as JSON if not set")
+	smtpServer := flag.String("notify-smtp-server", "", "host:port of the SMTP server used to e-mail the summary after each scan")
+	smtpUsername := flag.String("notify-smtp-username", "", "user to authenticate to the SMTP server as, with the password from $SMTP_PASSWORD")
+	emailFrom := flag.String("notify-email-from", "", "sender address of e-mailed summaries")
+	var emailTo stringSlice
+	flag.Var(&emailTo, "notify-email-to", "recipient of e-mailed summaries, either address (every namespace) or namespace=address[,address] (only that namespace); may be repeated")
+	emailFormat := flag.String("notify-email-format", "text", "format of e-mailed summaries: text or html")
+	emailTemplate := flag.String("notify-email-template", "", "file with a text/template (or html/template for -notify-email-format=html) for the e-mail body")
+	output := flag.String("o", "log", "how the results of every scan are reported: log (a line per host), table or wide (a table with more columns on stdout), custom-columns=HEADER:jsonpath,... (a table of these columns, e.g. custom-columns=NAME:.host,EXPIRES:.notAfter), html (a standalone page on stdout) or csv (a record per host on stdout); html and csv are only available for one-shot scans")
+	flag.StringVar(output, "output", "log", "same as -o")
+	noHeaders := flag.Bool("no-headers", false, "with -o table, wide or custom-columns, do not print the header line")
+	sortBy := flag.String("sort-by", "namespace", "order of the reported hosts: namespace (then object and host), name or expiry (soonest first)")
+	only := flag.String("only", "", "only report hosts that need attention (warnings), that failed or expired (errors), or whose certificate expired (expired)")
+	expiringWithin := flag.String("expiring-within", "", "only report hosts whose certificate expires within this duration, e.g. 30d")
+	events := flag.Bool("events", false, "record a Warning Event on the object of every host that needs attention after each scan")
+	annotate := flag.Bool("annotate", false, "after each scan, write the expiry and the status of its hosts to every checked ingress as "+expiresAtAnnotation+" and "+statusAnnotation+" annotations")
+	certificateReports := flag.Bool("certificate-reports", false, "after each scan, write the results of every namespace to a "+certificateReportKind+" named "+certificateReportName+" in it (see manifests/certificatereport-crd.yaml)")
+	renewalOverdue := flag.String("renewal-overdue", "", "with -certificate-reports, mark and log the hosts that served the same certificate for longer than this duration, e.g. 60d for certificates renewed automatically every 60 days")
+	operator := flag.Bool("operator", false, "run as an operator: watch, annotate every ingress with a "+certExpiryHealthy+" condition as "+conditionAnnotation+" as well as with -annotate, and check objects again as soon as a certificate enters the warning window or expires")
+	alertmanagerURL := flag.String("alertmanager-url", "", "push an alert for every host that needs attention to this Alertmanager, e.g. http://alertmanager:9093")
+	alertmanagerResolveAfter := flag.Duration("alertmanager-resolve-after", 25*time.Hour, "how long pushed alerts keep firing if the next scan does not push them again")
+	pushgatewayURL := flag.String("pushgateway-url", "", "push the metrics of every one-shot scan to this Prometheus Pushgateway, e.g. http://pushgateway:9091")
+	pushgatewayJob := flag.String("pushgateway-job", "cert-check", "job label of the group the metrics are pushed to")
+	pushgatewayInstance := flag.String("pushgateway-instance", "", "instance label of the group the metrics are pushed to (none if empty)")
+	pushgatewayDeleteAfter := flag.Duration("pushgateway-delete-after", 25*time.Hour, "delete other groups of -pushgateway-job that were not pushed to for this long, e.g. of renamed instances (0 disables)")
+	adminAddr := flag.String("admin-addr", "", "in watch mode, serve /healthz and /readyz on this address for Kubernetes probes, e.g. :8080; may be the same as -metrics-addr")
+	profiling := flag.Bool("profiling", false, "with -admin-addr, also serve the net/http/pprof profiles at /debug/pprof/")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the run to this file when it ends, for go tool pprof")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file when the run ends, for go tool pprof")
+	unhealthyAfter := flag.Int("unhealthy-after", 3, "with -admin-addr, fail /healthz once this many scan rounds in a row failed to check any target (never when 0)")
+	metricsAddr := flag.String("metrics-addr", "", "in watch mode, serve Prometheus metrics of the latest results of every object at /metrics on this address, e.g. :9090")
+	diffWith := flag.String("diff-with", "", "JSON file the previous scan is read from and every scan is saved to, so that only the hosts that changed since are reported and notified: new ones, renewed certificates and changes of severity; in watch mode every object is compared with its previous scan")
+	dumpCerts := flag.String("dump-certs", "", "directory the chain served by every host is written to after each scan, as a PEM file named host[_port].pem")
+	fetchIntermediates := flag.Bool("fetch-intermediates", false, "complete the chains of hosts that do not serve every intermediate certificate from the issuer URLs (AIA) of their certificates, reporting them as warnings rather than errors")
+	ct := flag.Bool("ct", false, "warn about publicly trusted leaf certificates without any signed certificate timestamp, embedded, sent in the handshake or stapled, since browsers reject them")
+	ocsp := flag.Bool("ocsp", false, "query the OCSP responder of every served leaf certificate whose host does not staple an OCSP response")
+	crl := flag.Bool("crl", false, "look up served leaf certificates in the CRL of their issuer when OCSP does not tell their revocation status")
+	crlCacheDir := flag.String("crl-cache-dir", "", "directory where downloaded CRLs are kept until their next update, so that following runs do not download them again")
+	caFile := flag.String("ca-file", "", "PEM bundle of certificate authorities trusted in addition to the system ones, e.g. a private CA")
+	caDir := flag.String("ca-dir", "", "directory of .pem, .crt and .cer files with certificate authorities trusted in addition to the system ones")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "report certificates that cannot be verified as warnings instead of errors")
+	var connectTo stringSlice
+	ipVersionFlag := flag.String("ip-version", string(ipVersionBoth), "which addresses of hosts are dialed: 4 (IPv4 only), 6 (IPv6 only) or both")
+	perIP := flag.Bool("per-ip", false, "check the certificate served at every address a host resolves to, of -ip-version, separately")
+	via := flag.String("via", "dns", "where the TLS hosts of ingresses are dialed: dns (the addresses their names resolve to) or status (the load balancer in the status of the ingress, still sending the host as SNI)")
+	proxyURL := flag.String("proxy", "", "dial hosts through this proxy instead of $HTTPS_PROXY, e.g. http://proxy:3128 or socks5://proxy:1080; hosts matching $NO_PROXY are still dialed directly")
+	resolverFlag := flag.String("resolver", "", "look hosts up with the DNS server at this ip[:port] instead of the ones of the system, e.g. the one of the cluster")
+	dnsOver := flag.String("dns-over", "udp", "transport DNS servers are asked over: udp, or tcp for networks where DNS over UDP is dropped")
+	flag.Var(&connectTo, "connect-to", "dial host at addr[:port] instead of its own address while still sending host as SNI, as host=addr[:port]; may be repeated")
+	clientCert := flag.String("client-cert", "", "PEM file with the client certificate sent to TLS hosts asking for one, with -client-key, unless their ingress has a "+clientCertSecretAnnotation+" annotation")
+	clientKey := flag.String("client-key", "", "PEM file with the private key of -client-cert")
+	alpnFlag := flag.String("alpn", "", "comma separated ALPN protocols offered when checking TLS hosts, e.g. h2 (or grpc, the same) for listeners serving another certificate over HTTP/2, unless their object has a "+alpnAnnotation+" annotation; none are offered when empty")
+	protocolFlag := flag.String("protocol", "https", "protocol TLS hosts speak, unless their object has a "+protocolAnnotation+" annotation: https, or smtp, imap, ldap or postgres to ask them to start TLS first, on the usual port of the protocol unless -port is set")
+	portFlag := flag.String("port", strconv.Itoa(defaultPort), "comma separated ports TLS hosts are checked on, unless their ingress has a "+portAnnotation+" annotation")
+	var crdTargetFlags stringSlice
+	flag.Var(&crdTargetFlags, "crd-target", "also check the certificates found in the objects of a resource at a JSONPath as group/version/resource:jsonpath, e.g. vault.example.com/v1/certificates:{.status.certificate}; PEM, base64 encoded PEM and base64 encoded DER are read; may be repeated")
+	resources := flag.String("resources", "ingresses", "comma separated kinds of objects whose TLS hosts are checked: ingresses, routes (OpenShift route.openshift.io/v1) gateways (Gateway API gateways and the HTTPRoutes attached to them) istio (Istio gateways and their virtual services), webhooks (the CA bundles of admission webhooks), apiservices (the CA bundles of aggregated API services) crds (the CA bundles of conversion webhooks), services (LoadBalancer services annotated with cert-check/enabled=true), nodes (kubelet serving certificates) and secrets (the PEM certificates stored in Opaque Secrets, see -secret-selector)")
+	controlPlane := flag.Bool("control-plane", false, "also check the serving certificate of the API server, and of every -control-plane-endpoint")
+	var controlPlaneEndpoints stringSlice
+	flag.Var(&controlPlaneEndpoints, "control-plane-endpoint", "with -control-plane, another endpoint to check, e.g. etcd=10.0.0.10:2379 or scheduler=10.0.0.10:10259; may be repeated")
+	contextsFlag := flag.String("contexts", "", "comma separated kubeconfig contexts whose clusters are all scanned in one run and reported together, each result with its context as cluster (the current context if empty)")
+	allContexts := flag.Bool("all-contexts", false, "scan the cluster of every context of -kubeconfig, as with -contexts")
+	webhook := flag.Bool("webhook", false, "instead of scanning, serve a validating admission webhook denying Ingresses whose TLS Secrets hold certificates at -webhook-deny")
+	webhookAddr := flag.String("webhook-addr", ":8443", "address the admission webhook listens on")
+	webhookCertFile := flag.String("webhook-cert-file", "", "PEM file with the serving certificate of the admission webhook")
+	webhookKeyFile := flag.String("webhook-key-file", "", "PEM file with the private key of the admission webhook")
+	webhookDeny := flag.String("webhook-deny", "expired", "severity at which the admission webhook denies Ingresses: expired (expired certificates) or warning (also certificates within the warning window), others are admitted with an audit annotation")
+	hostsFile := flag.String("hosts-file", "", "instead of scanning the cluster, check the hosts listed in this file, one host, host:port or URL (https://, or smtp://, imap://, ldap:// and postgres:// to start TLS in that protocol) per line, or in stdin if -; hosts without a port are checked on the first -port")
+	checkKubeconfig := flag.Bool("check-kubeconfig", false, "instead of scanning the cluster, check the client certificates of every context of -kubeconfig")
+	dialConversionWebhooks := flag.Bool("dial-conversion-webhooks", false, "with -resources=crds, also check the certificate served by every conversion webhook, verified against its CA bundle")
+	certSource := flag.String("source", "dial", "where certificates are read from: dial (the certificate served by each host), secret (the kubernetes.io/tls Secret referenced by the ingress, or the certificate embedded in the route) or compare (both, flagging hosts that do not serve the certificate of their Secret or route)")
+	var excludeHosts, excludeNamespaces stringSlice
+	flag.Var(&excludeHosts, "exclude-host", "never check hosts matching this shell pattern, e.g. *.staging.example.com; may be repeated")
+	flag.Var(&excludeNamespaces, "exclude-namespace", "never check objects in namespaces matching this shell pattern; may be repeated")
+	var filter scope
+	flag.StringVar(&filter.namespace, "namespace", "", "only check objects in this namespace (all namespaces when empty)")
+	flag.StringVar(&filter.namespace, "n", "", "same as -namespace")
+	flag.BoolVar(&filter.allNamespaces, "all-namespaces", false, "check objects in all namespaces, even if -namespace is set")
+	flag.BoolVar(&filter.allNamespaces, "A", false, "same as -all-namespaces")
+	flag.StringVar(&filter.labelSelector, "selector", "", "only check objects matching this label selector, e.g. team=payments")
+	flag.StringVar(&filter.secretSelector, "secret-selector", "", "with -resources=secrets, only scan the Opaque Secrets matching this label selector, e.g. cert-check/scan=true")
+	flag.StringVar(&filter.fieldSelector, "field-selector", "", "only check objects matching this field selector, e.g. metadata.name=web")
+	klog.InitFlags(nil)
 	flag.Parse()
+	defer klog.Flush()
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if err := applyEnv(flag.CommandLine, set, os.LookupEnv); err != nil {
+		fatal(err, "Invalid environment")
+	}
+	if *configFlag != "" {
+		c, err := loadConfigFile(*configFlag, flag.CommandLine)
+		if err != nil {
+			fatal(err, "Loading the configuration file", "file", *configFlag)
+		}
+		if err := c.apply(flag.CommandLine, set); err != nil {
+			fatal(err, "Applying the configuration file", "file", *configFlag)
+		}
+	}
 
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if *cpuProfile != "" || *memProfile != "" {
+		var err error
+		if runProfiles, err = startProfiles(*cpuProfile, *memProfile); err != nil {
+			fatal(err, "Starting the CPU profile", "file", *cpuProfile)
+		}
+		defer runProfiles.stop()
+	}
+
+	if *policyFileFlag != "" {
+		f, err := loadPolicyFile(*policyFileFlag)
+		if err != nil {
+			fatal(err, "Loading the policy file", "file", *policyFileFlag)
+		}
+		if err := f.apply(&p, set); err != nil {
+			fatal(err, "Applying the policy file", "file", *policyFileFlag)
+		}
+	}
+	if p.years == 0 && p.months == 0 && p.days == 0 {
+		p.days = defaultWarningDays
+	}
+	ports, err := parsePorts(*portFlag)
+	if err != nil {
+		fatal(err, "Invalid -port")
+	}
+	protocol, err := parseProtocol(*protocolFlag)
 	if err != nil {
-		panic(err.Error())
+		fatal(err, "Invalid -protocol")
+	}
+	if protocol != "" && !set["port"] {
+		ports = []int{startTLSPorts[protocol]}
+	}
+	var alpn []string
+	if *alpnFlag != "" {
+		if alpn, err = parseALPN(*alpnFlag); err != nil {
+			fatal(err, "Invalid -alpn")
+		}
+	}
+	var threshold *failOn
+	if *failOnFlag != "" {
+		if threshold, err = parseFailOn(*failOnFlag); err != nil {
+			fatal(err, "Invalid -fail-on")
+		}
 	}
 
-	// create the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	roots, err := loadRoots(*caFile, *caDir)
+	if err != nil {
+		fatal(err, "Loading certificate authorities")
+	}
+	connectToMap, err := parseConnectTo(connectTo)
+	if err != nil {
+		fatal(err, "Invalid -connect-to")
+	}
+	switch *certSource {
+	case "dial", "secret", "compare":
+	default:
+		fatal(fmt.Errorf("unknown source %q", *certSource), "Invalid flags")
+	}
+	if *operator {
+		*watch = true
+	}
+	if *watch {
+		client.watchDefaults()
+	}
+	if *leaderElect && !*watch {
+		fatal(errors.New("-leader-elect can only be used with -watch"), "Invalid flags")
+	}
+	if len(client.asGroups) > 0 && client.as == "" {
+		fatal(errors.New("--as-group needs --as"), "Invalid flags")
+	}
+	var crdTargets []*crdTarget
+	for _, s := range crdTargetFlags {
+		t, err := parseCRDTarget(s)
+		if err != nil {
+			fatal(err, "Invalid flags")
+		}
+		crdTargets = append(crdTargets, t)
+	}
+	if client.contentType != "protobuf" && client.contentType != "json" {
+		fatal(fmt.Errorf("unknown -kube-api-content-type %q", client.contentType), "Invalid flags")
+	}
+	if *via != "dns" && *via != "status" {
+		fatal(fmt.Errorf("unknown -via %q: must be dns or status", *via), "Invalid flags")
+	}
+	version, err := parseIPVersion(*ipVersionFlag)
+	if err != nil {
+		fatal(err, "Invalid -ip-version")
+	}
+	proxy, err := newProxyFunc(*proxyURL)
+	if err != nil {
+		fatal(err, "Invalid -proxy")
+	}
+	resolver, err := newResolver(*resolverFlag, *dnsOver)
 	if err != nil {
-		panic(err.Error())
+		fatal(err, "Invalid flags")
+	}
+	var clientCertificate *tls.Certificate
+	if *clientCert != "" || *clientKey != "" {
+		if *clientCert == "" || *clientKey == "" {
+			fatal(errors.New("-client-cert and -client-key must be set together"), "Invalid flags")
+		}
+		crt, err := tls.LoadX509KeyPair(*clientCert, *clientKey)
+		if err != nil {
+			fatal(err, "Invalid -client-cert", "file", *clientCert)
+		}
+		clientCertificate = &crt
+	}
+	d := &dialer{
+		timeout:            *timeout,
+		ocsp:               *ocsp,
+		ct:                 *ct,
+		fetchIntermediates: *fetchIntermediates,
+		client:             http.DefaultClient,
+		roots:              roots,
+		insecureSkipVerify: *insecureSkipVerify,
+		connectTo:          connectToMap,
+		alpn:               alpn,
+		protocol:           protocol,
+		clientCertificate:  clientCertificate,
+		limiter:            newDialLimiter(*maxDialsPerSecond, *perHostInterval),
+		proxy:              proxy,
+		resolver:           resolver,
+		network:            version.network(),
+		retry:              wait.Backoff{Duration: *retryBackoff, Factor: 2, Jitter: *retryJitter, Steps: *retries},
+	}
+	if *crl {
+		d.crls = newCRLCache(*crlCacheDir, http.DefaultClient)
+	}
+	// Hosts declared by several objects are only dialed once per scan, or
+	// per -dial-cache-ttl in watch mode.
+	ttl := time.Duration(-1)
+	if *watch {
+		ttl = *dialCacheTTL
+	}
+	dials := newDialCache(d.check, ttl)
+	s := &scanner{
+		concurrency:  *concurrency,
+		policy:       p,
+		flushTimeout: *flushTimeout,
+	}
+	if *perIP {
+		s.expand = (&backendResolver{lookup: resolver.LookupIPAddr, version: version, connectTo: connectToMap}).expand
+	}
+	if s.exclude, err = newExclusion(excludeHosts, excludeNamespaces); err != nil {
+		fatal(err, "Invalid exclusions")
+	}
+	if s.filter, err = newReportFilter(*sortBy, *only, *expiringWithin); err != nil {
+		fatal(err, "Invalid report filter")
+	}
+	format, columnsSpec := *output, ""
+	if strings.HasPrefix(format, "custom-columns=") {
+		format, columnsSpec = "custom-columns", strings.TrimPrefix(format, "custom-columns=")
+	}
+	if *noHeaders && format != "table" && format != "wide" && format != "custom-columns" {
+		fatal(errors.New("-no-headers can only be used with -o table, wide or custom-columns"), "Invalid flags")
+	}
+	switch format {
+	case "log":
+		s.report = printResults
+	case "html":
+		if *watch {
+			fatal(errors.New("-o html cannot be used with -watch"), "Invalid flags")
+		}
+		s.report = htmlReporter(os.Stdout)
+	case "csv":
+		if *watch {
+			fatal(errors.New("-o csv cannot be used with -watch"), "Invalid flags")
+		}
+		s.report = csvReporter(os.Stdout)
+	case "table", "wide", "custom-columns":
+		switch format {
+		case "table":
+			columnsSpec = tableColumns
+		case "wide":
+			columnsSpec = wideColumns
+		}
+		columns, err := parseColumns(columnsSpec)
+		if err != nil {
+			fatal(err, "Invalid -o")
+		}
+		s.report = tableReporter(os.Stdout, columns, *noHeaders)
+	default:
+		fatal(fmt.Errorf("unknown output format %q", *output), "Invalid flags")
+	}
+	var overdue time.Duration
+	if *renewalOverdue != "" {
+		if overdue, err = parseDuration(*renewalOverdue); err != nil {
+			fatal(err, "Invalid flags")
+		}
+	}
+	// The -notify-* flags are shorthands for the notifiers of -notify.
+	var notify []*notifierOptions
+	if *slackWebhook != "" {
+		notify = append(notify, &notifierOptions{kind: "slack", values: map[string][]string{"url": {*slackWebhook}, "template": {*slackTemplate}}})
+	}
+	if *webhookURL != "" {
+		notify = append(notify, &notifierOptions{kind: "webhook", values: map[string][]string{"url": {*webhookURL}, "template": {*webhookTemplate}}})
+	}
+	if *smtpServer != "" {
+		notify = append(notify, &notifierOptions{kind: "email", values: map[string][]string{
+			"server":   {*smtpServer},
+			"username": {*smtpUsername},
+			"from":     {*emailFrom},
+			"to":       emailTo,
+			"format":   {*emailFormat},
+			"template": {*emailTemplate},
+		}})
+	}
+	if *alertmanagerURL != "" {
+		notify = append(notify, &notifierOptions{kind: "alertmanager", values: map[string][]string{"url": {*alertmanagerURL}, "resolve-after": {alertmanagerResolveAfter.String()}}})
+	}
+	for _, value := range notifyFlags {
+		o, err := parseNotifierOptions(value)
+		if err != nil {
+			fatal(err, "Invalid flags")
+		}
+		notify = append(notify, o)
+	}
+	for _, o := range notify {
+		n, err := newNotifier(o, p)
+		if err != nil {
+			fatal(err, "Configuring notifications", "notifier", o.kind)
+		}
+		s.notifiers = append(s.notifiers, n)
+	}
+
+	if *diffWith != "" {
+		if s.diff, err = loadScanDiff(*diffWith, p, !*watch); err != nil {
+			fatal(err, "Loading the previous scan", "file", *diffWith)
+		}
+	}
+	if *dumpCerts != "" {
+		s.notifiers = append(s.notifiers, &certDumper{dir: *dumpCerts})
+	}
+
+	if *pushgatewayURL != "" {
+		// In watch mode every scan only covers the objects that changed,
+		// which would replace the metrics of all the others.
+		if *watch {
+			fatal(errors.New("-pushgateway-url cannot be used with -watch"), "Invalid flags")
+		}
+		if *pushgatewayJob == "" {
+			fatal(errors.New("-pushgateway-job must not be empty"), "Invalid flags")
+		}
+		s.notifiers = append(s.notifiers, &pushgatewayNotifier{
+			url:         *pushgatewayURL,
+			job:         *pushgatewayJob,
+			instance:    *pushgatewayInstance,
+			policy:      p,
+			deleteAfter: *pushgatewayDeleteAfter,
+			client:      notifyClient,
+		})
+	}
+
+	var exporter *metricsExporter
+	if *metricsAddr != "" {
+		if !*watch {
+			fatal(errors.New("-metrics-addr can only be used with -watch, use -pushgateway-url for one-shot scans"), "Invalid flags")
+		}
+		exporter = newMetricsExporter(p)
+		exporter.client = newRESTClientMetrics()
+		client.metrics = exporter.client
+		s.notifiers = append(s.notifiers, exporter)
+	}
+	var h *health
+	if *adminAddr != "" {
+		if !*watch {
+			fatal(errors.New("-admin-addr can only be used with -watch"), "Invalid flags")
+		}
+		h = newHealth(*unhealthyAfter)
+		s.notifiers = append(s.notifiers, h)
+	}
+	if *profiling && *adminAddr == "" {
+		fatal(errors.New("-profiling needs -admin-addr"), "Invalid flags")
+	}
+	var recheck *recheckSchedule
+	if *recheckFlag != "" {
+		if !*watch {
+			fatal(errors.New("-recheck-schedule can only be used with -watch"), "Invalid flags")
+		}
+		if recheck, err = parseRecheckSchedule(*recheckFlag); err != nil {
+			fatal(err, "Invalid flags")
+		}
+	}
+
+	if *hostsFile != "" {
+		// The hosts are dialed as they are, no cluster is involved.
+		if *watch {
+			fatal(errors.New("-hosts-file cannot be used with -watch"), "Invalid flags")
+		}
+		targets, err := readHostsFile(*hostsFile, os.Stdin, ports[0])
+		if err != nil {
+			fatal(err, "Reading the hosts", "file", *hostsFile)
+		}
+		signalled, cancel := cancelOnSignal()
+		defer cancel()
+		ctx := signalled
+		if *overallDeadline > 0 {
+			var cancelDeadline context.CancelFunc
+			ctx, cancelDeadline = context.WithTimeout(ctx, *overallDeadline)
+			defer cancelDeadline()
+		}
+		s.check = dials.check
+		results := s.scan(ctx, targets)
+		if signalled.Err() != nil {
+			exit(exitInterrupted)
+		}
+		if threshold != nil && threshold.exceeded(p, results, time.Now()) {
+			exit(exitThresholdExceeded)
+		}
+		return
 	}
 
-	// we will list every ingress using tls. Why? to check for expiration date and warn
-	ingress, err := clientset.ExtensionsV1beta1().Ingresses("").List(metav1.ListOptions{})
+	// use the current context in kubeconfig, or the service account of the
+	// pod when there is none
+	explicitKubeconfig := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "kubeconfig" {
+			explicitKubeconfig = true
+		}
+	})
+	if *checkKubeconfig {
+		// The credentials are read from the kubeconfig files themselves, no
+		// cluster is involved.
+		rules := loadingRules(*kubeconfig, explicitKubeconfig)
+		targets, err := kubeconfigTargets(rules)
+		if err != nil {
+			fatal(err, "Reading the kubeconfig", "kubeconfig", kubeconfigName(rules))
+		}
+		s.check = secretChecker(nil)
+		results := s.scan(context.Background(), targets)
+		if threshold != nil && threshold.exceeded(p, results, time.Now()) {
+			exit(exitThresholdExceeded)
+		}
+		return
+	}
+
+	if *webhook {
+		deny, err := parseAdmissionDeny(*webhookDeny)
+		if err != nil {
+			fatal(err, "Invalid flags")
+		}
+		if *webhookCertFile == "" || *webhookKeyFile == "" {
+			fatal(errors.New("-webhook needs -webhook-cert-file and -webhook-key-file"), "Invalid flags")
+		}
+		config, err := buildConfig(*kubeconfig, explicitKubeconfig, &client)
+		if err != nil {
+			fatal(err, "Loading the client configuration")
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			fatal(err, "Creating the clientset")
+		}
+		core := clientset.CoreV1().RESTClient()
+		mux := http.NewServeMux()
+		mux.Handle("/validate", &admissionWebhook{
+			secretCertificate: func(ctx context.Context, namespace, name string) (*certificate, error) {
+				return secretCertificate(ctx, core, namespace, name)
+			},
+			namespaceAnnotations: func(ctx context.Context, namespace string) map[string]string {
+				ns := &v1.Namespace{}
+				err := core.Get().Resource("namespaces").Name(namespace).Context(ctx).Do().Into(ns)
+				if err != nil {
+					klog.V(2).InfoS("Reading the namespace failed", "namespace", namespace, "err", err)
+					return nil
+				}
+				return ns.Annotations
+			},
+			policy: p,
+			deny:   deny,
+			now:    time.Now,
+		})
+		klog.InfoS("Serving the admission webhook", "addr", *webhookAddr)
+		if err := http.ListenAndServeTLS(*webhookAddr, *webhookCertFile, *webhookKeyFile, mux); err != nil {
+			fatal(err, "Serving the admission webhook")
+		}
+		return
+	}
+
+	contexts := []string{""}
+	if *allContexts || *contextsFlag != "" {
+		var names []string
+		if *contextsFlag != "" {
+			names = strings.Split(*contextsFlag, ",")
+		}
+		rules := loadingRules(*kubeconfig, explicitKubeconfig)
+		if contexts, err = kubeconfigContexts(rules, names, *allContexts); err != nil {
+			fatal(err, "Reading the contexts to scan", "kubeconfig", kubeconfigName(rules))
+		}
+	}
+
+	clusters, err := newClusters(*kubeconfig, explicitKubeconfig, contexts, s, clusterOptions{
+		resources: strings.Split(*resources, ","),
+		sources: sourceOptions{
+			ports:        ports,
+			viaStatus:    *via == "status",
+			dialWebhooks: *dialConversionWebhooks,
+			crdTargets:   crdTargets,
+		},
+		client:                &client,
+		filter:                filter,
+		resync:                *resync,
+		listPageSize:          *listPageSize,
+		certSource:            *certSource,
+		dial:                  dials.check,
+		controlPlane:          *controlPlane,
+		controlPlaneEndpoints: controlPlaneEndpoints,
+		events:                *events,
+		annotate:              *annotate,
+		certificateReports:    *certificateReports,
+		renewalOverdue:        overdue,
+		operator:              *operator,
+		policy:                p,
+		recheck:               recheck,
+	})
 	if err != nil {
-		panic(err.Error())
-	}
-	var (
-		c *certs.Cert
-	)
-	// there must be a better way!
-	// TODO: prometheus exporter
-	for _, s := range ingress.Items {
-		for p := range s.Spec.TLS {
-			for _, h := range s.Spec.TLS[p].Hosts {
-				port := h + ":443"
-				c, _ = certs.ParseRemoteCertificate(port, 10)
-				log.Println(h, c.Jsonify())
+		fatal(err, "Connecting to the cluster", "resources", *resources)
+	}
+	// With a single cluster its checker and notifiers are used as they are.
+	if len(clusters) == 1 {
+		s.check = clusters[0].check
+		s.notifiers = append(s.notifiers, clusters[0].notifiers...)
+	} else {
+		s.check = clusterChecker(clusters)
+		if n := newClusterNotifier(clusters, p); n != nil {
+			s.notifiers = append(s.notifiers, n)
+		}
+	}
+
+	ctx, cancel := cancelOnSignal()
+	defer cancel()
+
+	if !*watch {
+		signalled := ctx
+		for _, c := range clusters {
+			c.factories.start(ctx.Done())
+		}
+		if *overallDeadline > 0 {
+			var cancelDeadline context.CancelFunc
+			ctx, cancelDeadline = context.WithTimeout(ctx, *overallDeadline)
+			defer cancelDeadline()
+		}
+		targets, err := clusterTargets(ctx, clusters)
+		if err != nil && signalled.Err() != nil {
+			klog.InfoS("Scan interrupted before any host was checked")
+			exit(exitInterrupted)
+		}
+		if err != nil {
+			fatal(err, "Scan failed")
+		}
+		results := s.scan(ctx, targets)
+		if signalled.Err() != nil {
+			exit(exitInterrupted)
+		}
+		if threshold != nil && threshold.exceeded(p, results, time.Now()) {
+			exit(exitThresholdExceeded)
+		}
+		return
+	}
+
+	// The metrics and the probes share a server when given the same
+	// address.
+	muxes := map[string]*http.ServeMux{}
+	serveMux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if exporter != nil {
+		serveMux(*metricsAddr).Handle("/metrics", exporter)
+	}
+	if h != nil {
+		mux := serveMux(*adminAddr)
+		mux.HandleFunc("/healthz", h.serveHealthz)
+		mux.HandleFunc("/readyz", h.serveReadyz)
+		if *profiling {
+			handlePprof(mux)
+		}
+		// Standby replicas are ready to take over, without any scan.
+		le.standby = h.idle
+	}
+	for addr, mux := range muxes {
+		addr, mux := addr, mux
+		go func() {
+			klog.InfoS("Serving HTTP", "addr", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				fatal(err, "Serving HTTP", "addr", addr)
 			}
+		}()
+	}
+	run := func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for _, c := range clusters {
+			c.factories.start(ctx.Done())
+			wg.Add(1)
+			go func(c *cluster) {
+				defer wg.Done()
+				c.controller.Run(ctx, *workers)
+			}(c)
 		}
+		wg.Wait()
 	}
+	// SIGHUP restarts the process once the configuration file parses, so
+	// that every option is read again from scratch.
+	var reload int32
+	if *configFlag != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if _, err := loadConfigFile(*configFlag, flag.CommandLine); err != nil {
+					klog.ErrorS(err, "Not reloading the configuration file", "file", *configFlag)
+					continue
+				}
+				klog.InfoS("Reloading the configuration file", "file", *configFlag)
+				atomic.StoreInt32(&reload, 1)
+				cancel()
+				return
+			}
+		}()
+	}
+	defer func() {
+		if atomic.LoadInt32(&reload) == 1 {
+			runProfiles.stop()
+			klog.Flush()
+			if err := reexec(); err != nil {
+				fatal(err, "Reloading the configuration file", "file", *configFlag)
+			}
+		}
+	}()
+	if !*leaderElect {
+		run(ctx)
+		return
+	}
+	// The Lease is kept in the cluster of the current context, where the
+	// replicas run, even when other contexts are scanned.
+	config, err := buildConfig(*kubeconfig, explicitKubeconfig, &client)
+	if err != nil {
+		fatal(err, "Loading the client configuration")
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fatal(err, "Creating the clientset")
+	}
+	if le.namespace == "" {
+		le.namespace = podNamespace()
+	}
+	if err := runAsLeader(ctx, clientset, le, run); err != nil {
+		fatal(err, "Leader election failed")
+	}
+}
+
+// fatal logs err and exits with exitFailure.
+func fatal(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorS(err, msg, keysAndValues...)
+	exit(exitFailure)
+}
+
+// cancelOnSignal returns a context cancelled by Ctrl-C or SIGTERM, which
+// cancels the checks in flight so that the results gathered so far are
+// reported; a second one exits right away.
+func cancelOnSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		klog.InfoS("Interrupted, reporting the results gathered so far", "signal", sig.String())
+		cancel()
+		<-signals
+		exit(exitInterrupted)
+	}()
+	return ctx, cancel
+}
+
+// runProfiles are the profiles of -cpuprofile and -memprofile, if any.
+var runProfiles *profiles
+
+// exit writes the profiles, flushes the log and exits with code.
+func exit(code int) {
+	runProfiles.stop()
+	klog.Flush()
+	os.Exit(code)
 }
 
 func homeDir() string {
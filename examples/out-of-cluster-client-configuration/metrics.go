@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsContentType is the content type of the Prometheus text format
+// written by writeMetrics.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricFamily is a gauge, unless it is typed otherwise, and its samples.
+type metricFamily struct {
+	name string
+	help string
+	// typ is the Prometheus type of the family, gauge when empty.
+	typ     string
+	samples []sample
+}
+
+// sample is a single value of a metric family.
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// scanMetrics returns the metrics of results, classified by p at now: the
+// expiry of the certificate of every host, then gauges about all of them and
+// about the scanner itself, so that a single panel tells whether it is healthy
+// and when the next certificate expires. duration is how long the latest scan
+// took and lastSuccess when the latest one that was not interrupted ended, if
+// any did.
+func scanMetrics(results []result, p policy, now time.Time, duration time.Duration, lastSuccess time.Time) []metricFamily {
+	expiry := metricFamily{
+		name: "cert_check_certificate_expiry_timestamp_seconds",
+		help: "When the certificate of the host expires, in seconds since the epoch.",
+	}
+	grade := metricFamily{
+		name: "cert_check_host_grade",
+		help: "Always 1, with the grade of the host as the grade label.",
+	}
+	soonest := metricFamily{
+		name: "cert_check_soonest_expiry_timestamp_seconds",
+		help: "When the first certificate of all hosts expires, in seconds since the epoch.",
+	}
+	var failing, failed int
+	var first time.Time
+	for _, r := range results {
+		sev, g := p.grade(r, now)
+		if sev != severityOK {
+			failing++
+		}
+		labels := metricLabels(r.target)
+		labels["grade"] = g
+		grade.samples = append(grade.samples, sample{labels: labels, value: 1})
+		if r.err != nil {
+			failed++
+		}
+		if r.certificate != nil {
+			expiry.samples = append(expiry.samples, sample{labels: metricLabels(r.target), value: float64(r.certificate.NotAfter.Unix())})
+			if first.IsZero() || r.certificate.NotAfter.Before(first) {
+				first = r.certificate.NotAfter
+			}
+		}
+	}
+	if !first.IsZero() {
+		soonest.samples = []sample{{value: float64(first.Unix())}}
+	}
+	lastSuccessFamily := metricFamily{
+		name: "cert_check_last_success_timestamp_seconds",
+		help: "When the latest scan that was not interrupted ended, in seconds since the epoch.",
+	}
+	if !lastSuccess.IsZero() {
+		lastSuccessFamily.samples = []sample{{value: float64(lastSuccess.Unix())}}
+	}
+	return []metricFamily{
+		expiry,
+		grade,
+		{
+			name:    "cert_check_hosts",
+			help:    "Number of hosts checked.",
+			samples: []sample{{value: float64(len(results))}},
+		},
+		{
+			name:    "cert_check_hosts_failing",
+			help:    "Number of hosts that need attention, including the ones that could not be checked.",
+			samples: []sample{{value: float64(failing)}},
+		},
+		{
+			name:    "cert_check_errors",
+			help:    "Number of hosts whose certificate could not be checked.",
+			samples: []sample{{value: float64(failed)}},
+		},
+		soonest,
+		{
+			name:    "cert_check_scan_duration_seconds",
+			help:    "How long the latest scan took.",
+			samples: []sample{{value: duration.Seconds()}},
+		},
+		lastSuccessFamily,
+	}
+}
+
+// metricLabels returns the labels of the samples about t, with its cluster
+// when several clusters are scanned.
+func metricLabels(t target) map[string]string {
+	labels := map[string]string{
+		"namespace": t.namespace,
+		"kind":      t.kind,
+		"object":    t.object,
+		"host":      t.name(),
+	}
+	if t.cluster != "" {
+		labels["cluster"] = t.cluster
+	}
+	return labels
+}
+
+// writeMetrics writes families to w in the Prometheus text format.
+func writeMetrics(w io.Writer, families []metricFamily) error {
+	b := bufio.NewWriter(w)
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		b.WriteString("# HELP " + f.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+		typ := f.typ
+		if typ == "" {
+			typ = "gauge"
+		}
+		b.WriteString("# TYPE " + f.name + " " + typ + "\n")
+		for _, s := range f.samples {
+			b.WriteString(f.name)
+			if len(s.labels) > 0 {
+				names := make([]string, 0, len(s.labels))
+				for name := range s.labels {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				b.WriteString("{")
+				for i, name := range names {
+					if i > 0 {
+						b.WriteString(",")
+					}
+					b.WriteString(name + `="` + labelValueEscaper.Replace(s.labels[name]) + `"`)
+				}
+				b.WriteString("}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(s.value, 'f', -1, 64) + "\n")
+		}
+	}
+	return b.Flush()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
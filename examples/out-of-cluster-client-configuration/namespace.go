@@ -0,0 +1,53 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/api/core/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// mayWatchNamespaces reports whether client may list and watch namespaces.
+// The annotations of namespaces are only read when it may, so that the
+// permission stays optional.
+func mayWatchNamespaces(client authorizationclient.SelfSubjectAccessReviewsGetter) bool {
+	for _, verb := range []string{"list", "watch"} {
+		review, err := client.SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: verb, Resource: "namespaces"},
+			},
+		})
+		if err != nil || !review.Status.Allowed {
+			klog.V(2).InfoS("Namespaces cannot be watched, their annotations are ignored", "verb", verb, "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceAnnotations returns the annotations of the namespace in
+// namespaces, or nil if it is not known.
+func namespaceAnnotations(namespaces cache.SharedIndexInformer, namespace string) map[string]string {
+	obj, exists, err := namespaces.GetIndexer().GetByKey(namespace)
+	if err != nil || !exists {
+		return nil
+	}
+	return obj.(*v1.Namespace).Annotations
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWarnBefore(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "acme", Annotations: map[string]string{warnBeforeAnnotation: "14d"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "internal"}},
+	)
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	c := &controller{}
+	c.watchNamespaces(factory.Core().V1().Namespaces().Informer())
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	if _, err := c.targets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ingress := func(namespace, annotation string) target {
+		ing := &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web"},
+			Spec:       v1beta1.IngressSpec{TLS: []v1beta1.IngressTLS{{Hosts: []string{"web.example.com"}}}},
+		}
+		if annotation != "" {
+			ing.Annotations = map[string]string{warnBeforeAnnotation: annotation}
+		}
+		return c.inCluster(ingressTargets(ing, []int{defaultPort}, false))[0]
+	}
+	p := policy{days: 30}
+	// A 90 days ACME certificate is renewed 30 days before it expires.
+	acme := &certificate{NotAfter: now.AddDate(0, 0, 20)}
+	// A long-lived internal certificate needs a heads-up months before.
+	internal := &certificate{NotAfter: now.AddDate(0, 2, 0)}
+
+	tests := []struct {
+		target      target
+		certificate *certificate
+		want        severity
+	}{
+		{ingress("acme", ""), acme, severityOK},
+		{ingress("internal", ""), acme, severityWarning},
+		// The annotation of the Ingress takes precedence over the one of
+		// its namespace.
+		{ingress("acme", "30d"), acme, severityWarning},
+		{ingress("internal", "90d"), internal, severityWarning},
+		{ingress("internal", ""), internal, severityOK},
+		// An invalid annotation falls back to the policy.
+		{ingress("internal", "soon"), acme, severityWarning},
+		{ingress("internal", "0d"), internal, severityOK},
+	}
+	for _, test := range tests {
+		if got := p.severity(result{target: test.target, certificate: test.certificate}, now); got != test.want {
+			t.Errorf("%s with a warning window of %v: expected %v, got %v", test.target.namespace, test.target.warnBefore, test.want, got)
+		}
+	}
+
+	if next, ok := nextCheck([]result{{target: ingress("acme", ""), certificate: acme}}, p, now); !ok || next < 6*24*time.Hour || next > 7*24*time.Hour {
+		t.Errorf("expected the next check in 6 days, got %v", next)
+	}
+}
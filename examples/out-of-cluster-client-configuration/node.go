@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+
+	"k8s.io/api/core/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+)
+
+// nodeKind is the kind of the targets of kubelets.
+const nodeKind = "Node"
+
+// defaultKubeletPort is the port kubelets serve their API on unless their
+// node says otherwise.
+const defaultKubeletPort = 10250
+
+// nodeAddressTypes are the types of node addresses kubelets are dialed at,
+// most preferred first.
+var nodeAddressTypes = []v1.NodeAddressType{v1.NodeInternalIP, v1.NodeExternalIP, v1.NodeInternalDNS, v1.NodeExternalDNS, v1.NodeHostName}
+
+// nodeSource returns a source checking the serving certificate of the kubelet
+// of every node served by informer, verified against clusterCA.
+func nodeSource(informer coreinformers.NodeInformer, clusterCA []byte) *source {
+	return &source{
+		kind:     nodeKind,
+		informer: informer.Informer(),
+		targets: func(obj interface{}) []target {
+			return nodeTargets(obj.(*v1.Node), clusterCA)
+		},
+		changed: func(old, new interface{}) bool {
+			// Nodes update their status all the time; only their addresses
+			// and their kubelet port matter.
+			oldNode := old.(*v1.Node)
+			newNode := new.(*v1.Node)
+			return !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) ||
+				oldNode.Status.DaemonEndpoints != newNode.Status.DaemonEndpoints
+		},
+	}
+}
+
+// nodeTargets returns the target of the kubelet of node, dialed at the most
+// preferred of its addresses and verified for the name of node. Nodes without
+// an address have none.
+func nodeTargets(node *v1.Node, clusterCA []byte) []target {
+	port := int(node.Status.DaemonEndpoints.KubeletEndpoint.Port)
+	if port == 0 {
+		port = defaultKubeletPort
+	}
+	for _, addressType := range nodeAddressTypes {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType && address.Address != "" {
+				return []target{{
+					kind:     nodeKind,
+					object:   node.Name,
+					host:     node.Name,
+					port:     port,
+					address:  address.Address,
+					rootsPEM: clusterCA,
+					ref:      objectReference("v1", nodeKind, node),
+				}}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeTargets(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "worker-1"},
+				{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.11"},
+			},
+		},
+	}
+
+	targets := nodeTargets(node, []byte("CA"))
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %+v", targets)
+	}
+	if got := targets[0]; got.host != "worker-1" || got.port != defaultKubeletPort || got.address != "10.0.0.11" || string(got.rootsPEM) != "CA" {
+		t.Errorf("unexpected target %+v", got)
+	}
+
+	node.Status.DaemonEndpoints.KubeletEndpoint.Port = 10443
+	if got := nodeTargets(node, nil); got[0].name() != "worker-1:10443" {
+		t.Errorf("expected the kubelet port of the node, got %+v", got[0])
+	}
+
+	node.Status.Addresses = nil
+	if got := nodeTargets(node, nil); len(got) != 0 {
+		t.Errorf("expected no target for a node without an address, got %+v", got)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// notifierFactories build the notifiers of every kind -notify accepts from
+// their options. A new destination only needs a notifier and an entry here.
+var notifierFactories = map[string]func(o *notifierOptions) (notifier, error){
+	"slack": func(o *notifierOptions) (notifier, error) {
+		url, err := o.required("url")
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := loadTemplate("slack", o.get("template"), defaultSlackTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("loading the Slack template: %v", err)
+		}
+		return &slackNotifier{url: url, template: tmpl, client: notifyClient}, nil
+	},
+	"webhook": func(o *notifierOptions) (notifier, error) {
+		url, err := o.required("url")
+		if err != nil {
+			return nil, err
+		}
+		n := &webhookNotifier{url: url, client: notifyClient}
+		if file := o.get("template"); file != "" {
+			if n.template, err = loadTemplate("webhook", file, ""); err != nil {
+				return nil, fmt.Errorf("loading the webhook template: %v", err)
+			}
+		}
+		return n, nil
+	},
+	"email": func(o *notifierOptions) (notifier, error) {
+		server, err := o.required("server")
+		if err != nil {
+			return nil, err
+		}
+		format := o.get("format")
+		if format == "" {
+			format = "text"
+		}
+		return newEmailNotifier(server, o.get("username"), os.Getenv("SMTP_PASSWORD"), o.get("from"), o.all("to"), format, o.get("template"))
+	},
+	"alertmanager": func(o *notifierOptions) (notifier, error) {
+		url, err := o.required("url")
+		if err != nil {
+			return nil, err
+		}
+		n := &alertmanagerNotifier{url: url, resolveAfter: 25 * time.Hour, client: notifyClient}
+		if value := o.get("resolve-after"); value != "" {
+			if n.resolveAfter, err = parseDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid resolve-after: %v", err)
+			}
+		}
+		return n, nil
+	},
+	"stdout": func(o *notifierOptions) (notifier, error) {
+		n := &writerNotifier{w: os.Stdout}
+		if file := o.get("template"); file != "" {
+			var err error
+			if n.template, err = loadTemplate("stdout", file, ""); err != nil {
+				return nil, fmt.Errorf("loading the stdout template: %v", err)
+			}
+		}
+		return n, nil
+	},
+}
+
+// notifierOptions are the options of a notifier, as given to -notify:
+// kind[,key=value...], e.g. slack,url=https://hooks.slack.com/...,min-severity=error.
+// Keys may be repeated.
+type notifierOptions struct {
+	kind   string
+	values map[string][]string
+	used   map[string]bool
+}
+
+// parseNotifierOptions parses the value of a -notify flag.
+func parseNotifierOptions(s string) (*notifierOptions, error) {
+	parts := strings.Split(s, ",")
+	o := &notifierOptions{kind: strings.TrimSpace(parts[0]), values: map[string][]string{}}
+	for _, part := range parts[1:] {
+		i := strings.Index(part, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid notifier option %q: must be key=value", part)
+		}
+		key := strings.TrimSpace(part[:i])
+		o.values[key] = append(o.values[key], part[i+1:])
+	}
+	return o, nil
+}
+
+// get returns the last value of key, or "" if it is not set.
+func (o *notifierOptions) get(key string) string {
+	values := o.all(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// all returns every value of key.
+func (o *notifierOptions) all(key string) []string {
+	if o.used == nil {
+		o.used = map[string]bool{}
+	}
+	o.used[key] = true
+	return o.values[key]
+}
+
+// required returns the value of key, failing if it is not set.
+func (o *notifierOptions) required(key string) (string, error) {
+	if value := o.get(key); value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf("%s notifications need %s", o.kind, key)
+}
+
+// newNotifier builds the notifier of o. With a min-severity option, it is
+// only notified about the findings at least that severe.
+func newNotifier(o *notifierOptions, p policy) (notifier, error) {
+	factory, ok := notifierFactories[o.kind]
+	if !ok {
+		kinds := make([]string, 0, len(notifierFactories))
+		for kind := range notifierFactories {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return nil, fmt.Errorf("unknown notifier %q: must be one of %s", o.kind, strings.Join(kinds, ", "))
+	}
+	min := severityWarning
+	if value := o.get("min-severity"); value != "" {
+		var err error
+		if min, err = parseSeverity(value); err != nil {
+			return nil, err
+		}
+	}
+	n, err := factory(o)
+	if err != nil {
+		return nil, err
+	}
+	for key := range o.values {
+		if !o.used[key] {
+			return nil, fmt.Errorf("unknown option %q of %s notifications", key, o.kind)
+		}
+	}
+	if min == severityWarning {
+		return n, nil
+	}
+	return &severityFilter{notifier: n, min: min, policy: p}, nil
+}
+
+// parseSeverity parses the lower case name of a severity other than OK.
+func parseSeverity(s string) (severity, error) {
+	for sev := severityWarning; sev <= severityError; sev++ {
+		if strings.ToLower(sev.String()) == s {
+			return sev, nil
+		}
+	}
+	return severityOK, fmt.Errorf("invalid min-severity %q: must be warning, expired, revoked or error", s)
+}
+
+// severityFilter only notifies its notifier about the findings at least as
+// severe as min. The hosts of other findings count as fine, so that
+// resolving notifiers withdraw what they notified about them.
+type severityFilter struct {
+	notifier
+	min    severity
+	policy policy
+}
+
+func (f *severityFilter) resolvesFindings() {}
+
+func (f *severityFilter) notify(ctx context.Context, s *summary) error {
+	results := s.results
+	_, resolving := f.notifier.(resolvingNotifier)
+	if !resolving && s.changes != nil {
+		results = s.changes.results
+	}
+	filtered := summarize(results, f.policy, s.Time, f.min)
+	filtered.duration, filtered.Interrupted, filtered.Unchecked = s.duration, s.Interrupted, s.Unchecked
+	filtered.scope, filtered.object = s.scope, s.object
+	if !resolving && filtered.Findings == 0 {
+		return nil
+	}
+	return f.notifier.notify(ctx, filtered)
+}
+
+// writerNotifier writes summaries to w, as JSON or as rendered by a
+// template.
+type writerNotifier struct {
+	w io.Writer
+	// template renders the summary, which is written as JSON when it is
+	// nil.
+	template *template.Template
+}
+
+func (n *writerNotifier) notify(ctx context.Context, s *summary) error {
+	if n.template != nil {
+		return n.template.Execute(n.w, s)
+	}
+	return json.NewEncoder(n.w).Encode(s)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewNotifier(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		wantErr string
+		// round notifiers are only sent scan rounds in watch mode.
+		round bool
+	}{
+		{value: "slack,url=https://hooks.slack.com/x", round: true},
+		{value: "webhook,url=https://example.com/hook,min-severity=error", round: true},
+		{value: "email,server=smtp:25,from=a@example.com,to=b@example.com,to=payments=c@example.com", round: true},
+		{value: "alertmanager,url=http://alertmanager:9093,resolve-after=2d,min-severity=error"},
+		{value: "stdout"},
+		{value: "pager,url=x", wantErr: "unknown notifier"},
+		{value: "slack", wantErr: "need url"},
+		{value: "slack,url=x,channel=ops", wantErr: `unknown option "channel"`},
+		{value: "stdout,min-severity=fatal", wantErr: "invalid min-severity"},
+		{value: "webhook,url", wantErr: "must be key=value"},
+	} {
+		o, err := parseNotifierOptions(tc.value)
+		var n notifier
+		if err == nil {
+			n, err = newNotifier(o, policy{days: 30})
+		}
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.value, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.value, tc.wantErr, err)
+		}
+		if err == nil && perRound(n) != tc.round {
+			t.Errorf("%s: expected perRound to be %v", tc.value, tc.round)
+		}
+	}
+}
+
+func TestSeverityFilter(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "a", host: "down.example.com"}, err: errors.New("connection refused")},
+		{target: target{namespace: "a", host: "soon.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+	}
+	var buf bytes.Buffer
+	n := &severityFilter{notifier: &writerNotifier{w: &buf}, min: severityError, policy: policy{days: 30}}
+	s := newSummary(results, n.policy, now)
+
+	if err := n.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "down.example.com") || strings.Contains(out, "soon.example.com") {
+		t.Errorf("expected only down.example.com to be notified, got %s", out)
+	}
+
+	buf.Reset()
+	if err := n.notify(context.Background(), newSummary(results[1:], n.policy, now)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no notification without findings at ERROR, got %s", buf.String())
+	}
+}
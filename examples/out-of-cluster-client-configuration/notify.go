@@ -0,0 +1,314 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"text/template"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// notifier delivers the summary of a scan somewhere.
+type notifier interface {
+	notify(ctx context.Context, s *summary) error
+}
+
+// resolvingNotifier is implemented by notifiers that withdraw what they
+// notified about once the hosts are fine again. Unlike other notifiers they
+// are sent summaries without findings too.
+type resolvingNotifier interface {
+	notifier
+	resolvesFindings()
+}
+
+// roundNotifier is implemented by notifiers that are only sent the summaries
+// of whole scans: in watch mode, the ones of scan rounds instead of the ones
+// of every object checked.
+type roundNotifier interface {
+	notifier
+	perRound()
+}
+
+// summaryScope is what a summary is about.
+type summaryScope int
+
+const (
+	// scopeScan is a one-shot scan, sent to every notifier.
+	scopeScan summaryScope = iota
+	// scopeObject is the scan of a single object in watch mode, not sent to
+	// round notifiers.
+	scopeObject
+	// scopeRound is a scan round in watch mode, only sent to round
+	// notifiers.
+	scopeRound
+)
+
+// summary lists the results of a scan that need attention, grouped by
+// cluster and namespace. It is what message templates are executed against.
+type summary struct {
+	Time       time.Time          `json:"time"`
+	Findings   int                `json:"findings"`
+	Namespaces []namespaceSummary `json:"namespaces"`
+	// Interrupted is set if the scan was cut short, e.g. by a signal or
+	// -overall-deadline, before every host was checked. Unchecked is the
+	// number of hosts left out of the summary because of it.
+	Interrupted bool `json:"interrupted,omitempty"`
+	Unchecked   int  `json:"unchecked,omitempty"`
+	// fine are the targets that were checked and do not need attention.
+	fine []target
+	// results are the results the summary is made of.
+	results []result
+	// duration is how long the scan of results took, if known.
+	duration time.Duration
+	// changes, with -diff-with, is the summary of the results that changed
+	// since the previous scan, fine ones included. It is what notifiers
+	// that do not resolve findings are sent instead.
+	changes *summary
+	// scope is what the summary is about.
+	scope summaryScope
+	// object, for the scan of a single object in watch mode, is the object
+	// whose results replace the ones it had before: without results once
+	// it is deleted or has no hosts left. Nil for the static targets.
+	object *objectKey
+}
+
+type namespaceSummary struct {
+	// Cluster is the context of the cluster of the namespace when several
+	// clusters are scanned at once.
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Findings  []finding `json:"findings"`
+}
+
+// finding is a single host that needs attention.
+type finding struct {
+	Cluster       string    `json:"cluster,omitempty"`
+	Namespace     string    `json:"namespace"`
+	Kind          string    `json:"kind"`
+	Object        string    `json:"object"`
+	Host          string    `json:"host"`
+	Severity      string    `json:"severity"`
+	Grade         string    `json:"grade,omitempty"`
+	NotAfter      time.Time `json:"expires,omitempty"`
+	DaysRemaining int       `json:"daysRemaining"`
+	Error         string    `json:"error,omitempty"`
+	// Failure is the step checking the host failed at: dns, connect, tls
+	// or verify.
+	Failure string `json:"failure,omitempty"`
+	// Resolved are the addresses the host resolved to.
+	Resolved []string `json:"resolved,omitempty"`
+	// Change is what changed about the host since the previous scan with
+	// -diff-with: new, renewed, resolved or the severity it changed to.
+	Change string `json:"change,omitempty"`
+	// Attempts is the number of times a host that was retried was dialed.
+	Attempts int `json:"attempts,omitempty"`
+	// CertManager is the status of the cert-manager Certificate renewing
+	// the certificate, if any.
+	CertManager *certManagerStatus `json:"certManager,omitempty"`
+	// ref is the object the host is taken from, if any.
+	ref *v1.ObjectReference
+}
+
+// newSummary returns the summary of results.
+func newSummary(results []result, p policy, now time.Time) *summary {
+	return summarize(results, p, now, severityWarning)
+}
+
+// summarize returns the summary of results whose findings are at least as
+// severe as min, the hosts of the others count as fine. The hosts an
+// interrupted scan did not check are neither, they are only counted.
+func summarize(results []result, p policy, now time.Time, min severity) *summary {
+	s := &summary{Time: now}
+	byNamespace := map[namespaceKey][]finding{}
+	for _, r := range results {
+		if _, ok := r.err.(interruptedError); ok {
+			s.Unchecked++
+			continue
+		}
+		s.results = append(s.results, r)
+		sev, grade := p.grade(r, now)
+		if sev < min {
+			s.fine = append(s.fine, r.target)
+			continue
+		}
+		f := finding{
+			Cluster:     r.cluster,
+			Namespace:   r.namespace,
+			Kind:        r.kind,
+			Object:      r.object,
+			Host:        r.name(),
+			Severity:    sev.String(),
+			Grade:       grade,
+			Failure:     r.failure(),
+			Resolved:    r.resolved,
+			Change:      r.change,
+			CertManager: r.certManager,
+			ref:         r.ref,
+		}
+		if r.err != nil {
+			f.Error = r.err.Error()
+		}
+		if r.attempts > 1 {
+			f.Attempts = r.attempts
+		}
+		if r.certificate != nil {
+			f.NotAfter = r.certificate.NotAfter
+			f.DaysRemaining = int(r.certificate.NotAfter.Sub(now).Hours() / 24)
+		}
+		key := namespaceKey{r.cluster, r.namespace}
+		byNamespace[key] = append(byNamespace[key], f)
+		s.Findings++
+	}
+
+	for key, findings := range byNamespace {
+		s.Namespaces = append(s.Namespaces, namespaceSummary{Cluster: key.cluster, Namespace: key.namespace, Findings: findings})
+	}
+	sort.Slice(s.Namespaces, func(i, j int) bool {
+		a, b := s.Namespaces[i], s.Namespaces[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Namespace < b.Namespace
+	})
+	return s
+}
+
+// namespaceKey is a namespace of a cluster.
+type namespaceKey struct {
+	cluster, namespace string
+}
+
+// notifyAll delivers s to every notifier, logging the ones that fail.
+func notifyAll(ctx context.Context, notifiers []notifier, s *summary) {
+	for _, n := range notifiers {
+		if perRound(n) {
+			if s.scope == scopeObject {
+				continue
+			}
+		} else if s.scope == scopeRound {
+			continue
+		}
+		sum := s
+		if _, ok := n.(resolvingNotifier); !ok {
+			if s.changes != nil {
+				sum = s.changes
+			}
+			if sum.Findings == 0 {
+				continue
+			}
+		}
+		if err := n.notify(ctx, sum); err != nil {
+			klog.ErrorS(err, "Notification failed", "notifier", fmt.Sprintf("%T", n))
+			continue
+		}
+		klog.V(2).InfoS("Notification sent", "notifier", fmt.Sprintf("%T", n), "findings", sum.Findings)
+	}
+}
+
+// perRound reports whether n, or the notifier it filters, is a round
+// notifier.
+func perRound(n notifier) bool {
+	if f, ok := n.(*severityFilter); ok {
+		n = f.notifier
+	}
+	_, ok := n.(roundNotifier)
+	return ok
+}
+
+// executor is implemented by both text and HTML templates.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// only returns the part of s about namespaces, or nil if there is none. A
+// nil list of namespaces returns s itself.
+func (s *summary) only(namespaces []string) *summary {
+	if namespaces == nil {
+		return s
+	}
+	filtered := &summary{Time: s.Time}
+	for _, ns := range s.Namespaces {
+		for _, name := range namespaces {
+			if ns.Namespace == name {
+				filtered.Namespaces = append(filtered.Namespaces, ns)
+				filtered.Findings += len(ns.Findings)
+				break
+			}
+		}
+	}
+	if filtered.Findings == 0 {
+		return nil
+	}
+	return filtered
+}
+
+// loadTemplate parses the message template in file, or text when file is
+// empty.
+func loadTemplate(name, file, text string) (*template.Template, error) {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	return template.New(name).Parse(text)
+}
+
+func render(tmpl executor, s *summary) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// notifyTimeout bounds every notification sent to an endpoint, so that an
+// endpoint that stopped responding does not hold up the scans.
+const notifyTimeout = 30 * time.Second
+
+// notifyClient is the HTTP client of the notifiers posting to endpoints.
+var notifyClient = &http.Client{Timeout: notifyTimeout}
+
+// post sends body to url and fails on any non-2xx response.
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
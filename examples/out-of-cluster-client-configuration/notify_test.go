@@ -0,0 +1,196 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSummary(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "b", kind: "Ingress", object: "web", host: "b.example.com"}, err: errors.New("connection refused")},
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "ok.example.com"}, certificate: &certificate{NotAfter: now.AddDate(1, 0, 0)}},
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "soon.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+	}
+
+	s := newSummary(results, policy{days: 30}, now)
+	if s.Findings != 2 {
+		t.Errorf("expected 2 findings, got %d", s.Findings)
+	}
+	if len(s.Namespaces) != 2 || s.Namespaces[0].Namespace != "a" || s.Namespaces[1].Namespace != "b" {
+		t.Fatalf("expected namespaces a and b, got %+v", s.Namespaces)
+	}
+	if f := s.Namespaces[0].Findings[0]; f.Host != "soon.example.com" || f.Severity != "WARNING" || f.DaysRemaining != 10 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if f := s.Namespaces[1].Findings[0]; f.Severity != "ERROR" || f.Error != "connection refused" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+
+	if len(s.fine) != 1 || s.fine[0].host != "ok.example.com" {
+		t.Errorf("expected ok.example.com to be fine, got %+v", s.fine)
+	}
+	if s := newSummary(results[1:2], policy{days: 30}, now); s.Findings != 0 || len(s.Namespaces) != 0 {
+		t.Errorf("expected no findings, got %+v", s)
+	}
+}
+
+func TestNewSummaryInterrupted(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "soon.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "slow.example.com"}, err: interruptedError{context.Canceled}},
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "queued.example.com"}, err: interruptedError{context.Canceled}},
+	}
+	s := newSummary(results, policy{days: 30}, now)
+	if s.Findings != 1 || s.Unchecked != 2 || len(s.results) != 1 {
+		t.Errorf("expected the unchecked hosts not to be findings, got %+v", s)
+	}
+}
+
+// deadlineNotifier records whether the context it is notified with is done.
+type deadlineNotifier struct {
+	done bool
+	s    *summary
+}
+
+func (n *deadlineNotifier) notify(ctx context.Context, s *summary) error {
+	n.done = ctx.Err() != nil
+	n.s = s
+	return nil
+}
+
+func TestScanInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// The scan is interrupted once the first host, whose certificate
+	// expires soon, was checked.
+	check := func(ctx context.Context, target target) result {
+		if ctx.Err() != nil {
+			return result{target: target, err: ctx.Err()}
+		}
+		cancel()
+		return result{target: target, certificate: &certificate{NotAfter: time.Now().AddDate(0, 0, 10)}}
+	}
+	n := &deadlineNotifier{}
+	s := &scanner{
+		check:        check,
+		concurrency:  1,
+		policy:       policy{days: 30},
+		report:       func([]result, policy) {},
+		notifiers:    []notifier{n},
+		flushTimeout: time.Minute,
+	}
+	s.scan(ctx, []target{{host: "shop.example.com"}, {host: "api.example.com"}, {host: "www.example.com"}})
+	if n.s == nil || !n.s.Interrupted || n.s.Findings != 1 || n.s.Unchecked != 2 {
+		t.Fatalf("expected an interrupted summary with 1 finding and 2 unchecked hosts, got %+v", n.s)
+	}
+	if n.done {
+		t.Error("expected the notifiers to be given -flush-timeout to report")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	s.flushTimeout = 0
+	s.scan(ctx, []target{{host: "shop.example.com"}, {host: "api.example.com"}})
+	if !n.done {
+		t.Error("expected the notifiers to be given the interrupted context without -flush-timeout")
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var text string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct{ Text string }
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decoding message: %v", err)
+		}
+		text = msg.Text
+	}))
+	defer server.Close()
+
+	tmpl, err := loadTemplate("slack", "", defaultSlackTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &slackNotifier{url: server.URL, template: tmpl, client: server.Client()}
+	s := &summary{
+		Findings: 1,
+		Namespaces: []namespaceSummary{{
+			Namespace: "payments",
+			Findings:  []finding{{Host: "pay.example.com", Kind: "Ingress", Object: "web", Severity: "ERROR", Error: "connection refused"}},
+		}},
+	}
+	if err := n.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"*payments*", "pay.example.com (Ingress web): ERROR connection refused"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in message %q", want, text)
+		}
+	}
+}
+
+func TestAlertmanagerNotifier(t *testing.T) {
+	var alerts []alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("decoding alerts: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	results := []result{
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "soon.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "renewed.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 3, 0)}},
+	}
+	n := &alertmanagerNotifier{url: server.URL, resolveAfter: time.Hour, client: server.Client()}
+	notifyAll(context.Background(), []notifier{n}, newSummary(results, policy{days: 30}, now))
+
+	firing := map[string]string{}
+	resolved := map[string]int{}
+	for _, a := range alerts {
+		if a.EndsAt.After(now) {
+			firing[a.Labels["host"]] = a.Labels["severity"]
+			if a.Labels["kind"] != "Ingress" || a.Labels["object"] != "web" || a.Labels["namespace"] != "a" {
+				t.Errorf("unexpected labels %v", a.Labels)
+			}
+			if a.Annotations["days_remaining"] != "10" {
+				t.Errorf("unexpected annotations %v", a.Annotations)
+			}
+			continue
+		}
+		resolved[a.Labels["host"]]++
+	}
+	if len(firing) != 1 || firing["soon.example.com"] != "warning" {
+		t.Errorf("expected a warning for soon.example.com, got %v", firing)
+	}
+	if resolved["soon.example.com"] != 1 || resolved["renewed.example.com"] != 2 {
+		t.Errorf("unexpected resolved alerts %v", resolved)
+	}
+}
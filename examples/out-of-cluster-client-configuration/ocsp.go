@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	revocationGood    = "good"
+	revocationRevoked = "revoked"
+	revocationUnknown = "unknown"
+)
+
+// maxOCSPResponseSize bounds how much of an OCSP response is read.
+const maxOCSPResponseSize = 1 << 20
+
+// ocspClockSkew is how far the clocks of responders may be off when the
+// validity interval of their responses is checked.
+const ocspClockSkew = 5 * time.Minute
+
+// checkOCSP returns the revocation status of the leaf certificate of state.
+// A stapled response is always honored; the responder of the certificate is
+// only queried when d.ocsp is set. It returns nil when there is nothing to
+// report.
+func (d *dialer) checkOCSP(ctx context.Context, state tls.ConnectionState) *revocation {
+	leaf, issuer := leafAndIssuer(state)
+	if issuer == nil {
+		return nil
+	}
+	if len(state.OCSPResponse) > 0 {
+		return ocspRevocation(state.OCSPResponse, leaf, issuer, "stapled")
+	}
+	if !d.ocsp || len(leaf.OCSPServer) == 0 {
+		return nil
+	}
+
+	responder := leaf.OCSPServer[0]
+	raw, err := d.queryOCSP(ctx, responder, leaf, issuer)
+	if err != nil {
+		return &revocation{Status: revocationUnknown, Source: responder, Error: err.Error()}
+	}
+	return ocspRevocation(raw, leaf, issuer, responder)
+}
+
+// leafAndIssuer returns the leaf certificate of state and the certificate
+// that issued it, preferring the verified chain over what the peer sent.
+func leafAndIssuer(state tls.ConnectionState) (leaf, issuer *x509.Certificate) {
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+	if len(chain) < 2 {
+		return chain[0], nil
+	}
+	return chain[0], chain[1]
+}
+
+func (d *dialer) queryOCSP(ctx context.Context, responder string, leaf, issuer *x509.Certificate) ([]byte, error) {
+	body, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, responder, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned %s", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+}
+
+// ocspRevocation parses the OCSP response raw about leaf. A response that is
+// not valid yet, or no longer, says nothing about leaf.
+func ocspRevocation(raw []byte, leaf, issuer *x509.Certificate, source string) *revocation {
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return &revocation{Status: revocationUnknown, Source: source, Error: err.Error()}
+	}
+	now := time.Now()
+	if resp.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return &revocation{Status: revocationUnknown, Source: source, Error: "OCSP response is not valid before " + resp.ThisUpdate.UTC().Format(time.RFC3339)}
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Add(ocspClockSkew).Before(now) {
+		return &revocation{Status: revocationUnknown, Source: source, Error: "OCSP response expired at " + resp.NextUpdate.UTC().Format(time.RFC3339)}
+	}
+	r := &revocation{Source: source}
+	switch resp.Status {
+	case ocsp.Good:
+		r.Status = revocationGood
+	case ocsp.Revoked:
+		r.Status = revocationRevoked
+		r.RevokedAt = &resp.RevokedAt
+	default:
+		r.Status = revocationUnknown
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCertificate returns a certificate for cn signed by parent, or a
+// self-signed one when parent is nil.
+func newTestCertificate(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{cn},
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
+
+func TestCheckOCSP(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, "example.com", false, ca, caKey)
+
+	status := ocsp.Good
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			t.Errorf("parsing OCSP request: %v", err)
+			return
+		}
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, caKey)
+		if err != nil {
+			t.Errorf("creating OCSP response: %v", err)
+			return
+		}
+		w.Write(resp)
+	}))
+	defer responder.Close()
+	leaf.OCSPServer = []string{responder.URL}
+
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca}}
+	d := &dialer{ocsp: true, client: responder.Client()}
+
+	if r := d.checkOCSP(context.Background(), state); r == nil || r.Status != revocationGood || r.Source != responder.URL {
+		t.Errorf("expected good from %s, got %+v", responder.URL, r)
+	}
+	status = ocsp.Revoked
+	if r := d.checkOCSP(context.Background(), state); r == nil || r.Status != revocationRevoked || r.RevokedAt == nil {
+		t.Errorf("expected revoked, got %+v", r)
+	}
+
+	d.ocsp = false
+	if r := d.checkOCSP(context.Background(), state); r != nil {
+		t.Errorf("expected no OCSP query, got %+v", r)
+	}
+
+	// A stapled response is honored without querying the responder.
+	stapled, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.OCSPResponse = stapled
+	responder.Close()
+	if r := d.checkOCSP(context.Background(), state); r == nil || r.Status != revocationRevoked || r.Source != "stapled" {
+		t.Errorf("expected stapled revoked, got %+v", r)
+	}
+}
+
+func TestOCSPRevocationValidity(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, "example.com", false, ca, caKey)
+
+	now := time.Now()
+	for _, test := range []struct {
+		name                   string
+		thisUpdate, nextUpdate time.Time
+		expected               string
+	}{
+		{"current", now.Add(-time.Hour), now.Add(time.Hour), revocationGood},
+		{"without next update", now.Add(-time.Hour), time.Time{}, revocationGood},
+		{"within the clock skew", now.Add(time.Minute), now.Add(-time.Minute), revocationGood},
+		{"expired", now.Add(-2 * time.Hour), now.Add(-time.Hour), revocationUnknown},
+		{"from the future", now.Add(time.Hour), now.Add(2 * time.Hour), revocationUnknown},
+	} {
+		raw, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   test.thisUpdate,
+			NextUpdate:   test.nextUpdate,
+		}, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := ocspRevocation(raw, leaf, ca, "stapled")
+		if r.Status != test.expected {
+			t.Errorf("%s: expected %s, got %+v", test.name, test.expected, r)
+		}
+		if test.expected == revocationUnknown && r.Error == "" {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
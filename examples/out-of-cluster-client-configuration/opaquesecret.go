@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+)
+
+// secretKind is the kind of the targets of Opaque Secrets.
+const secretKind = "Secret"
+
+// pemCertificateHeader starts every PEM encoded certificate.
+var pemCertificateHeader = []byte("-----BEGIN CERTIFICATE-----")
+
+// opaqueSecretSource returns a source checking the PEM certificates stored
+// in the Opaque Secrets served by informer, outside of the kubernetes.io/tls
+// Secrets referenced by other objects.
+func opaqueSecretSource(informer coreinformers.SecretInformer) *source {
+	return &source{
+		kind:     secretKind,
+		informer: informer.Informer(),
+		targets: func(obj interface{}) []target {
+			return opaqueSecretTargets(obj.(*v1.Secret))
+		},
+		changed: func(old, new interface{}) bool {
+			oldSecret := old.(*v1.Secret)
+			newSecret := new.(*v1.Secret)
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data) ||
+				checkerAnnotationsChanged(oldSecret.Annotations, newSecret.Annotations)
+		},
+	}
+}
+
+// opaqueSecretTargets returns a target for every key of secret holding PEM
+// certificates, named after the key. Like CA bundles, the certificates of a
+// key are never dialed and are reported by the one expiring first. A Secret
+// with the ignore annotation has none.
+func opaqueSecretTargets(secret *v1.Secret) []target {
+	if secret.Type != v1.SecretTypeOpaque || ignored(secret.Namespace, secret.Name, secret.Annotations) {
+		return nil
+	}
+	var keys []string
+	for key, data := range secret.Data {
+		if bytes.Contains(data, pemCertificateHeader) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var targets []target
+	for _, key := range keys {
+		targets = append(targets, target{
+			namespace:      secret.Namespace,
+			kind:           secretKind,
+			object:         secret.Name,
+			host:           key,
+			certificatePEM: secret.Data[key],
+			caBundle:       true,
+			ref:            objectReference("v1", secretKind, secret),
+			warnBefore:     annotatedWarnBefore(secret.Namespace, secret.Name, secret.Annotations),
+		})
+	}
+	return targets
+}
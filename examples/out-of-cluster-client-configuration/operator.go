@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// certExpiryHealthy is the type of the condition -operator maintains on
+// ingresses.
+const certExpiryHealthy = "CertExpiryHealthy"
+
+// requeueSlack is how long after a certificate enters the warning window or
+// expires its object is checked again, so that the check sees the new
+// severity.
+const requeueSlack = time.Minute
+
+// ingressCondition is a condition of an ingress. Ingresses have no
+// conditions in their status, hence the annotation.
+type ingressCondition struct {
+	Type               string      `json:"type"`
+	Status             string      `json:"status"`
+	Reason             string      `json:"reason"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// condition returns the CertExpiryHealthy condition of status as JSON. The
+// time of the last transition is kept from the condition already on the
+// ingress if its status did not change.
+func (n *annotationNotifier) condition(ctx context.Context, status ingressStatus) (string, error) {
+	var previous *ingressCondition
+	annotations := make(chan map[string]string, 1)
+	err := callAPI(ctx, func() error {
+		ing, err := n.client.Ingresses(status.namespace).Get(status.name, metav1.GetOptions{})
+		if err == nil {
+			annotations <- ing.Annotations
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if value, ok := (<-annotations)[conditionAnnotation]; ok {
+		previous = &ingressCondition{}
+		if err := json.Unmarshal([]byte(value), previous); err != nil {
+			previous = nil
+		}
+	}
+	b, err := json.Marshal(newIngressCondition(status, previous))
+	return string(b), err
+}
+
+// newIngressCondition returns the CertExpiryHealthy condition of status: True
+// once every host is fine again, for example after the certificate was
+// renewed, False with the reason of the worst host otherwise.
+func newIngressCondition(status ingressStatus, previous *ingressCondition) ingressCondition {
+	c := ingressCondition{
+		Type:               certExpiryHealthy,
+		Status:             string(metav1.ConditionTrue),
+		Reason:             "CertificateValid",
+		LastTransitionTime: metav1.NewTime(status.checked),
+	}
+	if status.severity != severityOK {
+		c.Status = string(metav1.ConditionFalse)
+		c.Reason = eventReasons[status.severity.String()]
+	}
+	switch {
+	case status.expiresAt.IsZero():
+		c.Message = "no certificate could be checked"
+	case status.severity == severityExpired:
+		c.Message = fmt.Sprintf("certificate expired %s", status.expiresAt.Format("2006-01-02"))
+	default:
+		c.Message = fmt.Sprintf("certificate expires %s", status.expiresAt.Format("2006-01-02"))
+	}
+	if previous != nil && previous.Status == c.Status {
+		c.LastTransitionTime = previous.LastTransitionTime
+	}
+	return c
+}
+
+// nextCheck returns how long until the severity of one of results changes
+// because of time alone: until a certificate enters the warning window of its
+// host, or expires. It returns false if none of them will.
+func nextCheck(results []result, p policy, now time.Time) (time.Duration, bool) {
+	var next time.Time
+	for _, r := range results {
+		c := r.certificate
+		if c == nil {
+			continue
+		}
+		at := p.warnsAt(r.target, c)
+		if !now.Before(at) {
+			at = c.NotAfter
+		}
+		if now.Before(at) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return 0, false
+	}
+	return next.Sub(now) + requeueSlack, true
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnnotationNotifierConditions(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&v1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}})
+	p := policy{days: 30}
+	n := &annotationNotifier{client: clientset.ExtensionsV1beta1(), policy: p, conditions: true}
+	ref := &v1.ObjectReference{Kind: ingressKind, Namespace: "shop", Name: "web"}
+	scan := func(now time.Time, notAfter time.Time) ingressCondition {
+		t.Helper()
+		results := []result{{target: target{host: "shop.example.com", ref: ref}, certificate: &certificate{NotAfter: notAfter}}}
+		if err := n.notify(context.Background(), newSummary(results, p, now)); err != nil {
+			t.Fatal(err)
+		}
+		ing, err := clientset.ExtensionsV1beta1().Ingresses("shop").Get("web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c ingressCondition
+		if err := json.Unmarshal([]byte(ing.Annotations[conditionAnnotation]), &c); err != nil {
+			t.Fatalf("invalid condition %q: %v", ing.Annotations[conditionAnnotation], err)
+		}
+		return c
+	}
+
+	day := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	notAfter := day.AddDate(0, 0, 10)
+	c := scan(day, notAfter)
+	if c.Type != certExpiryHealthy || c.Status != "False" || c.Reason != "CertificateExpiring" || !c.LastTransitionTime.Time.Equal(day) {
+		t.Errorf("expected an expiring condition since %s, got %+v", day, c)
+	}
+	// Still expiring the next day, the transition time is kept.
+	if c = scan(day.AddDate(0, 0, 1), notAfter); c.Status != "False" || !c.LastTransitionTime.Time.Equal(day) {
+		t.Errorf("expected the transition time to be kept, got %+v", c)
+	}
+	// Renewed.
+	renewed := day.AddDate(0, 0, 2)
+	if c = scan(renewed, renewed.AddDate(0, 3, 0)); c.Status != "True" || c.Reason != "CertificateValid" || !c.LastTransitionTime.Time.Equal(renewed) {
+		t.Errorf("expected a healthy condition since %s, got %+v", renewed, c)
+	}
+}
+
+func TestNextCheck(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	p := policy{days: 30}
+	tests := []struct {
+		name     string
+		notAfter []time.Time
+		want     time.Duration
+		ok       bool
+	}{
+		{name: "none"},
+		{name: "enters the warning window", notAfter: []time.Time{now.AddDate(0, 0, 40), now.AddDate(1, 0, 0)}, want: 10*24*time.Hour + requeueSlack, ok: true},
+		{name: "expires", notAfter: []time.Time{now.AddDate(0, 0, 3)}, want: 3*24*time.Hour + requeueSlack, ok: true},
+		{name: "expired", notAfter: []time.Time{now.AddDate(0, 0, -1)}},
+	}
+	for _, test := range tests {
+		results := []result{{err: errors.New("connection refused")}}
+		for _, notAfter := range test.notAfter {
+			results = append(results, result{certificate: &certificate{NotAfter: notAfter}})
+		}
+		got, ok := nextCheck(results, p, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: expected %v, %v, got %v, %v", test.name, test.want, test.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// policyFile is the YAML file read by -policy-file, so that the policy can
+// be tuned without recompiling:
+//
+//	days: 30
+//	minRSABits: 3072
+//	sunsetSignatureAlgorithms:
+//	  SHA256-RSA: "2030-01-01"
+//	  SHA1-RSA: null
+//	rules:
+//	- grade: C
+//	  when:
+//	    tlsVersionBelow: TLS 1.2
+//
+// Fields mirror the flags of the same name, which take precedence when set.
+// Signature algorithms are named as Go names them; a null date removes an
+// algorithm from the built-in table.
+type policyFile struct {
+	Years                     *int               `json:"years"`
+	Months                    *int               `json:"months"`
+	Days                      *int               `json:"days"`
+	MinRSABits                *int               `json:"minRSABits"`
+	MinECBits                 *int               `json:"minECBits"`
+	SunsetSignatureAlgorithms map[string]*string `json:"sunsetSignatureAlgorithms"`
+	// Rules grade hosts, see gradingRule.
+	Rules []gradingRule `json:"rules"`
+}
+
+func loadPolicyFile(file string) (*policyFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f := &policyFile{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", file, err)
+	}
+	return f, nil
+}
+
+// apply sets the fields of p whose flag was not in set and its grading
+// rules, and updates sunsetSignatureAlgorithms.
+func (f *policyFile) apply(p *policy, set map[string]bool) error {
+	for flag, field := range map[string]struct {
+		value *int
+		to    *int
+	}{
+		"years":        {f.Years, &p.years},
+		"months":       {f.Months, &p.months},
+		"days":         {f.Days, &p.days},
+		"min-rsa-bits": {f.MinRSABits, &p.minRSABits},
+		"min-ec-bits":  {f.MinECBits, &p.minECBits},
+	} {
+		if field.value != nil && !set[flag] {
+			*field.to = *field.value
+		}
+	}
+
+	algorithms := signatureAlgorithmsByName()
+	for name, date := range f.SunsetSignatureAlgorithms {
+		alg, ok := algorithms[name]
+		if !ok {
+			return fmt.Errorf("unknown signature algorithm %q", name)
+		}
+		if date == nil {
+			delete(sunsetSignatureAlgorithms, alg)
+			continue
+		}
+		t, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			return fmt.Errorf("invalid sunset date of %s: %v", name, err)
+		}
+		sunsetSignatureAlgorithms[alg] = sunsetSignatureAlgorithm{name: name, date: t}
+	}
+
+	for i := range f.Rules {
+		if err := f.Rules[i].compile(); err != nil {
+			return err
+		}
+	}
+	p.rules = f.Rules
+	return nil
+}
+
+// signatureAlgorithmsByName maps the names of the signature algorithms
+// known to crypto/x509 to them.
+func signatureAlgorithmsByName() map[string]x509.SignatureAlgorithm {
+	algorithms := map[string]x509.SignatureAlgorithm{}
+	for alg := x509.MD2WithRSA; alg <= x509.PureEd25519; alg++ {
+		algorithms[alg.String()] = alg
+	}
+	return algorithms
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyFile(t *testing.T) {
+	saved := map[x509.SignatureAlgorithm]sunsetSignatureAlgorithm{}
+	for alg, sunset := range sunsetSignatureAlgorithms {
+		saved[alg] = sunset
+	}
+	defer func() { sunsetSignatureAlgorithms = saved }()
+
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "policy.yaml")
+	if err := ioutil.WriteFile(file, []byte(`
+days: 14
+months: 1
+minRSABits: 3072
+sunsetSignatureAlgorithms:
+  SHA256-RSA: "2030-01-01"
+  SHA1-RSA: null
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := loadPolicyFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := policy{days: 7, minRSABits: defaultMinRSABits, minECBits: defaultMinECBits}
+	// -days was set on the command line.
+	if err := f.apply(&p, map[string]bool{"days": true}); err != nil {
+		t.Fatal(err)
+	}
+	want := policy{months: 1, days: 7, minRSABits: 3072, minECBits: defaultMinECBits}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+	if sunset, ok := sunsetSignatureAlgorithms[x509.SHA256WithRSA]; !ok || !sunset.date.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected SHA256-RSA to be sunset in 2030, got %+v", sunset)
+	}
+	if _, ok := sunsetSignatureAlgorithms[x509.SHA1WithRSA]; ok {
+		t.Errorf("expected SHA1-RSA to be removed")
+	}
+	if _, ok := sunsetSignatureAlgorithms[x509.MD5WithRSA]; !ok {
+		t.Errorf("expected MD5-RSA to be kept")
+	}
+
+	for _, invalid := range []string{
+		"dayz: 14\n",
+		"sunsetSignatureAlgorithms:\n  SHA3-RSA: \"2030-01-01\"\n",
+		"sunsetSignatureAlgorithms:\n  SHA256-RSA: soon\n",
+		"rules:\n- grade: C\n",
+		"rules:\n- when:\n    chainIssues: true\n",
+		"rules:\n- grade: C\n  when:\n    tlsVersionBelow: SSL 3.0\n",
+	} {
+		if err := ioutil.WriteFile(file, []byte(invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := loadPolicyFile(file)
+		if err == nil {
+			err = f.apply(&policy{}, nil)
+		}
+		if err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// profiles writes the CPU and heap profiles of -cpuprofile and -memprofile
+// once the run ends, however it ends.
+type profiles struct {
+	cpu     *os.File
+	memFile string
+	once    sync.Once
+}
+
+// startProfiles starts profiling the CPU into cpuFile, if set, and returns
+// the profiles whose stop writes them.
+func startProfiles(cpuFile, memFile string) (*profiles, error) {
+	p := &profiles{memFile: memFile}
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := rpprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		p.cpu = f
+	}
+	return p, nil
+}
+
+// stop stops profiling the CPU and writes the heap profile. Only the first
+// call does anything, so that it can both be deferred and run on exit.
+func (p *profiles) stop() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		if p.cpu != nil {
+			rpprof.StopCPUProfile()
+			if err := p.cpu.Close(); err != nil {
+				klog.ErrorS(err, "Writing the CPU profile", "file", p.cpu.Name())
+			}
+		}
+		if p.memFile != "" {
+			if err := writeHeapProfile(p.memFile); err != nil {
+				klog.ErrorS(err, "Writing the heap profile", "file", p.memFile)
+			}
+		}
+	})
+}
+
+// writeHeapProfile writes the profile of the memory in use to file.
+func writeHeapProfile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	// The profile is as of the last garbage collection.
+	runtime.GC()
+	if err := rpprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// handlePprof serves the net/http/pprof endpoints under /debug/pprof/ on mux.
+func handlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
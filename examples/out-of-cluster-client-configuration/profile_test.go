@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cpuFile, memFile := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+
+	p, err := startProfiles(cpuFile, memFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.stop()
+	// Stopping again, as on exit after the deferred stop, does nothing.
+	p.stop()
+	for _, file := range []string{cpuFile, memFile} {
+		if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+			t.Errorf("expected a profile in %s, got %v", file, err)
+		}
+	}
+
+	var nilProfiles *profiles
+	nilProfiles.stop()
+	if _, err := startProfiles(filepath.Join(dir, "missing", "cpu.pprof"), ""); err == nil {
+		t.Error("expected an error for a CPU profile that cannot be created")
+	}
+}
+
+func TestHandlePprof(t *testing.T) {
+	mux := http.NewServeMux()
+	handlePprof(mux)
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, w.Code)
+		}
+	}
+}
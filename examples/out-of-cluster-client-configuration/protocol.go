@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// tlsVersionNames are the names of the TLS versions a handshake may
+// negotiate.
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// offeredCipherSuites are the TLS 1.0-1.2 cipher suites offered to hosts.
+// The weak ones are offered too, to find out whether a host still prefers
+// them; TLS 1.3 suites are not configurable.
+var offeredCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+	tls.TLS_RSA_WITH_RC4_128_SHA,
+}
+
+// cipherSuiteNames are the names of the cipher suites a handshake may
+// negotiate.
+var cipherSuiteNames = map[uint16]string{
+	tls.TLS_AES_128_GCM_SHA256:                  "TLS_AES_128_GCM_SHA256",
+	tls.TLS_AES_256_GCM_SHA384:                  "TLS_AES_256_GCM_SHA384",
+	tls.TLS_CHACHA20_POLY1305_SHA256:            "TLS_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256:         "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA:     "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA:           "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA:          "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	tls.TLS_RSA_WITH_RC4_128_SHA:                "TLS_RSA_WITH_RC4_128_SHA",
+}
+
+// weakCipherSuites are the cipher suites crypto/tls considers insecure: RC4
+// and 3DES, CBC with SHA-256 (Lucky13), and RSA key exchange (no forward
+// secrecy).
+var weakCipherSuites = map[uint16]bool{
+	tls.TLS_RSA_WITH_RC4_128_SHA:                true,
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA:           true,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            true,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            true,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256:         true,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         true,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         true,
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        true,
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA:          true,
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA:     true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: true,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256:   true,
+}
+
+// protocol is the TLS version, cipher suite and application protocol a host
+// negotiated.
+type protocol struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipherSuite"`
+	// ALPN is the application protocol negotiated with ALPN, if any was
+	// offered and the host picked one.
+	ALPN string `json:"alpn,omitempty"`
+	// Weak is why the version or the cipher suite is weak, if it is.
+	Weak string `json:"weak,omitempty"`
+}
+
+func newProtocol(state tls.ConnectionState) *protocol {
+	p := &protocol{
+		Version:     tlsVersionNames[state.Version],
+		CipherSuite: cipherSuiteNames[state.CipherSuite],
+		ALPN:        state.NegotiatedProtocol,
+	}
+	if p.Version == "" {
+		p.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	if p.CipherSuite == "" {
+		p.CipherSuite = fmt.Sprintf("0x%04x", state.CipherSuite)
+	}
+	switch {
+	case state.Version < tls.VersionTLS12:
+		p.Weak = p.Version + " is deprecated"
+	case weakCipherSuites[state.CipherSuite]:
+		p.Weak = p.CipherSuite + " is insecure"
+	}
+	return p
+}
+
+// parseALPN parses a comma separated list of ALPN protocols, e.g. h2,http/1.1.
+// grpc stands for h2, the protocol gRPC is served over.
+func parseALPN(s string) ([]string, error) {
+	var protos []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "grpc" {
+			p = "h2"
+		}
+		if p == "" || len(p) > 255 {
+			return nil, fmt.Errorf("invalid ALPN protocol %q", p)
+		}
+		protos = append(protos, p)
+	}
+	return protos, nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFunc returns the proxy to dial addr through, nil to dial it directly.
+type proxyFunc func(addr string) (*url.URL, error)
+
+// newProxyFunc returns the proxyFunc of the environment: $HTTPS_PROXY
+// unless the address matches $NO_PROXY. A non-empty proxyURL replaces
+// $HTTPS_PROXY.
+func newProxyFunc(proxyURL string) (proxyFunc, error) {
+	config := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid proxy %q: the scheme must be http, https or socks5", proxyURL)
+		}
+		config.HTTPSProxy = proxyURL
+	}
+	if config.HTTPSProxy == "" {
+		return nil, nil
+	}
+	f := config.ProxyFunc()
+	return func(addr string) (*url.URL, error) {
+		return f(&url.URL{Scheme: "https", Host: addr})
+	}, nil
+}
+
+// contextDialer is implemented by the SOCKS5 dialer of golang.org/x/net/proxy.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// dialThrough connects to addr through the proxy at u: a tunnel opened with
+// CONNECT through HTTP(S) proxies, or a SOCKS5 connection. Either way the
+// handshake that follows is with addr itself, so the certificate inspected
+// is the one of the origin.
+func dialThrough(ctx context.Context, u *url.URL, addr string) (net.Conn, error) {
+	if u.Scheme == "socks5" {
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, nil)
+		if err != nil {
+			return nil, err
+		}
+		return d.(contextDialer).DialContext(ctx, "tcp", addr)
+	}
+
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+	var netDialer net.Dialer
+	conn, err := netDialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s: %v", u.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", u.Host, err)
+	}
+	// The origin does not send anything before the client hello, so
+	// nothing past the response is buffered.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", u.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", u.Host, addr, resp.Status)
+	}
+	return conn, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectProxy is an HTTP proxy tunneling CONNECT requests, recording the
+// addresses it connected to.
+func connectProxy(t *testing.T, connected chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+			http.Error(w, "who are you", http.StatusProxyAuthRequired)
+			return
+		}
+		origin, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer origin.Close()
+		connected <- r.Host
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(origin, conn)
+			origin.Close()
+		}()
+		io.Copy(conn, origin)
+	}))
+}
+
+func TestDialerProxy(t *testing.T) {
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer origin.Close()
+	connected := make(chan string, 1)
+	p := connectProxy(t, connected)
+	defer p.Close()
+	proxyURL, err := url.Parse(p.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := strings.TrimPrefix(origin.URL, "https://")
+
+	proxyURL.User = url.UserPassword("user", "secret")
+	d := &dialer{timeout: 5 * time.Second, insecureSkipVerify: true, proxy: func(string) (*url.URL, error) { return proxyURL, nil }}
+	c, err := d.checkHost(context.Background(), "127.0.0.1", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Fingerprint != fingerprint(origin.Certificate()) {
+		t.Errorf("expected the certificate of the origin, got %+v", c)
+	}
+	if got := <-connected; got != addr {
+		t.Errorf("expected the proxy to connect to %s, got %s", addr, got)
+	}
+
+	proxyURL.User = nil
+	if _, err := d.checkHost(context.Background(), "127.0.0.1", addr); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("expected the proxy to refuse, got %v", err)
+	}
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		if value, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, value)
+		} else {
+			defer os.Unsetenv(env)
+		}
+		os.Unsetenv(env)
+	}
+
+	f, err := newProxyFunc("")
+	if err != nil || f != nil {
+		t.Fatalf("expected no proxy without $HTTPS_PROXY, got %v", err)
+	}
+
+	os.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	os.Setenv("NO_PROXY", ".internal")
+	for _, test := range []struct {
+		flag, addr, want string
+	}{
+		{"", "shop.example.com:443", "http://env-proxy:3128"},
+		{"", "api.internal:443", ""},
+		{"socks5://socks:1080", "shop.example.com:443", "socks5://socks:1080"},
+		{"socks5://socks:1080", "api.internal:443", ""},
+	} {
+		f, err := newProxyFunc(test.flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := f(test.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != test.want {
+			t.Errorf("-proxy=%q %s: expected %q, got %q", test.flag, test.addr, test.want, got)
+		}
+	}
+
+	if _, err := newProxyFunc("ftp://proxy"); err == nil {
+		t.Error("expected an error for an ftp proxy")
+	}
+}
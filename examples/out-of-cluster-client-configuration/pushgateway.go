@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// pushgatewayNotifier pushes the metrics of every scan to a Prometheus
+// Pushgateway, for one-shot runs that are not around to be scraped. The
+// metrics replace the ones of the previous run in the group of job and
+// instance.
+type pushgatewayNotifier struct {
+	url      string
+	job      string
+	instance string
+	policy   policy
+	// deleteAfter, if set, is how long other groups of job are kept without
+	// being pushed to before they are deleted, e.g. the groups of instances
+	// that were renamed.
+	deleteAfter time.Duration
+	client      *http.Client
+}
+
+// pushgatewayGroup is a group of metrics as listed by GET /api/v1/metrics.
+// Only the time of its last push is of interest.
+type pushgatewayGroup struct {
+	Labels   map[string]string `json:"labels"`
+	PushTime struct {
+		Metrics []struct {
+			Value string `json:"value"`
+		} `json:"metrics"`
+	} `json:"push_time_seconds"`
+}
+
+func (n *pushgatewayNotifier) resolvesFindings() {}
+
+func (n *pushgatewayNotifier) notify(ctx context.Context, s *summary) error {
+	// An interrupted scan replaces the metrics it has with POST, keeping
+	// the time of the last successful one pushed before.
+	method, lastSuccess := http.MethodPut, s.Time
+	if s.Interrupted {
+		method, lastSuccess = http.MethodPost, time.Time{}
+	}
+	var body bytes.Buffer
+	if err := writeMetrics(&body, scanMetrics(s.results, n.policy, s.Time, s.duration, lastSuccess)); err != nil {
+		return err
+	}
+	if err := n.do(ctx, method, n.groupURL(n.labels()), metricsContentType, body.Bytes()); err != nil {
+		return err
+	}
+	if n.deleteAfter > 0 {
+		return n.deleteStale(ctx, s.Time)
+	}
+	return nil
+}
+
+// labels returns the grouping labels of the metrics pushed by n.
+func (n *pushgatewayNotifier) labels() map[string]string {
+	labels := map[string]string{"job": n.job}
+	if n.instance != "" {
+		labels["instance"] = n.instance
+	}
+	return labels
+}
+
+// deleteStale deletes the other groups of the job of n that were last pushed
+// to more than deleteAfter before now.
+func (n *pushgatewayNotifier) deleteStale(ctx context.Context, now time.Time) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(n.url, "/")+"/api/v1/metrics", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", req.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	var groups struct {
+		Data []pushgatewayGroup `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return fmt.Errorf("decoding the groups of %s: %v", n.url, err)
+	}
+
+	own := n.labels()
+	for _, g := range groups.Data {
+		if g.Labels["job"] != n.job || g.Labels["instance"] == own["instance"] || len(g.PushTime.Metrics) == 0 {
+			continue
+		}
+		pushed, err := strconv.ParseFloat(g.PushTime.Metrics[0].Value, 64)
+		if err != nil || now.Sub(time.Unix(int64(pushed), 0)) < n.deleteAfter {
+			continue
+		}
+		if err := n.do(ctx, http.MethodDelete, n.groupURL(g.Labels), "", nil); err != nil {
+			return err
+		}
+		klog.V(2).InfoS("Deleted stale Pushgateway group", "labels", g.Labels)
+	}
+	return nil
+}
+
+// groupURL returns the URL of the group with labels, which must include job.
+// Values that cannot be part of a path are base64 encoded.
+func (n *pushgatewayNotifier) groupURL(labels map[string]string) string {
+	u := strings.TrimSuffix(n.url, "/") + "/metrics" + groupingPath("job", labels["job"])
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "job" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		u += groupingPath(name, labels[name])
+	}
+	return u
+}
+
+// groupingPath returns the part of the path of a group for the label name
+// with value.
+func groupingPath(name, value string) string {
+	switch {
+	case value == "":
+		// An empty value is encoded as a single padding character.
+		return "/" + name + "@base64/="
+	case strings.Contains(value, "/"):
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + url.PathEscape(value)
+}
+
+// do sends body to u with method and fails on any non-2xx response.
+func (n *pushgatewayNotifier) do(ctx context.Context, method, u, contentType string, body []byte) error {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushgatewayNotifier(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	var method, pushed string
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet && r.Method != http.MethodDelete && r.URL.Path == "/metrics/job/cert-check/instance/nightly":
+			method = r.Method
+			b, _ := ioutil.ReadAll(r.Body)
+			pushed = string(b)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/metrics":
+			fmt.Fprintf(w, `{"status":"success","data":[
+				{"labels":{"job":"cert-check","instance":"nightly"},"push_time_seconds":{"metrics":[{"value":"%d"}]}},
+				{"labels":{"job":"cert-check","instance":"renamed"},"push_time_seconds":{"metrics":[{"value":"%d"}]}},
+				{"labels":{"job":"cert-check","instance":"other/cluster"},"push_time_seconds":{"metrics":[{"value":"%d"}]}},
+				{"labels":{"job":"other","instance":"old"},"push_time_seconds":{"metrics":[{"value":"%d"}]}}
+			]}`, now.Unix(), now.AddDate(0, 0, -3).Unix(), now.Add(-time.Hour).Unix(), now.AddDate(0, 0, -3).Unix())
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	results := []result{
+		{target: target{namespace: "a", kind: "Ingress", object: "web", host: "soon.example.com"}, certificate: &certificate{NotAfter: now.AddDate(0, 0, 10)}},
+		{target: target{namespace: "b", kind: "Ingress", object: "web", host: "b.example.com"}, err: errors.New("connection refused")},
+	}
+	s := newSummary(results, policy{days: 30}, now)
+	s.duration = 1500 * time.Millisecond
+	n := &pushgatewayNotifier{url: server.URL, job: "cert-check", instance: "nightly", policy: policy{days: 30}, deleteAfter: 25 * time.Hour, client: server.Client()}
+	if err := n.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"# TYPE cert_check_certificate_expiry_timestamp_seconds gauge\n",
+		fmt.Sprintf(`cert_check_certificate_expiry_timestamp_seconds{host="soon.example.com",kind="Ingress",namespace="a",object="web"} %d`, now.AddDate(0, 0, 10).Unix()),
+		"cert_check_hosts 2\n",
+		"cert_check_hosts_failing 2\n",
+		"cert_check_errors 1\n",
+		fmt.Sprintf("cert_check_soonest_expiry_timestamp_seconds %d\n", now.AddDate(0, 0, 10).Unix()),
+		"cert_check_scan_duration_seconds 1.5\n",
+		fmt.Sprintf("cert_check_last_success_timestamp_seconds %d\n", now.Unix()),
+	} {
+		if !strings.Contains(pushed, want) {
+			t.Errorf("expected %q in pushed metrics:\n%s", want, pushed)
+		}
+	}
+	if method != http.MethodPut {
+		t.Errorf("expected the metrics to be pushed with PUT, got %s", method)
+	}
+	if len(deleted) != 1 || deleted[0] != "/metrics/job/cert-check/instance/renamed" {
+		t.Errorf("expected only the renamed group to be deleted, got %v", deleted)
+	}
+
+	// An interrupted scan keeps the last success pushed before.
+	s.Interrupted = true
+	n.deleteAfter = 0
+	if err := n.notify(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || strings.Contains(pushed, "cert_check_last_success_timestamp_seconds") {
+		t.Errorf("expected the metrics but the last success to be pushed with POST, got %s:\n%s", method, pushed)
+	}
+}
+
+func TestGroupingPath(t *testing.T) {
+	for _, tc := range []struct {
+		name, value, want string
+	}{
+		{"instance", "nightly", "/instance/nightly"},
+		{"instance", "", "/instance@base64/="},
+		{"instance", "a/b", "/instance@base64/YS9i"},
+	} {
+		if got := groupingPath(tc.name, tc.value); got != tc.want {
+			t.Errorf("groupingPath(%q, %q) = %q, expected %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+}
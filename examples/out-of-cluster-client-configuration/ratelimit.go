@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+// dialLimiter spaces out dials, so that large scans do not trip the rate
+// limits of the load balancers and WAFs in front of the hosts: the dials of
+// every address are limited to a rate, and the dials of every host may be at
+// least an interval apart.
+type dialLimiter struct {
+	// perAddress is the rate at which every address may be dialed, with
+	// bursts of as many dials, unlimited when 0.
+	perAddress float64
+	// hostInterval is how long to wait between two dials of the same host,
+	// 0 does not wait.
+	hostInterval time.Duration
+
+	mu        sync.Mutex
+	addresses map[string]*rate.Limiter
+	hosts     map[string]*rate.Limiter
+}
+
+// newDialLimiter returns a limiter for -max-dials-per-second and
+// -per-host-interval, or nil if neither limits anything.
+func newDialLimiter(perAddress float64, hostInterval time.Duration) *dialLimiter {
+	if perAddress <= 0 && hostInterval <= 0 {
+		return nil
+	}
+	return &dialLimiter{
+		perAddress:   perAddress,
+		hostInterval: hostInterval,
+		addresses:    map[string]*rate.Limiter{},
+		hosts:        map[string]*rate.Limiter{},
+	}
+}
+
+// wait blocks until host may be dialed at the addresses ips returns, or ctx
+// is done. ips is only called when the dials of addresses are limited; hosts
+// that do not resolve, or are dialed through a proxy, have none to wait for.
+func (l *dialLimiter) wait(ctx context.Context, host string, ips func() []string) error {
+	if l == nil {
+		return nil
+	}
+	if l.hostInterval > 0 {
+		if err := l.limiter(l.hosts, host, rate.Every(l.hostInterval), 1).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.perAddress <= 0 {
+		return nil
+	}
+	burst := int(math.Ceil(l.perAddress))
+	for _, ip := range ips() {
+		start := time.Now()
+		if err := l.limiter(l.addresses, ip, rate.Limit(l.perAddress), burst).Wait(ctx); err != nil {
+			return err
+		}
+		if waited := time.Since(start); waited > time.Millisecond {
+			klog.V(3).InfoS("Waited to dial address", "host", host, "address", ip, "duration", waited)
+		}
+	}
+	return nil
+}
+
+// limiter returns the limiter of key in limiters, adding one with limit and
+// burst if there is none.
+func (l *dialLimiter) limiter(limiters map[string]*rate.Limiter, key string, limit rate.Limit, burst int) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim, ok := limiters[key]
+	if !ok {
+		lim = rate.NewLimiter(limit, burst)
+		limiters[key] = lim
+	}
+	return lim
+}
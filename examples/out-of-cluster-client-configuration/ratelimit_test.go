@@ -0,0 +1,64 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDialLimiter(t *testing.T) {
+	if l := newDialLimiter(0, 0); l != nil {
+		t.Errorf("expected no limiter without limits, got %+v", l)
+	}
+	var nilLimiter *dialLimiter
+	if err := nilLimiter.wait(context.Background(), "shop.example.com", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Every host resolves to the same load balancer.
+	l := newDialLimiter(20, 0)
+	loadBalancer := func() []string { return []string{"10.0.0.1"} }
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		if err := l.wait(context.Background(), "shop.example.com", loadBalancer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 20 dials are allowed at once, the next 10 take half a second.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected the dials of the address to be limited, took %v", elapsed)
+	}
+	start = time.Now()
+	if err := l.wait(context.Background(), "proxied.example.com", func() []string { return nil }); err != nil || time.Since(start) > 10*time.Millisecond {
+		t.Errorf("expected hosts dialed through a proxy not to wait, got %v after %v", err, time.Since(start))
+	}
+
+	l = newDialLimiter(0, time.Hour)
+	if err := l.wait(context.Background(), "shop.example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.wait(context.Background(), "api.example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, "shop.example.com", nil); err == nil {
+		t.Error("expected the second dial of the host to wait for the interval")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// recheckSchedule is how often objects are checked again in watch mode,
+// depending on how soon the first of their certificates expires. It is set
+// with -recheck-schedule, e.g. 7d:1h,24h checks objects whose certificates
+// expire within 7 days every hour, and the others every day.
+type recheckSchedule struct {
+	// tiers are ordered by increasing within.
+	tiers []recheckTier
+	// otherwise is how often objects beyond the last tier are checked, never
+	// if 0.
+	otherwise time.Duration
+}
+
+// recheckTier checks objects whose first certificate expires within a
+// duration every interval.
+type recheckTier struct {
+	within time.Duration
+	every  time.Duration
+}
+
+// parseRecheckSchedule parses a -recheck-schedule: within:every tiers by
+// increasing within separated by commas, optionally followed by the interval
+// of the objects beyond the last tier.
+func parseRecheckSchedule(s string) (*recheckSchedule, error) {
+	schedule := &recheckSchedule{}
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		within, every := "", part
+		if j := strings.Index(part, ":"); j >= 0 {
+			within, every = part[:j], part[j+1:]
+		} else if i != len(parts)-1 {
+			return nil, fmt.Errorf("invalid -recheck-schedule %q: only the last interval may be given without a window", s)
+		}
+		interval, err := parseDuration(strings.TrimSpace(every))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid -recheck-schedule %q: invalid interval %q", s, every)
+		}
+		if within == "" {
+			schedule.otherwise = interval
+			continue
+		}
+		window, err := parseDuration(strings.TrimSpace(within))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid -recheck-schedule %q: invalid window %q", s, within)
+		}
+		if n := len(schedule.tiers); n > 0 && window <= schedule.tiers[n-1].within {
+			return nil, fmt.Errorf("invalid -recheck-schedule %q: windows must increase", s)
+		}
+		schedule.tiers = append(schedule.tiers, recheckTier{within: window, every: interval})
+	}
+	return schedule, nil
+}
+
+// after returns how long until the object whose hosts gave results is
+// checked again. Hosts that failed are checked as often as the ones whose
+// certificate expired, on the first tier. It returns false if the object is
+// not checked again on schedule.
+func (s *recheckSchedule) after(results []result, now time.Time) (time.Duration, bool) {
+	if s == nil || len(results) == 0 {
+		return 0, false
+	}
+	var first time.Duration
+	for i, r := range results {
+		remaining := time.Duration(0)
+		if r.certificate != nil && r.err == nil {
+			remaining = r.certificate.NotAfter.Sub(now)
+		}
+		if i == 0 || remaining < first {
+			first = remaining
+		}
+	}
+	for _, t := range s.tiers {
+		if first < t.within {
+			return t.every, true
+		}
+	}
+	return s.otherwise, s.otherwise > 0
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRecheckSchedule(t *testing.T) {
+	s, err := parseRecheckSchedule("1d:10m, 7d:1h,24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tiers) != 2 || s.tiers[0] != (recheckTier{within: 24 * time.Hour, every: 10 * time.Minute}) || s.tiers[1] != (recheckTier{within: 7 * 24 * time.Hour, every: time.Hour}) || s.otherwise != 24*time.Hour {
+		t.Errorf("unexpected schedule %+v", s)
+	}
+	if s, err = parseRecheckSchedule("7d:1h"); err != nil || s.otherwise != 0 {
+		t.Errorf("expected no interval beyond 7 days, got %+v, %v", s, err)
+	}
+	for _, invalid := range []string{"", "24h,7d:1h", "7d:1h,1d:10m", "7d:0s", "soon:1h", "7d:", "-1h"} {
+		if _, err := parseRecheckSchedule(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestRecheckAfter(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	s, err := parseRecheckSchedule("7d:1h,24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := func(days int) result {
+		return result{certificate: &certificate{NotAfter: now.AddDate(0, 0, days)}}
+	}
+	tests := []struct {
+		name    string
+		results []result
+		want    time.Duration
+		ok      bool
+	}{
+		{name: "nothing checked"},
+		{name: "far from expiry", results: []result{expires(90)}, want: 24 * time.Hour, ok: true},
+		{name: "first to expire", results: []result{expires(90), expires(3)}, want: time.Hour, ok: true},
+		{name: "expired", results: []result{expires(-1)}, want: time.Hour, ok: true},
+		{name: "failed", results: []result{expires(90), {err: errors.New("connection refused")}}, want: time.Hour, ok: true},
+	}
+	for _, test := range tests {
+		got, ok := s.after(test.results, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: expected %v, %v, got %v, %v", test.name, test.want, test.ok, got, ok)
+		}
+	}
+
+	if s, err = parseRecheckSchedule("7d:1h"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.after([]result{expires(90)}, now); ok {
+		t.Error("expected hosts beyond the last window not to be checked again")
+	}
+	var none *recheckSchedule
+	if _, ok := none.after([]result{expires(3)}, now); ok {
+		t.Error("expected no schedule not to check anything again")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// result is the outcome of checking a single target.
+type result struct {
+	target
+	certificate *certificate
+	// stored is the certificate found in the Secret of the target when the
+	// served one was compared against it.
+	stored *certificate
+	// certManager is the status of the cert-manager Certificate issuing
+	// into the Secret of the target, if any.
+	certManager *certManagerStatus
+	// attempts is the number of times the host was dialed.
+	attempts int
+	err      error
+	// change is what changed since the previous scan with -diff-with.
+	change string
+	// resolved are the addresses the host resolved to when it was dialed
+	// at its own name.
+	resolved []string
+}
+
+// mismatch reports whether the served certificate differs from the one
+// stored in the Secret of the target.
+func (r result) mismatch() bool {
+	return r.certificate != nil && r.stored != nil && r.certificate.Fingerprint != r.stored.Fingerprint
+}
+
+// printResults logs one line per result, with the object and host of the
+// result as structured fields. Failed checks are logged as errors.
+func printResults(results []result, p policy) {
+	now := time.Now()
+	for _, r := range results {
+		sev, grade := p.grade(r, now)
+		var fields []interface{}
+		if r.cluster != "" {
+			fields = append(fields, "cluster", r.cluster)
+		}
+		fields = append(fields,
+			"namespace", r.namespace,
+			"kind", r.kind,
+			"object", r.object,
+			"host", r.name(),
+			"severity", sev.String(),
+			"grade", grade,
+		)
+		if r.attempts > 1 {
+			fields = append(fields, "attempts", r.attempts)
+		}
+		if failure := r.failure(); failure != "" {
+			fields = append(fields, "failure", failure)
+		}
+		if len(r.resolved) > 0 {
+			fields = append(fields, "resolved", r.resolved)
+		}
+		if r.change != "" {
+			fields = append(fields, "change", r.change)
+		}
+		if r.certificate != nil {
+			fields = append(fields, "certificate", r.certificate.Jsonify())
+		}
+		if r.mismatch() {
+			fields = append(fields, "storedIn", r.storedIn(), "stored", r.stored.Jsonify())
+		}
+		if r.certManager != nil {
+			fields = append(fields, "certManager", r.certManager.String())
+		}
+		switch {
+		case r.err != nil:
+			klog.ErrorS(r.err, "Certificate check failed", fields...)
+		case r.mismatch():
+			klog.InfoS("Served certificate does not match the stored one", fields...)
+		default:
+			klog.InfoS("Certificate checked", fields...)
+		}
+	}
+}
+
+// reportFilter selects and orders the results of a scan that get reported.
+// Notifications and -fail-on always see every result.
+type reportFilter struct {
+	// sortBy is namespace (the order results are checked in), name or
+	// expiry.
+	sortBy string
+	// only is empty (every result), warnings (results that need
+	// attention), errors (the ones -fail-on=error fails on) or expired.
+	only string
+	// expiringWithin, if set, only keeps certificates expiring within it.
+	expiringWithin time.Duration
+}
+
+func newReportFilter(sortBy, only, expiringWithin string) (*reportFilter, error) {
+	f := &reportFilter{sortBy: sortBy, only: only}
+	switch sortBy {
+	case "namespace", "name", "expiry":
+	default:
+		return nil, fmt.Errorf("invalid -sort-by %q: must be expiry, name or namespace", sortBy)
+	}
+	switch only {
+	case "", "warnings", "errors", "expired":
+	default:
+		return nil, fmt.Errorf("invalid -only %q: must be warnings, errors or expired", only)
+	}
+	if expiringWithin != "" {
+		d, err := parseDuration(expiringWithin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -expiring-within: %v", err)
+		}
+		f.expiringWithin = d
+	}
+	return f, nil
+}
+
+// apply returns the results to report out of results. A nil filter
+// reports everything.
+func (f *reportFilter) apply(results []result, p policy, now time.Time) []result {
+	if f == nil {
+		return results
+	}
+	var filtered []result
+	for _, r := range results {
+		if f.matches(r, p.severity(r, now), now) {
+			filtered = append(filtered, r)
+		}
+	}
+	switch f.sortBy {
+	case "name":
+		sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].name() < filtered[j].name() })
+	case "expiry":
+		sort.SliceStable(filtered, func(i, j int) bool { return expiresBefore(filtered[i].certificate, filtered[j].certificate) })
+	}
+	return filtered
+}
+
+func (f *reportFilter) matches(r result, sev severity, now time.Time) bool {
+	switch f.only {
+	case "warnings":
+		if sev == severityOK {
+			return false
+		}
+	case "errors":
+		if sev != severityError && sev != severityRevoked && sev != severityExpired {
+			return false
+		}
+	case "expired":
+		if sev != severityExpired {
+			return false
+		}
+	}
+	if f.expiringWithin > 0 {
+		return r.certificate != nil && r.certificate.NotAfter.Before(now.Add(f.expiringWithin))
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReportFilter(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	expiresIn := func(namespace, host string, days int) result {
+		return result{target: target{namespace: namespace, host: host}, certificate: &certificate{NotAfter: now.AddDate(0, 0, days)}}
+	}
+	results := []result{
+		expiresIn("a", "z.example.com", 300),
+		expiresIn("a", "y.example.com", 10),
+		{target: target{namespace: "b", host: "x.example.com"}, err: errors.New("connection refused")},
+		expiresIn("b", "w.example.com", -1),
+		expiresIn("b", "v.example.com", 40),
+	}
+	tests := []struct {
+		sortBy, only, expiringWithin string
+		want                         []string
+	}{
+		{sortBy: "namespace", want: []string{"z.example.com", "y.example.com", "x.example.com", "w.example.com", "v.example.com"}},
+		{sortBy: "name", want: []string{"v.example.com", "w.example.com", "x.example.com", "y.example.com", "z.example.com"}},
+		{sortBy: "expiry", want: []string{"w.example.com", "y.example.com", "v.example.com", "z.example.com", "x.example.com"}},
+		{sortBy: "namespace", only: "warnings", want: []string{"y.example.com", "x.example.com", "w.example.com"}},
+		{sortBy: "namespace", only: "errors", want: []string{"x.example.com", "w.example.com"}},
+		{sortBy: "namespace", only: "expired", want: []string{"w.example.com"}},
+		{sortBy: "expiry", expiringWithin: "60d", want: []string{"w.example.com", "y.example.com", "v.example.com"}},
+		{sortBy: "namespace", only: "warnings", expiringWithin: "60d", want: []string{"y.example.com", "w.example.com"}},
+	}
+	for _, test := range tests {
+		f, err := newReportFilter(test.sortBy, test.only, test.expiringWithin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range f.apply(results, policy{days: 30}, now) {
+			got = append(got, r.host)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("-sort-by=%s -only=%s -expiring-within=%s: expected %v, got %v", test.sortBy, test.only, test.expiringWithin, test.want, got)
+		}
+	}
+
+	for _, invalid := range [][3]string{{"size", "", ""}, {"name", "ok", ""}, {"name", "", "soon"}} {
+		if _, err := newReportFilter(invalid[0], invalid[1], invalid[2]); err == nil {
+			t.Errorf("%v: expected an error", invalid)
+		}
+	}
+}
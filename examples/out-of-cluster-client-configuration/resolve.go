@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// ipVersion restricts the addresses hosts are dialed at to one family.
+type ipVersion string
+
+const (
+	ipVersion4    ipVersion = "4"
+	ipVersion6    ipVersion = "6"
+	ipVersionBoth ipVersion = "both"
+)
+
+func parseIPVersion(s string) (ipVersion, error) {
+	switch v := ipVersion(s); v {
+	case ipVersion4, ipVersion6, ipVersionBoth:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid -ip-version %q: must be 4, 6 or both", s)
+}
+
+// network returns the network hosts are dialed on.
+func (v ipVersion) network() string {
+	switch v {
+	case ipVersion4:
+		return "tcp4"
+	case ipVersion6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// matches reports whether ip is of the family of v.
+func (v ipVersion) matches(ip net.IP) bool {
+	switch v {
+	case ipVersion4:
+		return ip.To4() != nil
+	case ipVersion6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// backendResolver expands targets into a target per address their host
+// resolves to, so that endpoints balanced over several backends serving
+// different certificates are all checked.
+type backendResolver struct {
+	// lookup is net.DefaultResolver.LookupIPAddr, replaced in tests.
+	lookup  func(ctx context.Context, host string) ([]net.IPAddr, error)
+	version ipVersion
+	// connectTo are the hosts dialed at another address, which are not
+	// resolved.
+	connectTo map[string]string
+}
+
+// expand returns targets with every target dialed at its own host name
+// replaced by a target per address of the host. Targets whose host does not
+// resolve are kept as they are, dialing them reports why.
+func (r *backendResolver) expand(ctx context.Context, targets []target) []target {
+	var expanded []target
+	for _, t := range targets {
+		if t.caBundle || t.address != "" || r.connectTo[t.host] != "" || net.ParseIP(t.host) != nil {
+			expanded = append(expanded, t)
+			continue
+		}
+		addrs, err := r.lookup(ctx, t.host)
+		if err != nil {
+			expanded = append(expanded, t)
+			continue
+		}
+		port := t.port
+		if port == 0 {
+			port = defaultPort
+		}
+		var ips []string
+		for _, addr := range addrs {
+			if r.version.matches(addr.IP) {
+				ips = append(ips, addr.IP.String())
+			}
+		}
+		if len(ips) == 0 {
+			expanded = append(expanded, t)
+			continue
+		}
+		sort.Strings(ips)
+		for _, ip := range ips {
+			backend := t
+			backend.backend = ip
+			backend.address = net.JoinHostPort(ip, strconv.Itoa(port))
+			expanded = append(expanded, backend)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestBackendResolver(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "shop.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("10.0.0.2")}, {IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("10.0.0.1")}}, nil
+		case "v6.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("2001:db8::2")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	targets := []target{
+		{host: "shop.example.com", port: 8443},
+		{host: "v6.example.com"},
+		{host: "unknown.example.com"},
+		{host: "lb.example.com"},
+		{host: "10.0.0.3"},
+		{host: "webhook", caBundle: true},
+	}
+	tests := []struct {
+		version ipVersion
+		want    []string
+	}{
+		{ipVersionBoth, []string{
+			"shop.example.com:8443 (10.0.0.1)=10.0.0.1:8443",
+			"shop.example.com:8443 (10.0.0.2)=10.0.0.2:8443",
+			"shop.example.com:8443 (2001:db8::1)=[2001:db8::1]:8443",
+			"v6.example.com (2001:db8::2)=[2001:db8::2]:443",
+			"unknown.example.com=",
+			"lb.example.com=",
+			"10.0.0.3=",
+			"webhook=",
+		}},
+		{ipVersion4, []string{
+			"shop.example.com:8443 (10.0.0.1)=10.0.0.1:8443",
+			"shop.example.com:8443 (10.0.0.2)=10.0.0.2:8443",
+			// Without an IPv4 address, dialing reports the error.
+			"v6.example.com=",
+			"unknown.example.com=",
+			"lb.example.com=",
+			"10.0.0.3=",
+			"webhook=",
+		}},
+	}
+	for _, test := range tests {
+		r := &backendResolver{lookup: lookup, version: test.version, connectTo: map[string]string{"lb.example.com": "10.0.0.9"}}
+		var got []string
+		for _, t := range r.expand(context.Background(), targets) {
+			got = append(got, t.name()+"="+t.address)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("-ip-version=%s: expected %q, got %q", test.version, test.want, got)
+		}
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	for _, test := range []struct {
+		in, network string
+	}{
+		{"4", "tcp4"},
+		{"6", "tcp6"},
+		{"both", "tcp"},
+	} {
+		v, err := parseIPVersion(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.network() != test.network {
+			t.Errorf("%s: expected %s, got %s", test.in, test.network, v.network())
+		}
+	}
+	if _, err := parseIPVersion("5"); err == nil {
+		t.Error("expected an error")
+	}
+}
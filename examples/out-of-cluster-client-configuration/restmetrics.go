@@ -0,0 +1,190 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// restClientMetrics counts the requests of the REST clients to the API
+// servers: how long they took by verb, how they were answered, and how long
+// they waited for the client side rate limiter. Requests throttled by the
+// API server, for example by API Priority and Fairness, are answered with
+// 429.
+type restClientMetrics struct {
+	mu      sync.Mutex
+	latency map[string]*timing
+	results map[restResult]int
+	// throttled is the time requests spent waiting for the rate limiter.
+	throttled timing
+}
+
+// timing is how many times something happened and how long it took in
+// total.
+type timing struct {
+	count int
+	sum   time.Duration
+}
+
+// restResult is how a request was answered.
+type restResult struct {
+	code, method, host string
+}
+
+// newRESTClientMetrics returns the metrics of every REST client. client-go
+// records the metrics of every client in the process in the same place, so
+// the first one returned gets them.
+func newRESTClientMetrics() *restClientMetrics {
+	m := &restClientMetrics{latency: map[string]*timing{}, results: map[restResult]int{}}
+	metrics.Register(m, m)
+	return m
+}
+
+// Observe records the latency of a request, it is a metrics.LatencyMetric.
+// URLs are not recorded, they name every object.
+func (m *restClientMetrics) Observe(verb string, u url.URL, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.latency[verb]
+	if !ok {
+		t = &timing{}
+		m.latency[verb] = t
+	}
+	t.count++
+	t.sum += latency
+}
+
+// Increment records the answer to a request, it is a metrics.ResultMetric.
+func (m *restClientMetrics) Increment(code, method, host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[restResult{code: code, method: method, host: host}]++
+}
+
+// observeRateLimiter replaces the rate limiter of config, built from its QPS
+// and burst as client-go would, with one whose waits are recorded. Configs
+// without a limit keep none.
+func (m *restClientMetrics) observeRateLimiter(config *rest.Config) {
+	limiter := config.RateLimiter
+	if limiter == nil && config.QPS < 0 {
+		return
+	}
+	if limiter == nil {
+		qps, burst := config.QPS, config.Burst
+		if qps == 0 {
+			qps = rest.DefaultQPS
+		}
+		if burst == 0 {
+			burst = rest.DefaultBurst
+		}
+		limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	config.RateLimiter = &observedRateLimiter{RateLimiter: limiter, metrics: m}
+}
+
+// families returns the metric families of m.
+func (m *restClientMetrics) families() []metricFamily {
+	requests := metricFamily{
+		name: "cert_check_rest_client_requests_total",
+		help: "Number of requests to the API servers, by status code, method and host.",
+		typ:  "counter",
+	}
+	latencySum := metricFamily{
+		name: "cert_check_rest_client_request_duration_seconds_sum",
+		help: "Total time spent in requests to the API servers, by verb.",
+		typ:  "counter",
+	}
+	latencyCount := metricFamily{
+		name: "cert_check_rest_client_request_duration_seconds_count",
+		help: "Number of requests to the API servers whose duration was recorded, by verb.",
+		typ:  "counter",
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	results := make([]restResult, 0, len(m.results))
+	for r := range m.results {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, r := range results {
+		requests.samples = append(requests.samples, sample{
+			labels: map[string]string{"code": r.code, "method": r.method, "host": r.host},
+			value:  float64(m.results[r]),
+		})
+	}
+	verbs := make([]string, 0, len(m.latency))
+	for verb := range m.latency {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	for _, verb := range verbs {
+		t := m.latency[verb]
+		latencySum.samples = append(latencySum.samples, sample{labels: map[string]string{"verb": verb}, value: t.sum.Seconds()})
+		latencyCount.samples = append(latencyCount.samples, sample{labels: map[string]string{"verb": verb}, value: float64(t.count)})
+	}
+	return []metricFamily{
+		requests,
+		latencySum,
+		latencyCount,
+		{
+			name:    "cert_check_rest_client_rate_limiter_duration_seconds_sum",
+			help:    "Total time requests to the API servers waited for the client side rate limiter.",
+			typ:     "counter",
+			samples: []sample{{value: m.throttled.sum.Seconds()}},
+		},
+		{
+			name:    "cert_check_rest_client_rate_limiter_duration_seconds_count",
+			help:    "Number of requests to the API servers that went through the client side rate limiter.",
+			typ:     "counter",
+			samples: []sample{{value: float64(m.throttled.count)}},
+		},
+	}
+}
+
+// observedRateLimiter records how long the requests of a REST client wait
+// for its rate limiter.
+type observedRateLimiter struct {
+	flowcontrol.RateLimiter
+	metrics *restClientMetrics
+}
+
+func (l *observedRateLimiter) Accept() {
+	start := time.Now()
+	l.RateLimiter.Accept()
+	waited := time.Since(start)
+	l.metrics.mu.Lock()
+	defer l.metrics.mu.Unlock()
+	l.metrics.throttled.count++
+	l.metrics.throttled.sum += waited
+}
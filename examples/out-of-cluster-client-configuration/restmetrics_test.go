@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestRESTClientMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"shop"}}`))
+	}))
+	defer server.Close()
+
+	m := newRESTClientMetrics()
+	config := &rest.Config{Host: server.URL, QPS: 10, Burst: 1}
+	m.observeRateLimiter(config)
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core := clientset.CoreV1().RESTClient()
+	for _, name := range []string{"shop", "missing"} {
+		core.Get().Resource("namespaces").Name(name).Do()
+	}
+
+	var b bytes.Buffer
+	if err := writeMetrics(&b, m.families()); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	for _, expected := range []string{
+		"# TYPE cert_check_rest_client_requests_total counter\n",
+		`cert_check_rest_client_requests_total{code="200",host="` + u.Host + `",method="GET"} 1` + "\n",
+		`cert_check_rest_client_requests_total{code="404",host="` + u.Host + `",method="GET"} 1` + "\n",
+		`cert_check_rest_client_request_duration_seconds_count{verb="GET"} 2` + "\n",
+		"cert_check_rest_client_rate_limiter_duration_seconds_count 2\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in\n%s", expected, b.String())
+		}
+	}
+	unlimited := &rest.Config{QPS: -1}
+	m.observeRateLimiter(unlimited)
+	if unlimited.RateLimiter != nil {
+		t.Errorf("expected no rate limiter without limit, got %v", unlimited.RateLimiter)
+	}
+	// The second request waited for the next token, 10 per second.
+	if m.throttled.sum.Seconds() < 0.05 {
+		t.Errorf("expected the second request to be throttled, waited %v", m.throttled.sum)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// retriedError is the error of a host that kept failing with a transient
+// error after every retry.
+type retriedError struct {
+	attempts int
+	err      error
+}
+
+func (e *retriedError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", e.attempts, e.err)
+}
+
+func (e *retriedError) Unwrap() error {
+	return e.err
+}
+
+// checkHostWithRetries is checkHost retried on transient failures, with
+// every attempt bounded by the timeout of d. It returns the number of
+// attempts made along with the result of the last one.
+func (d *dialer) checkHostWithRetries(ctx context.Context, host, addr string) (*certificate, int, error) {
+	backoff := d.retry
+	for attempt := 1; ; attempt++ {
+		// Waiting for the limiter does not count against the timeout.
+		if err := d.limiter.wait(ctx, host, func() []string { return d.dialedIPs(ctx, addr) }); err != nil {
+			return nil, attempt, err
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, d.timeout)
+		c, err := d.checkHost(attemptCtx, host, addr)
+		cancel()
+		if ctx.Err() != nil || !transient(err) {
+			return c, attempt, err
+		}
+		if backoff.Steps < 1 {
+			if attempt > 1 {
+				err = &retriedError{attempts: attempt, err: err}
+			}
+			return c, attempt, err
+		}
+		delay := backoff.Step()
+		klog.V(2).InfoS("Retrying host", "host", host, "address", addr, "attempt", attempt, "delay", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return c, attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// transient reports whether err is a network failure that may not happen
+// again: timeouts, refused or reset connections and connections closed
+// during the handshake. Certificates failing verification, hosts that do not
+// exist and TLS alerts sent by the host are not.
+func transient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == context.DeadlineExceeded || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch opErr.Op {
+		case "dial", "read", "write":
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
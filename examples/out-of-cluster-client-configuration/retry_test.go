@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// flakyListener closes the first failures connections it accepts.
+type flakyListener struct {
+	net.Listener
+	failures int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || atomic.AddInt32(&l.failures, -1) < 0 {
+			return conn, err
+		}
+		conn.Close()
+	}
+}
+
+func TestCheckHostWithRetries(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = &flakyListener{Listener: server.Listener, failures: 2}
+	server.StartTLS()
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	d := &dialer{timeout: 5 * time.Second, roots: roots, retry: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 2}}
+	c, attempts, err := d.checkHostWithRetries(context.Background(), "127.0.0.1", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 || c.Fingerprint != fingerprint(server.Certificate()) {
+		t.Errorf("expected the certificate of the server after 3 attempts, got %+v after %d", c, attempts)
+	}
+
+	// Nothing listens on a closed server any more.
+	server.Close()
+	_, attempts, err = d.checkHostWithRetries(context.Background(), "127.0.0.1", addr)
+	var retried *retriedError
+	if !errors.As(err, &retried) || attempts != 3 || retried.attempts != 3 {
+		t.Errorf("expected to fail after 3 attempts, got %v after %d", err, attempts)
+	}
+}
+
+func TestCheckHostWithRetriesVerifyError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	// Certificates failing verification are not retried.
+	d := &dialer{timeout: 5 * time.Second, roots: x509.NewCertPool(), retry: wait.Backoff{Duration: time.Millisecond, Steps: 2}}
+	_, attempts, err := d.checkHostWithRetries(context.Background(), "127.0.0.1", addr)
+	if _, ok := err.(x509.UnknownAuthorityError); !ok || attempts != 1 {
+		t.Errorf("expected an unknown authority error after a single attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{context.DeadlineExceeded, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{&net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}, false},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}, false},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}}, true},
+		{x509.HostnameError{}, false},
+		{errors.New("no certificate set in the Ingress"), false},
+	}
+	for _, test := range tests {
+		if got := transient(test.err); got != test.want {
+			t.Errorf("%v: expected %v, got %v", test.err, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// loadRoots returns the system certificate authorities extended with the
+// ones in the PEM bundle file and in every .pem, .crt and .cer file of dir.
+// It returns nil, meaning the system ones, when both are empty.
+func loadRoots(file, dir string) (*x509.CertPool, error) {
+	if file == "" && dir == "" {
+		return nil, nil
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+
+	var files []string
+	if file != "" {
+		files = append(files, file)
+	}
+	if dir != "" {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".pem", ".crt", ".cer":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(dir, e.Name()))
+				}
+			}
+		}
+	}
+
+	for _, f := range files {
+		certs, err := certutil.CertsFromFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificate authorities: %v", err)
+		}
+		for _, crt := range certs {
+			roots.AddCert(crt)
+		}
+	}
+	return roots, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// routeKind is the kind of the targets taken from OpenShift routes.
+const routeKind = "Route"
+
+// routesResource is the resource of OpenShift routes. Routes are read with
+// the dynamic client so that the checker does not depend on the OpenShift
+// client libraries.
+var routesResource = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+
+// routeSource returns a source checking the hosts of the routes served by
+// informer on ports, unless a route says otherwise.
+func routeSource(informer cache.SharedIndexInformer, ports []int) *source {
+	return &source{
+		kind:     routeKind,
+		informer: informer,
+		targets: func(obj interface{}) []target {
+			return routeTargets(obj.(*unstructured.Unstructured), ports)
+		},
+		changed: func(old, new interface{}) bool {
+			oldRoute := old.(*unstructured.Unstructured)
+			newRoute := new.(*unstructured.Unstructured)
+			oldHost, _, _ := unstructured.NestedString(oldRoute.Object, "spec", "host")
+			newHost, _, _ := unstructured.NestedString(newRoute.Object, "spec", "host")
+			oldTLS, _, _ := unstructured.NestedFieldNoCopy(oldRoute.Object, "spec", "tls")
+			newTLS, _, _ := unstructured.NestedFieldNoCopy(newRoute.Object, "spec", "tls")
+			return oldHost != newHost || !reflect.DeepEqual(oldTLS, newTLS) ||
+				checkerAnnotationsChanged(oldRoute.GetAnnotations(), newRoute.GetAnnotations())
+		},
+	}
+}
+
+// routeTargets returns a target for every port of the host of route if it
+// terminates TLS. The certificate and key embedded in spec.tls, if any, are
+// kept on the targets so that they can be checked without dialing. Routes
+// with passthrough termination have none: their backend serves its own
+// certificate. Neither do routes with the ignore annotation.
+func routeTargets(route *unstructured.Unstructured, ports []int) []target {
+	if ignored(route.GetNamespace(), route.GetName(), route.GetAnnotations()) {
+		return nil
+	}
+	tls, ok, err := unstructured.NestedMap(route.Object, "spec", "tls")
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid spec.tls", "kind", routeKind, "object", klog.KObj(route))
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	if host == "" {
+		return nil
+	}
+	certificatePEM, _, _ := unstructured.NestedString(tls, "certificate")
+	keyPEM, _, _ := unstructured.NestedString(tls, "key")
+
+	var targets []target
+	for _, port := range annotatedPorts(route.GetNamespace(), route.GetName(), route.GetAnnotations(), ports) {
+		t := target{
+			namespace: route.GetNamespace(),
+			kind:      routeKind,
+			object:    route.GetName(),
+			host:      host,
+			port:      port,
+			ref:       objectReference(route.GetAPIVersion(), route.GetKind(), route),
+			alpn:      annotatedALPN(route.GetNamespace(), route.GetName(), route.GetAnnotations()),
+		}
+		if certificatePEM != "" {
+			t.certificatePEM = []byte(certificatePEM)
+		}
+		if keyPEM != "" {
+			t.keyPEM = []byte(keyPEM)
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
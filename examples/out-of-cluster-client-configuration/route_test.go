@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRouteTargets(t *testing.T) {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "route.openshift.io/v1",
+		"kind":       "Route",
+		"metadata": map[string]interface{}{
+			"namespace":   "shop",
+			"name":        "web",
+			"annotations": map[string]interface{}{portAnnotation: "443,8443"},
+		},
+		"spec": map[string]interface{}{
+			"host": "shop.example.com",
+			"tls": map[string]interface{}{
+				"termination": "edge",
+				"certificate": "CERTIFICATE",
+			},
+		},
+	}}
+
+	var got []string
+	for _, target := range routeTargets(route, []int{defaultPort}) {
+		got = append(got, target.name())
+		if target.namespace != "shop" || target.kind != routeKind || target.object != "web" || string(target.certificatePEM) != "CERTIFICATE" {
+			t.Errorf("unexpected target %+v", target)
+		}
+	}
+	if want := []string{"shop.example.com", "shop.example.com:8443"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Routes without TLS are not checked.
+	unstructured.RemoveNestedField(route.Object, "spec", "tls")
+	if targets := routeTargets(route, []int{defaultPort}); len(targets) != 0 {
+		t.Errorf("expected no target for a plain HTTP route, got %+v", targets)
+	}
+}
+
+func TestEmbeddedCertificate(t *testing.T) {
+	crt, key := newTestCertificate(t, "shop.example.com", false, nil, nil)
+	_, otherKey := newTestCertificate(t, "other.example.com", false, nil, nil)
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	keyPEM := func(t *testing.T, key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	target := target{namespace: "shop", kind: routeKind, object: "web", certificatePEM: certificatePEM, keyPEM: keyPEM(t, key)}
+	c, err := embeddedCertificate(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CommonName != "shop.example.com" {
+		t.Errorf("expected the embedded certificate, got %+v", c)
+	}
+
+	target.keyPEM = keyPEM(t, otherKey)
+	if _, err := embeddedCertificate(target); err == nil {
+		t.Error("expected an error for a key not matching the certificate")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// How the host of a target is covered by its certificate.
+const (
+	coverageExact    = "exact"
+	coverageWildcard = "wildcard"
+	// coverageCommonName is a host only matching the CommonName of a
+	// certificate without subject alternative names. Clients stopped
+	// accepting those.
+	coverageCommonName = "commonName"
+	coverageNone       = "none"
+)
+
+// hostCoverage returns how host is covered by crt.
+func hostCoverage(crt *x509.Certificate, host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, candidate := range crt.IPAddresses {
+			if candidate.Equal(ip) {
+				return coverageExact
+			}
+		}
+		return coverageNone
+	}
+
+	host = normalizeHostname(host)
+	coverage := coverageNone
+	for _, name := range crt.DNSNames {
+		name = normalizeHostname(name)
+		switch {
+		case name == host:
+			return coverageExact
+		case matchesWildcard(name, host):
+			coverage = coverageWildcard
+		}
+	}
+	if coverage == coverageNone && len(crt.DNSNames) == 0 && len(crt.IPAddresses) == 0 {
+		cn := normalizeHostname(crt.Subject.CommonName)
+		if cn == host || matchesWildcard(cn, host) {
+			return coverageCommonName
+		}
+	}
+	return coverage
+}
+
+func normalizeHostname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// matchesWildcard reports whether pattern is a wildcard name, e.g.
+// *.example.com, matching the single leftmost label of host.
+func matchesWildcard(pattern, host string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	i := strings.Index(host, ".")
+	return i > 0 && host[i:] == pattern[1:]
+}
+
+// hostnameError explains why crt is not valid for host, given its coverage.
+func hostnameError(crt *x509.Certificate, host, coverage string) error {
+	if coverage == coverageCommonName {
+		return fmt.Errorf("%s is only covered by the CommonName of the certificate, which clients no longer accept without a matching subject alternative name", host)
+	}
+	var names []string
+	names = append(names, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%s is not covered by the certificate, which has no subject alternative names", host)
+	}
+	return fmt.Errorf("%s is not covered by the certificate, which is only valid for %s", host, strings.Join(names, ", "))
+}
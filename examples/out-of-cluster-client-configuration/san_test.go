@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+)
+
+func TestHostCoverage(t *testing.T) {
+	san := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "shop.example.com"},
+		DNSNames:    []string{"shop.example.com", "*.api.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	cnOnly := &x509.Certificate{Subject: pkix.Name{CommonName: "legacy.example.com"}}
+	tests := []struct {
+		crt  *x509.Certificate
+		host string
+		want string
+	}{
+		{san, "shop.example.com", coverageExact},
+		{san, "SHOP.example.com.", coverageExact},
+		{san, "v1.api.example.com", coverageWildcard},
+		{san, "api.example.com", coverageNone},
+		{san, "a.v1.api.example.com", coverageNone},
+		{san, "10.0.0.1", coverageExact},
+		{san, "10.0.0.2", coverageNone},
+		{cnOnly, "legacy.example.com", coverageCommonName},
+		{cnOnly, "other.example.com", coverageNone},
+	}
+	for _, test := range tests {
+		if got := hostCoverage(test.crt, test.host); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.host, test.want, got)
+		}
+	}
+
+	err := hostnameError(san, "www.example.com", coverageNone)
+	if want := "www.example.com is not covered by the certificate, which is only valid for shop.example.com, *.api.example.com, 10.0.0.1"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// scanner checks targets and reports the results.
+type scanner struct {
+	check checker
+	// concurrency is the number of targets checked in parallel.
+	concurrency int
+	policy      policy
+	// report reports the results of every scan, printResults unless
+	// another output format was asked for.
+	report reporter
+	// exclude, if set, leaves out the targets that are never checked.
+	exclude *exclusion
+	// expand, if set, is applied to targets before they are checked, e.g.
+	// to check every address of their hosts.
+	expand func(ctx context.Context, targets []target) []target
+	// diff, if set, only reports the results that changed since the
+	// previous scan.
+	diff *scanDiff
+	// filter selects the results that get reported, if set.
+	filter    *reportFilter
+	notifiers []notifier
+	// flushTimeout bounds the notifications of a scan that was interrupted,
+	// which are sent anyway with the results gathered so far.
+	flushTimeout time.Duration
+}
+
+// reporter reports the results of a scan.
+type reporter func(results []result, p policy)
+
+// scan checks targets, reports the results and sends the summary of the
+// ones that need attention to every notifier.
+func (s *scanner) scan(ctx context.Context, targets []target) []result {
+	results, _ := s.run(ctx, targets, scopeScan, nil)
+	return results
+}
+
+// scanObject is scan for the targets of object in watch mode, nil for the
+// static targets. Its summary, also returned, is not sent to round
+// notifiers.
+func (s *scanner) scanObject(ctx context.Context, object *objectKey, targets []target) ([]result, *summary) {
+	return s.run(ctx, targets, scopeObject, object)
+}
+
+// forget sends the notifiers the summary of object, which was deleted, so
+// that the ones keeping the results of every object drop its own.
+func (s *scanner) forget(ctx context.Context, object objectKey) {
+	sum := newSummary(nil, s.policy, time.Now())
+	sum.scope = scopeObject
+	sum.object = &object
+	notifyAll(ctx, s.notifiers, sum)
+}
+
+// notifyRound sends the summary of the scan round r to the round notifiers.
+func (s *scanner) notifyRound(ctx context.Context, r *scanRound) {
+	now := time.Now()
+	sum := newSummary(r.results, s.policy, now)
+	sum.duration = now.Sub(r.started)
+	sum.scope = scopeRound
+	if s.diff != nil {
+		sum.changes = summarize(r.changes, s.policy, now, severityOK)
+	}
+	klog.V(2).InfoS("Scan round done", "results", len(r.results), "duration", sum.duration)
+	notifyAll(ctx, s.notifiers, sum)
+}
+
+func (s *scanner) run(ctx context.Context, targets []target, scope summaryScope, object *objectKey) ([]result, *summary) {
+	targets = s.exclude.apply(targets)
+	if s.expand != nil {
+		targets = s.expand(ctx, targets)
+	}
+	sortTargets(targets)
+	klog.V(2).InfoS("Scanning", "targets", len(targets))
+	start := time.Now()
+	results := checkTargets(ctx, targets, s.concurrency, s.check)
+	duration := time.Since(start)
+	reported := results
+	var changes *summary
+	if s.diff != nil {
+		reported = s.diff.update(results, time.Now())
+		changes = summarize(reported, s.policy, time.Now(), severityOK)
+	}
+	s.report(s.filter.apply(reported, s.policy, time.Now()), s.policy)
+	sum := newSummary(results, s.policy, time.Now())
+	sum.duration = duration
+	sum.Interrupted = ctx.Err() != nil
+	sum.changes = changes
+	sum.scope = scope
+	sum.object = object
+	if sum.Interrupted {
+		klog.InfoS("Scan interrupted, the results are partial", "checked", len(results)-sum.Unchecked, "unchecked", sum.Unchecked)
+		if s.flushTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(context.Background(), s.flushTimeout)
+			defer cancel()
+		}
+	}
+	notifyAll(ctx, s.notifiers, sum)
+	return results, sum
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+)
+
+// scope restricts which objects are checked.
+type scope struct {
+	namespace     string
+	allNamespaces bool
+	labelSelector string
+	fieldSelector string
+	// secretSelector further restricts the Opaque Secrets scanned with
+	// -resources=secrets.
+	secretSelector string
+}
+
+// listOptions returns the namespace objects are listed in (all namespaces
+// when empty) and the function restricting list options to s. Both selectors
+// are parsed up front so that a typo fails the run instead of silently
+// matching nothing.
+func (s scope) listOptions() (string, func(*metav1.ListOptions), error) {
+	labelSelector, err := labels.Parse(s.labelSelector)
+	if err != nil {
+		return "", nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(s.fieldSelector)
+	if err != nil {
+		return "", nil, err
+	}
+
+	namespace := metav1.NamespaceAll
+	if !s.allNamespaces {
+		namespace = s.namespace
+	}
+	return namespace, func(options *metav1.ListOptions) {
+		options.LabelSelector = labelSelector.String()
+		options.FieldSelector = fieldSelector.String()
+	}, nil
+}
+
+// informerOptions returns the shared informer factory options that limit the
+// listed objects to s.
+func (s scope) informerOptions() ([]informers.SharedInformerOption, error) {
+	namespace, tweak, err := s.listOptions()
+	if err != nil {
+		return nil, err
+	}
+	return []informers.SharedInformerOption{
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(tweak),
+	}, nil
+}
+
+// secretListOptions returns the namespace Opaque Secrets are listed in and the
+// function restricting list options to the Opaque Secrets of s matching its
+// secret selector as well.
+func (s scope) secretListOptions() (string, func(*metav1.ListOptions), error) {
+	secretSelector, err := labels.Parse(s.secretSelector)
+	if err != nil {
+		return "", nil, err
+	}
+	namespace, tweak, err := s.listOptions()
+	if err != nil {
+		return "", nil, err
+	}
+	return namespace, func(options *metav1.ListOptions) {
+		tweak(options)
+		options.LabelSelector = joinSelectors(options.LabelSelector, secretSelector.String())
+		options.FieldSelector = joinSelectors(options.FieldSelector, opaqueSecretSelector)
+	}, nil
+}
+
+// opaqueSecretSelector selects Opaque Secrets.
+const opaqueSecretSelector = "type=Opaque"
+
+// joinSelectors returns the selector matching both a and b, either of which
+// may be empty.
+func joinSelectors(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "," + b
+}
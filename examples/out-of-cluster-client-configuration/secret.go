@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// secretChecker returns a checker that reports on the certificate stored in
+// the Secret referenced by a target, or embedded in its object, without
+// dialing its host.
+func secretChecker(client rest.Interface) checker {
+	return func(ctx context.Context, t target) result {
+		c, err := storedCertificate(ctx, client, t)
+		return result{target: t, certificate: c, err: err}
+	}
+}
+
+// storedCertificate returns the leaf certificate embedded in the object of t,
+// or else stored in the Secret it references.
+func storedCertificate(ctx context.Context, client rest.Interface, t target) (*certificate, error) {
+	if t.certificatePEM != nil || t.certificateFile != "" {
+		return embeddedCertificate(t)
+	}
+	if t.secretName == "" {
+		return nil, fmt.Errorf("no certificate set in the %s, its controller serves the default certificate", strings.ToLower(t.kind))
+	}
+	namespace, name := t.secretRef()
+	return secretCertificate(ctx, client, namespace, name)
+}
+
+// embeddedCertificate returns the leaf certificate embedded in the object of
+// t, or in the files it references, after making sure that the key, if any,
+// belongs to it.
+func embeddedCertificate(t target) (*certificate, error) {
+	var err error
+	if len(t.certificatePEM) == 0 && t.certificateFile != "" {
+		if t.certificatePEM, err = ioutil.ReadFile(t.certificateFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.keyPEM) == 0 && t.keyFile != "" {
+		if t.keyPEM, err = ioutil.ReadFile(t.keyFile); err != nil {
+			return nil, err
+		}
+	}
+	if t.keyPEM != nil {
+		if _, err := tls.X509KeyPair(t.certificatePEM, t.keyPEM); err != nil {
+			return nil, fmt.Errorf("%s %s/%s: invalid certificate and key: %v", strings.ToLower(t.kind), t.namespace, t.object, err)
+		}
+	}
+	chain, err := certutil.ParseCertsPEM(t.certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s/%s: %v", strings.ToLower(t.kind), t.namespace, t.object, err)
+	}
+	return newCertificate(leafCertificate(chain)), nil
+}
+
+// secretCertificate returns the leaf certificate of the PEM chain stored in
+// the kubernetes.io/tls Secret namespace/name.
+func secretCertificate(ctx context.Context, client rest.Interface, namespace, name string) (*certificate, error) {
+	secret, err := getSecret(ctx, client, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[v1.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, name, v1.TLSCertKey)
+	}
+	chain, err := certutil.ParseCertsPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s/%s: %v", namespace, name, err)
+	}
+	return newCertificate(leafCertificate(chain)), nil
+}
+
+// clientCertificateChecker returns a checker reading the client certificate
+// of targets with a client certificate Secret before check dials them.
+func clientCertificateChecker(check checker, client rest.Interface) checker {
+	return func(ctx context.Context, t target) result {
+		if t.clientSecret == "" {
+			return check(ctx, t)
+		}
+		crt, err := secretKeyPair(ctx, client, t.namespace, t.clientSecret)
+		if err != nil {
+			return result{target: t, err: fmt.Errorf("reading the client certificate: %v", err)}
+		}
+		t.clientCertificate = crt
+		return check(ctx, t)
+	}
+}
+
+// secretKeyPair returns the certificate and key stored in the
+// kubernetes.io/tls Secret namespace/name.
+func secretKeyPair(ctx context.Context, client rest.Interface, namespace, name string) (*tls.Certificate, error) {
+	secret, err := getSecret(ctx, client, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("secret %s/%s: %v", namespace, name, err)
+	}
+	return &crt, nil
+}
+
+// getSecret returns the Secret namespace/name. client must be a core/v1 REST
+// client; it is used directly so that the request is bound to ctx.
+func getSecret(ctx context.Context, client rest.Interface, namespace, name string) (*v1.Secret, error) {
+	secret := &v1.Secret{}
+	err := client.Get().
+		Namespace(namespace).
+		Resource("secrets").
+		Name(name).
+		Context(ctx).
+		Do().
+		Into(secret)
+	return secret, err
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// serviceKind is the kind of the targets taken from services.
+const serviceKind = "Service"
+
+// serviceSource returns a source checking the LoadBalancer services served
+// by informer that opted in with the enabled annotation, on ports unless a
+// service says otherwise.
+func serviceSource(informer coreinformers.ServiceInformer, ports []int) *source {
+	return &source{
+		kind:     serviceKind,
+		informer: informer.Informer(),
+		targets: func(obj interface{}) []target {
+			return serviceTargets(obj.(*v1.Service), ports)
+		},
+		changed: func(old, new interface{}) bool {
+			oldSvc := old.(*v1.Service)
+			newSvc := new.(*v1.Service)
+			return oldSvc.Spec.Type != newSvc.Spec.Type ||
+				checkerAnnotationsChanged(oldSvc.Annotations, newSvc.Annotations) ||
+				!reflect.DeepEqual(oldSvc.Status.LoadBalancer, newSvc.Status.LoadBalancer)
+		},
+	}
+}
+
+// serviceTargets returns a target for every port of svc, dialed at the
+// address of its load balancer, if svc is a LoadBalancer service with the
+// enabled annotation. The host checked is the one of the host annotation, or
+// else the hostname or IP of the load balancer. The ports are taken from the
+// port annotation of svc, or are ports if it has none. Services whose load
+// balancer has no address yet have none.
+func serviceTargets(svc *v1.Service, ports []int) []target {
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer || !enabled(svc.Namespace, svc.Name, svc.Annotations) || ignored(svc.Namespace, svc.Name, svc.Annotations) {
+		return nil
+	}
+	var address, host string
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			address, host = lb.IP, lb.IP
+		} else {
+			address, host = lb.Hostname, lb.Hostname
+		}
+		if address != "" {
+			break
+		}
+	}
+	if address == "" {
+		return nil
+	}
+	if h := svc.Annotations[hostAnnotation]; h != "" {
+		host = h
+	}
+	ports = annotatedPorts(svc.Namespace, svc.Name, svc.Annotations, ports)
+	warnBefore := annotatedWarnBefore(svc.Namespace, svc.Name, svc.Annotations)
+	alpn := annotatedALPN(svc.Namespace, svc.Name, svc.Annotations)
+	protocol := annotatedProtocol(svc.Namespace, svc.Name, svc.Annotations)
+
+	var targets []target
+	for _, port := range ports {
+		targets = append(targets, target{
+			namespace:  svc.Namespace,
+			kind:       serviceKind,
+			object:     svc.Name,
+			host:       host,
+			port:       port,
+			address:    address,
+			ref:        objectReference("v1", serviceKind, svc),
+			warnBefore: warnBefore,
+			alpn:       alpn,
+			protocol:   protocol,
+		})
+	}
+	return targets
+}
+
+// enabled reports whether the enabled annotation of the object
+// namespace/name is set to true.
+func enabled(namespace, name string, annotations map[string]string) bool {
+	value, ok := annotations[enabledAnnotation]
+	if !ok {
+		return false
+	}
+	enable, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid annotation", "object", klog.KRef(namespace, name), "annotation", enabledAnnotation)
+		return false
+	}
+	return enable
+}
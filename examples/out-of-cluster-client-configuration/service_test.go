@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceTargets(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "mq", Name: "broker", Annotations: map[string]string{
+			enabledAnnotation: "true",
+			portAnnotation:    "5671,8883",
+		}},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{
+			{Hostname: "broker-123.elb.example.com"},
+		}}},
+	}
+
+	targets := serviceTargets(svc, []int{443})
+	if len(targets) != 2 {
+		t.Fatalf("expected a target per annotated port, got %+v", targets)
+	}
+	if got := targets[0]; got.host != "broker-123.elb.example.com" || got.address != "broker-123.elb.example.com" || got.port != 5671 || got.kind != serviceKind || got.ref == nil {
+		t.Errorf("unexpected target %+v", got)
+	}
+
+	svc.Annotations[hostAnnotation] = "mq.example.com"
+	if got := serviceTargets(svc, nil); got[1].host != "mq.example.com" || got[1].address != "broker-123.elb.example.com" || got[1].name() != "mq.example.com:8883" {
+		t.Errorf("expected the annotated host dialed at the load balancer, got %+v", got[1])
+	}
+
+	for name, change := range map[string]func(*v1.Service){
+		"not enabled":      func(s *v1.Service) { delete(s.Annotations, enabledAnnotation) },
+		"ignored":          func(s *v1.Service) { s.Annotations[ignoreAnnotation] = "true" },
+		"not LoadBalancer": func(s *v1.Service) { s.Spec.Type = v1.ServiceTypeClusterIP },
+		"no address yet":   func(s *v1.Service) { s.Status.LoadBalancer.Ingress = nil },
+	} {
+		s := svc.DeepCopy()
+		change(s)
+		if got := serviceTargets(s, []int{443}); len(got) != 0 {
+			t.Errorf("%s: expected no target, got %+v", name, got)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// severity classifies a result, from fine to failed.
+type severity int
+
+const (
+	severityOK severity = iota
+	// severityWarning is used for certificates expiring within the warning
+	// window, signed with a sunset algorithm, failing a tolerated
+	// verification, or not matching their Secret.
+	severityWarning
+	severityExpired
+	// severityRevoked is used for certificates revoked by their issuer.
+	severityRevoked
+	// severityError is used for hosts whose certificate could not be
+	// checked at all.
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityOK:
+		return "OK"
+	case severityWarning:
+		return "WARNING"
+	case severityExpired:
+		return "EXPIRED"
+	case severityRevoked:
+		return "REVOKED"
+	case severityError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// policy classifies results. Certificates expiring within years, months and
+// days from now are warnings, and so are certificates with RSA keys shorter
+// than minRSABits or elliptic curve keys smaller than minECBits, in the
+// served chain too. The grading rules of the policy file, if any, come
+// first.
+type policy struct {
+	years, months, days   int
+	minRSABits, minECBits int
+	rules                 []gradingRule
+}
+
+// severity returns the severity of r at now, see grade.
+func (p policy) severity(r result, now time.Time) severity {
+	sev, _ := p.grade(r, now)
+	return sev
+}
+
+// builtinSeverity returns the severity of r at now without the grading
+// rules.
+func (p policy) builtinSeverity(r result, now time.Time) severity {
+	c := r.certificate
+	if c == nil {
+		return severityError
+	}
+	// An expired or revoked certificate fails verification too, but that is
+	// the more useful thing to say about it.
+	switch {
+	case c.revoked():
+		return severityRevoked
+	case !now.Before(c.NotAfter):
+		return severityExpired
+	case r.err != nil:
+		return severityError
+	case p.expiresSoon(r.target, c, now):
+		return severityWarning
+	case c.Sunset != nil && !c.NotAfter.Before(*c.Sunset):
+		return severityWarning
+	case len(p.weakKeys(c)) > 0:
+		return severityWarning
+	case c.VerifyError != "":
+		return severityWarning
+	case c.Protocol != nil && c.Protocol.Weak != "":
+		return severityWarning
+	case c.Transparency != nil && c.Transparency.Missing:
+		return severityWarning
+	case c.IncompleteChain != "" || c.chainExpiresFirst():
+		return severityWarning
+	case r.mismatch():
+		return severityWarning
+	}
+	return severityOK
+}
+
+// expiresSoon reports whether c, the certificate of t, expires within the
+// warning window of t from now: the one t overrides the policy with, if any,
+// or the one of p.
+func (p policy) expiresSoon(t target, c *certificate, now time.Time) bool {
+	if t.warnBefore > 0 {
+		return c.NotAfter.Before(now.Add(t.warnBefore))
+	}
+	return c.NotAfter.Before(now.AddDate(p.years, p.months, p.days))
+}
+
+// warnsAt returns when c, the certificate of t, enters the warning window of
+// t.
+func (p policy) warnsAt(t target, c *certificate) time.Time {
+	if t.warnBefore > 0 {
+		return c.NotAfter.Add(-t.warnBefore)
+	}
+	return c.NotAfter.AddDate(-p.years, -p.months, -p.days)
+}
+
+// failOn is the threshold at which a one-shot run exits non-zero. Errors,
+// expired and revoked certificates always exceed it.
+type failOn struct {
+	// warnings fails the run on any warning.
+	warnings bool
+	// within, if set, fails the run on certificates expiring within it.
+	within time.Duration
+}
+
+// parseFailOn parses the value of the -fail-on flag: error, warning or
+// expiring<duration>, e.g. expiring7d.
+func parseFailOn(s string) (*failOn, error) {
+	switch {
+	case s == "error":
+		return &failOn{}, nil
+	case s == "warning":
+		return &failOn{warnings: true}, nil
+	case strings.HasPrefix(s, "expiring"):
+		within, err := parseDuration(strings.TrimPrefix(s, "expiring"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid -fail-on %q: %v", s, err)
+		}
+		return &failOn{within: within}, nil
+	}
+	return nil, fmt.Errorf("invalid -fail-on %q: must be error, warning or expiring<duration>", s)
+}
+
+// exceeded reports whether any of results exceeds the threshold.
+func (f *failOn) exceeded(p policy, results []result, now time.Time) bool {
+	for _, r := range results {
+		switch p.severity(r, now) {
+		case severityError, severityRevoked, severityExpired:
+			return true
+		case severityWarning:
+			if f.warnings {
+				return true
+			}
+		}
+		if f.within > 0 && r.certificate != nil && r.certificate.NotAfter.Before(now.Add(f.within)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "90m", want: 90 * time.Minute},
+		{in: "7d", want: 7 * 24 * time.Hour},
+		{in: "1d12h", want: 36 * time.Hour},
+		{in: "d", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "1d-1h", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseDuration(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: expected %v, got %v", test.in, test.want, got)
+		}
+	}
+}
+
+func TestFailOn(t *testing.T) {
+	now := time.Date(2019, 10, 14, 0, 0, 0, 0, time.UTC)
+	p := policy{days: 30}
+	expiresIn := func(d time.Duration) result {
+		return result{certificate: &certificate{NotAfter: now.Add(d)}}
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		failOn  string
+		results []result
+		want    bool
+	}{
+		{failOn: "error", results: []result{expiresIn(90 * day)}, want: false},
+		{failOn: "error", results: []result{expiresIn(10 * day)}, want: false},
+		{failOn: "error", results: []result{expiresIn(-day)}, want: true},
+		{failOn: "error", results: []result{{err: errors.New("unreachable")}}, want: true},
+		{failOn: "warning", results: []result{expiresIn(90 * day)}, want: false},
+		{failOn: "warning", results: []result{expiresIn(10 * day)}, want: true},
+		{failOn: "expiring7d", results: []result{expiresIn(10 * day)}, want: false},
+		{failOn: "expiring7d", results: []result{expiresIn(5 * day)}, want: true},
+		{failOn: "expiring7d", results: []result{{err: errors.New("unreachable")}}, want: true},
+	}
+	for _, test := range tests {
+		f, err := parseFailOn(test.failOn)
+		if err != nil {
+			t.Fatalf("%s: %v", test.failOn, err)
+		}
+		if got := f.exceeded(p, test.results, now); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.failOn, test.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "errors", "expiring", "expiringsoon"} {
+		if _, err := parseFailOn(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
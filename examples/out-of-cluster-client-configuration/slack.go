@@ -0,0 +1,54 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// defaultSlackTemplate renders the text of Slack messages.
+const defaultSlackTemplate = `{{.Findings}} TLS certificates need attention{{if .Interrupted}} (partial scan, {{.Unchecked}} hosts not checked){{end}}
+{{range .Namespaces}}*{{with .Cluster}}{{.}}/{{end}}{{.Namespace}}*
+{{range .Findings}}• {{.Host}} ({{.Kind}} {{.Object}}): {{.Severity}}{{if .Error}} {{.Error}}{{else}} expires {{.NotAfter.Format "2006-01-02"}}, in {{.DaysRemaining}} days{{end}}{{with .CertManager}}{{if .RenewalTime}}, renewal scheduled {{.RenewalTime.Format "2006-01-02"}}{{else}}, not renewed by cert-manager{{end}}{{end}}
+{{end}}{{end}}`
+
+// slackNotifier posts summaries to a Slack incoming webhook, in watch mode
+// the ones of scan rounds.
+type slackNotifier struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+func (n *slackNotifier) perRound() {}
+
+func (n *slackNotifier) notify(ctx context.Context, s *summary) error {
+	text, err := render(n.template, s)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.client, n.url, "application/json", body)
+}
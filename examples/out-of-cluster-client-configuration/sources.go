@@ -0,0 +1,254 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerFactories are the shared informer factories sources are built
+// from. Ingresses are read with the typed client and every other resource
+// with the dynamic client, so that the checker does not depend on the client
+// libraries of OpenShift, the Gateway API or Istio.
+type informerFactories struct {
+	discovery discovery.DiscoveryInterface
+	typed     informers.SharedInformerFactory
+	// typedUnfiltered serves the typed objects other resources depend on,
+	// such as namespaces.
+	typedUnfiltered informers.SharedInformerFactory
+	// secrets serves the Opaque Secrets of the scope matching its secret
+	// selector.
+	secrets informers.SharedInformerFactory
+	// namespaced resources are filtered by the namespace and the selectors
+	// of the scope, cluster scoped ones by its selectors only. The objects
+	// other resources depend on are not filtered at all.
+	namespaced dynamicinformer.DynamicSharedInformerFactory
+	cluster    dynamicinformer.DynamicSharedInformerFactory
+	unfiltered dynamicinformer.DynamicSharedInformerFactory
+}
+
+// newInformerFactories returns the factories of the informers listing the
+// objects of filter, in pages of pageSize objects unless it is 0. The
+// informers never resync: objects are checked again by the scan rounds of
+// the controller, whose summaries cover all of them at once.
+func newInformerFactories(clientset kubernetes.Interface, dynamicClient dynamic.Interface, filter scope, pageSize int64) (*informerFactories, error) {
+	const resync = 0
+	options, err := filter.informerOptions()
+	if err != nil {
+		return nil, err
+	}
+	namespace, tweak, err := filter.listOptions()
+	if err != nil {
+		return nil, err
+	}
+	tweak = paginate(pageSize, tweak)
+	_, secretTweak, err := filter.secretListOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &informerFactories{
+		discovery:       clientset.Discovery(),
+		typed:           informers.NewSharedInformerFactoryWithOptions(clientset, resync, append(options, informers.WithTweakListOptions(tweak))...),
+		typedUnfiltered: informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithTweakListOptions(paginate(pageSize, nil))),
+		secrets:         informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithNamespace(namespace), informers.WithTweakListOptions(paginate(pageSize, secretTweak))),
+		namespaced:      dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resync, namespace, tweak),
+		cluster:         dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resync, metav1.NamespaceAll, tweak),
+		unfiltered:      dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resync, metav1.NamespaceAll, paginate(pageSize, nil)),
+	}, nil
+}
+
+// paginate returns tweak, if any, also making the lists of informers read
+// pages of size objects. Informers list from the watch cache of the API
+// server, which ignores limits and always answers with every object at once,
+// so paginated lists are read from etcd instead; watches are unchanged. tweak
+// is returned as it is when size is 0.
+func paginate(size int64, tweak func(*metav1.ListOptions)) func(*metav1.ListOptions) {
+	if size == 0 {
+		return tweak
+	}
+	return func(options *metav1.ListOptions) {
+		if tweak != nil {
+			tweak(options)
+		}
+		// Informers list at resource version 0 and watch from the
+		// resource version of the list.
+		if options.ResourceVersion == "0" {
+			options.ResourceVersion = ""
+			options.Limit = size
+		}
+	}
+}
+
+// start starts the informers of every source built so far.
+func (f *informerFactories) start(stopCh <-chan struct{}) {
+	f.typed.Start(stopCh)
+	f.typedUnfiltered.Start(stopCh)
+	f.secrets.Start(stopCh)
+	f.namespaced.Start(stopCh)
+	f.cluster.Start(stopCh)
+	f.unfiltered.Start(stopCh)
+}
+
+// sourceOptions configure how the targets of sources are checked.
+type sourceOptions struct {
+	// ports are the ports the TLS hosts of ingresses and routes are checked
+	// on unless they say otherwise.
+	ports []int
+	// viaStatus dials the TLS hosts of ingresses at the address of their
+	// load balancer.
+	viaStatus bool
+	// dialWebhooks enables dialing conversion webhooks.
+	dialWebhooks bool
+	// clusterCA is the certificate authority of the cluster, which kubelet
+	// serving certificates are verified against.
+	clusterCA []byte
+	// crdTargets are the resources of -crd-target and the paths of their
+	// certificates.
+	crdTargets []*crdTarget
+}
+
+// sources returns the sources of resources, as named by -resources.
+func (f *informerFactories) sources(resources []string, opts sourceOptions) ([]*source, error) {
+	var sources []*source
+	for _, resource := range resources {
+		switch strings.TrimSpace(resource) {
+		case "":
+			// -resources= only checks the resources of -crd-target.
+		case "ingresses":
+			sources = append(sources, ingressSource(f.typed.Extensions().V1beta1().Ingresses(), opts.ports, opts.viaStatus))
+		case "routes":
+			if _, err := servedResource(f.discovery, routesResource); err != nil {
+				return nil, err
+			}
+			sources = append(sources, routeSource(f.namespacedInformer(routesResource), opts.ports))
+		case "gateways":
+			gateways, err := servedResource(f.discovery, resourceVersions(gatewayGroup, "gateways", gatewayVersions...)...)
+			if err != nil {
+				return nil, err
+			}
+			// HTTPRoutes are read in the same version as gateways.
+			httpRoutes := gateways.GroupVersion().WithResource("httproutes")
+			src, err := gatewaySource(f.namespacedInformer(gateways), f.unfilteredInformer(httpRoutes))
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, src)
+		case "istio":
+			gateways, err := servedResource(f.discovery, resourceVersions(istioGroup, "gateways", istioVersions...)...)
+			if err != nil {
+				return nil, err
+			}
+			virtualServices := gateways.GroupVersion().WithResource("virtualservices")
+			src, err := istioGatewaySource(f.namespacedInformer(gateways), f.unfilteredInformer(virtualServices))
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, src)
+		case "webhooks":
+			for _, kind := range []string{validatingWebhookKind, mutatingWebhookKind} {
+				webhooks, err := servedResource(f.discovery, resourceVersions(admissionGroup, strings.ToLower(kind)+"s", caBundleVersions...)...)
+				if err != nil {
+					return nil, err
+				}
+				sources = append(sources, webhookSource(kind, f.clusterInformer(webhooks)))
+			}
+		case "apiservices":
+			apiServices, err := servedResource(f.discovery, resourceVersions(apiRegistrationGroup, "apiservices", caBundleVersions...)...)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, apiServiceSource(f.clusterInformer(apiServices)))
+		case "crds":
+			crds, err := servedResource(f.discovery, resourceVersions(apiExtensionsGroup, "customresourcedefinitions", crdVersions...)...)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, crdSource(f.clusterInformer(crds), opts.dialWebhooks))
+		case "services":
+			sources = append(sources, serviceSource(f.typed.Core().V1().Services(), opts.ports))
+		case "nodes":
+			sources = append(sources, nodeSource(f.typed.Core().V1().Nodes(), opts.clusterCA))
+		case "secrets":
+			sources = append(sources, opaqueSecretSource(f.secrets.Core().V1().Secrets()))
+		default:
+			return nil, fmt.Errorf("unknown resource %q", resource)
+		}
+	}
+	// The paths of the same resource are read by the same source.
+	var crdResources []schema.GroupVersionResource
+	paths := map[schema.GroupVersionResource][]*crdTarget{}
+	for _, t := range opts.crdTargets {
+		if _, ok := paths[t.resource]; !ok {
+			crdResources = append(crdResources, t.resource)
+		}
+		paths[t.resource] = append(paths[t.resource], t)
+	}
+	for _, resource := range crdResources {
+		r, err := apiResource(f.discovery, resource)
+		if err != nil {
+			return nil, err
+		}
+		informer := f.clusterInformer(resource)
+		if r.Namespaced {
+			informer = f.namespacedInformer(resource)
+		}
+		sources = append(sources, crdTargetSource(informer, r.Kind, paths[resource]))
+	}
+	return sources, nil
+}
+
+// certManagerCertificates returns an informer of the cert-manager
+// Certificates of every namespace, indexed by certificateIndexers, or nil if
+// the cluster does not serve them.
+func (f *informerFactories) certManagerCertificates() (cache.SharedIndexInformer, error) {
+	certificates, err := servedResource(f.discovery, resourceVersions(certManagerGroup, "certificates", certManagerVersions...)...)
+	if err != nil {
+		return nil, nil
+	}
+	informer := f.unfilteredInformer(certificates)
+	if err := informer.AddIndexers(certificateIndexers); err != nil {
+		return nil, err
+	}
+	return informer, nil
+}
+
+// namespaces returns an informer of every namespace, whatever the scope.
+func (f *informerFactories) namespaces() cache.SharedIndexInformer {
+	return f.typedUnfiltered.Core().V1().Namespaces().Informer()
+}
+
+func (f *informerFactories) namespacedInformer(resource schema.GroupVersionResource) cache.SharedIndexInformer {
+	return f.namespaced.ForResource(resource).Informer()
+}
+
+func (f *informerFactories) clusterInformer(resource schema.GroupVersionResource) cache.SharedIndexInformer {
+	return f.cluster.ForResource(resource).Informer()
+}
+
+func (f *informerFactories) unfilteredInformer(resource schema.GroupVersionResource) cache.SharedIndexInformer {
+	return f.unfiltered.ForResource(resource).Informer()
+}
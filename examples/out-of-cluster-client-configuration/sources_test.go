@@ -0,0 +1,41 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPaginate(t *testing.T) {
+	if tweak := paginate(0, nil); tweak != nil {
+		t.Error("expected lists to be left alone without a page size")
+	}
+	tweak := paginate(500, func(options *metav1.ListOptions) { options.LabelSelector = "team=shop" })
+
+	list := metav1.ListOptions{ResourceVersion: "0"}
+	tweak(&list)
+	if list.Limit != 500 || list.ResourceVersion != "" || list.LabelSelector != "team=shop" {
+		t.Errorf("expected a paginated list of the scope from etcd, got %+v", list)
+	}
+	watch := metav1.ListOptions{ResourceVersion: "12345"}
+	tweak(&watch)
+	if watch.Limit != 0 || watch.ResourceVersion != "12345" || watch.LabelSelector != "team=shop" {
+		t.Errorf("expected the watch of the scope to be unchanged, got %+v", watch)
+	}
+}
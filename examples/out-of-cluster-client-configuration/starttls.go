@@ -0,0 +1,245 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// startTLSPorts are the protocols upgraded to TLS with STARTTLS before the
+// handshake, and the ports they are checked on by default.
+var startTLSPorts = map[string]int{
+	"smtp":     25,
+	"imap":     143,
+	"ldap":     389,
+	"postgres": 5432,
+}
+
+// parseProtocol parses a -protocol: https, where the handshake starts right
+// away, or one of the protocols of startTLSPorts. https is returned as "".
+func parseProtocol(s string) (string, error) {
+	if s == "" || s == "https" {
+		return "", nil
+	}
+	if _, ok := startTLSPorts[s]; !ok {
+		return "", fmt.Errorf("unknown protocol %q: must be https, smtp, imap, ldap or postgres", s)
+	}
+	return s, nil
+}
+
+// startTLS asks the server at the other end of conn, speaking protocol, to
+// upgrade the connection to TLS. It returns once the server is ready for the
+// handshake; nothing is done for https.
+func startTLS(conn net.Conn, protocol string) error {
+	switch protocol {
+	case "":
+		return nil
+	case "smtp":
+		return startSMTP(conn)
+	case "imap":
+		return startIMAP(conn)
+	case "ldap":
+		return startLDAP(conn)
+	case "postgres":
+		return startPostgres(conn)
+	}
+	return fmt.Errorf("unknown protocol %q", protocol)
+}
+
+// startSMTP sends STARTTLS after the greeting and EHLO of RFC 3207.
+func startSMTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if _, err := readSMTPReply(r, "220"); err != nil {
+		return fmt.Errorf("SMTP greeting: %v", err)
+	}
+	if _, err := io.WriteString(conn, "EHLO cert-check\r\n"); err != nil {
+		return err
+	}
+	extensions, err := readSMTPReply(r, "250")
+	if err != nil {
+		return fmt.Errorf("SMTP EHLO: %v", err)
+	}
+	if !containsFold(extensions, "STARTTLS") {
+		return errors.New("SMTP server does not offer STARTTLS")
+	}
+	if _, err := io.WriteString(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	if _, err := readSMTPReply(r, "220"); err != nil {
+		return fmt.Errorf("SMTP STARTTLS: %v", err)
+	}
+	return nil
+}
+
+// readSMTPReply reads a reply, continued over lines of the form code-text,
+// and returns the texts of its lines. A reply with another code than code
+// is an error.
+func readSMTPReply(r *bufio.Reader, code string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 3 || line[:3] != code {
+			return nil, fmt.Errorf("unexpected reply %q", line)
+		}
+		if len(line) == 3 {
+			return append(lines, ""), nil
+		}
+		lines = append(lines, line[4:])
+		if line[3] != '-' {
+			return lines, nil
+		}
+	}
+}
+
+// containsFold reports whether the first word of one of lines is word,
+// ignoring case.
+func containsFold(lines []string, word string) bool {
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], word) {
+			return true
+		}
+	}
+	return false
+}
+
+// startIMAP sends STARTTLS after the greeting, as in RFC 3501.
+func startIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("IMAP greeting: %v", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected IMAP greeting %q", strings.TrimSpace(greeting))
+	}
+	if _, err := io.WriteString(conn, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	// Untagged responses may come before the tagged one.
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("IMAP STARTTLS: %v", err)
+		}
+		if !strings.HasPrefix(line, "a1 ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a1 OK") {
+			return fmt.Errorf("IMAP STARTTLS: %s", strings.TrimSpace(line[3:]))
+		}
+		return nil
+	}
+}
+
+// ldapStartTLSRequest is the LDAP extended request of RFC 4511 with message
+// ID 1 and the StartTLS OID, 1.3.6.1.4.1.1466.20037.
+var ldapStartTLSRequest = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+
+// startLDAP sends the StartTLS extended request and checks that its
+// response succeeded.
+func startLDAP(conn net.Conn) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	message, err := readBER(r, 0x30)
+	if err != nil {
+		return fmt.Errorf("LDAP StartTLS: %v", err)
+	}
+	body := bufio.NewReader(bytes.NewReader(message))
+	if _, err := readBER(body, 0x02); err != nil {
+		return fmt.Errorf("LDAP StartTLS message ID: %v", err)
+	}
+	response, err := readBER(body, 0x78)
+	if err != nil {
+		return fmt.Errorf("LDAP StartTLS response: %v", err)
+	}
+	code, err := readBER(bufio.NewReader(bytes.NewReader(response)), 0x0a)
+	if err != nil {
+		return fmt.Errorf("LDAP StartTLS result code: %v", err)
+	}
+	if len(code) != 1 || code[0] != 0 {
+		return fmt.Errorf("LDAP StartTLS failed with result code %v", code)
+	}
+	return nil
+}
+
+// readBER reads a BER element with tag from r and returns its contents.
+func readBER(r *bufio.Reader, tag byte) ([]byte, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if t != tag {
+		return nil, fmt.Errorf("unexpected tag 0x%02x, expected 0x%02x", t, tag)
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length := int(b)
+	if b&0x80 != 0 {
+		n := int(b & 0x7f)
+		if n == 0 || n > 3 {
+			return nil, fmt.Errorf("unsupported length of %d bytes", n)
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			if b, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(r, contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// postgresSSLRequest is the SSLRequest message of the PostgreSQL frontend
+// protocol: its length and the SSL request code.
+var postgresSSLRequest = []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+
+// startPostgres sends an SSLRequest and checks that the server accepts it.
+func startPostgres(conn net.Conn) error {
+	if _, err := conn.Write(postgresSSLRequest); err != nil {
+		return err
+	}
+	var answer [1]byte
+	if _, err := io.ReadFull(conn, answer[:]); err != nil {
+		return fmt.Errorf("PostgreSQL SSLRequest: %v", err)
+	}
+	switch answer[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("PostgreSQL server does not accept TLS connections")
+	}
+	return fmt.Errorf("unexpected PostgreSQL answer 0x%02x to SSLRequest", answer[0])
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// startTLSServer accepts a single connection, runs preamble on it and then
+// serves the handshake with config if preamble succeeded.
+func startTLSServer(t *testing.T, config *tls.Config, preamble func(net.Conn, *bufio.Reader) bool) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if preamble(conn, bufio.NewReader(conn)) {
+			tls.Server(conn, config).Handshake()
+		}
+	}()
+	return l
+}
+
+func TestStartTLS(t *testing.T) {
+	crt, key := newTestCertificate(t, "mail.example.com", false, nil, nil)
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{crt.Raw}, PrivateKey: key}}}
+
+	tests := []struct {
+		protocol string
+		preamble func(net.Conn, *bufio.Reader) bool
+		wantErr  string
+	}{
+		{
+			protocol: "smtp",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+				if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "EHLO ") {
+					return false
+				}
+				io.WriteString(conn, "250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+				if line, _ := r.ReadString('\n'); line != "STARTTLS\r\n" {
+					return false
+				}
+				io.WriteString(conn, "220 Ready to start TLS\r\n")
+				return true
+			},
+		},
+		{
+			protocol: "smtp",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+				r.ReadString('\n')
+				io.WriteString(conn, "250-mail.example.com\r\n250 PIPELINING\r\n")
+				r.ReadString('\n')
+				return false
+			},
+			wantErr: "SMTP server does not offer STARTTLS",
+		},
+		{
+			protocol: "imap",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				io.WriteString(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+				line, _ := r.ReadString('\n')
+				if !strings.HasSuffix(line, " STARTTLS\r\n") {
+					return false
+				}
+				tag := strings.Fields(line)[0]
+				io.WriteString(conn, "* CAPABILITY IMAP4rev1 STARTTLS\r\n"+tag+" OK Begin TLS negotiation now\r\n")
+				return true
+			},
+		},
+		{
+			protocol: "ldap",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				request := make([]byte, len(ldapStartTLSRequest))
+				if _, err := io.ReadFull(r, request); err != nil || string(request) != string(ldapStartTLSRequest) {
+					return false
+				}
+				// An ExtendedResponse with message ID 1, success, no
+				// matched DN, no diagnostic message and the StartTLS OID.
+				response := append([]byte{0x30, 0x24, 0x02, 0x01, 0x01, 0x78, 0x1f, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00, 0x8a, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+				conn.Write(response)
+				return true
+			},
+		},
+		{
+			protocol: "postgres",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				request := make([]byte, len(postgresSSLRequest))
+				if _, err := io.ReadFull(r, request); err != nil || string(request) != string(postgresSSLRequest) {
+					return false
+				}
+				conn.Write([]byte("S"))
+				return true
+			},
+		},
+		{
+			protocol: "postgres",
+			preamble: func(conn net.Conn, r *bufio.Reader) bool {
+				io.ReadFull(r, make([]byte, len(postgresSSLRequest)))
+				conn.Write([]byte("N"))
+				return false
+			},
+			wantErr: "PostgreSQL server does not accept TLS connections",
+		},
+	}
+	for _, test := range tests {
+		l := startTLSServer(t, config, test.preamble)
+		d := &dialer{timeout: wait.ForeverTestTimeout, insecureSkipVerify: true}
+		r := d.check(context.Background(), target{host: "mail.example.com", address: l.Addr().String(), protocol: test.protocol})
+		l.Close()
+		if test.wantErr != "" {
+			if r.err == nil || r.err.Error() != test.wantErr {
+				t.Errorf("%s: expected %q, got %v", test.protocol, test.wantErr, r.err)
+			}
+			continue
+		}
+		if r.err != nil {
+			t.Errorf("%s: unexpected error: %v", test.protocol, r.err)
+			continue
+		}
+		if r.certificate.Fingerprint != fingerprint(crt) {
+			t.Errorf("%s: expected the certificate of the server, got %+v", test.protocol, r.certificate)
+		}
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	for s, want := range map[string]string{"": "", "https": "", "smtp": "smtp", "postgres": "postgres"} {
+		if got, err := parseProtocol(s); err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q, %v", s, want, got, err)
+		}
+	}
+	if _, err := parseProtocol("ftp"); err == nil {
+		t.Error("expected an error for an unknown protocol")
+	}
+}
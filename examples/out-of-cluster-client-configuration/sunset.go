@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+type sunsetSignatureAlgorithm struct {
+	name string    // Human readable name of the signature algorithm.
+	date time.Time // Date the signature algorithm will be sunset.
+}
+
+// sunsetSignatureAlgorithms is an algorithm to string mapping for certificate
+// signature algorithms which have been or are being deprecated. See the
+// following links to learn more about SHA1's inclusion on this list.
+// - https://technet.microsoft.com/en-us/library/security/2880823.aspx
+// - http://googleonlinesecurity.blogspot.com/2014/09/gradually-sunsetting-sha-1.html
+var sunsetSignatureAlgorithms = map[x509.SignatureAlgorithm]sunsetSignatureAlgorithm{
+	x509.MD2WithRSA: {
+		name: "MD2 with RSA",
+		date: time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	x509.MD5WithRSA: {
+		name: "MD5 with RSA",
+		date: time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	x509.SHA1WithRSA: {
+		name: "SHA1 with RSA",
+		date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	x509.DSAWithSHA1: {
+		name: "DSA with SHA1",
+		date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	x509.ECDSAWithSHA1: {
+		name: "ECDSA with SHA1",
+		date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultPort is the port TLS hosts are checked on by default.
+const defaultPort = 443
+
+// target is a single TLS host of an ingress or of another kind of object
+// routing TLS traffic.
+type target struct {
+	// cluster is the kubeconfig context of the cluster the host is taken
+	// from when several clusters are scanned at once.
+	cluster   string
+	namespace string
+	// kind and object are the kind and the name of the object the host is
+	// taken from, e.g. Ingress and web.
+	kind       string
+	object     string
+	host       string
+	port       int
+	secretName string
+	// secretNamespace is the namespace of the Secret if it is not the one
+	// of the object.
+	secretNamespace string
+	// certificatePEM and keyPEM are the certificate chain and private key
+	// embedded in the object itself, if any. They take precedence over
+	// secretName.
+	certificatePEM []byte
+	keyPEM         []byte
+	// certificateFile and keyFile are the files the certificate chain and
+	// private key are read from when they are not embedded.
+	certificateFile string
+	keyFile         string
+	// rootsPEM are the certificate authorities the certificate served by
+	// host is verified against instead of the usual ones, if any.
+	rootsPEM []byte
+	// caBundle is set if certificatePEM is a bundle of certificate
+	// authorities rather than the certificate of host, in which case host
+	// only names the bundle and is never dialed.
+	caBundle bool
+	// ref is the object the host is taken from, the one events are recorded
+	// on. It is nil for hosts that are not taken from an object.
+	ref *v1.ObjectReference
+	// address is the host:port the host is dialed at, sending host as SNI.
+	// The host and port themselves are dialed when empty.
+	address string
+	// backend is the address the host resolved to when every address of
+	// the host is checked separately.
+	backend string
+	// warnBefore, if set, is the warning window of the host, overriding
+	// the one of the policy.
+	warnBefore time.Duration
+	// alpn, if set, are the ALPN protocols offered when dialing the host,
+	// overriding the ones of the dialer.
+	alpn []string
+	// protocol, if set, is the protocol the host speaks before upgrading
+	// the connection with STARTTLS, overriding the one of the dialer.
+	protocol string
+	// clientSecret, if set, is the kubernetes.io/tls Secret in the
+	// namespace of the host holding the client certificate it is sent,
+	// read into clientCertificate before the host is dialed.
+	clientSecret      string
+	clientCertificate *tls.Certificate
+}
+
+// name identifies the target in reports: its host, followed by the port if
+// it is not the default one and by the backend address if any.
+func (t target) name() string {
+	name := t.host
+	if t.port != defaultPort && t.port != 0 {
+		name = net.JoinHostPort(t.host, strconv.Itoa(t.port))
+	}
+	if t.backend != "" {
+		name += " (" + t.backend + ")"
+	}
+	return name
+}
+
+// storedIn describes where the certificate of t is stored: its Secret, or its
+// object if the certificate is embedded in it.
+func (t target) storedIn() string {
+	if t.certificateFile != "" {
+		return "file " + t.certificateFile
+	}
+	if t.certificatePEM != nil {
+		return strings.ToLower(t.kind) + " " + t.namespace + "/" + t.object
+	}
+	namespace, name := t.secretRef()
+	return "secret " + namespace + "/" + name
+}
+
+// secretRef returns the namespace and the name of the Secret of t.
+func (t target) secretRef() (string, string) {
+	if t.secretNamespace != "" {
+		return t.secretNamespace, t.secretName
+	}
+	return t.namespace, t.secretName
+}
+
+// objectReference returns a reference to obj, an object of kind in
+// apiVersion. Neither is set on objects of typed informers, hence the
+// explicit arguments.
+func objectReference(apiVersion, kind string, obj metav1.Object) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
+}
+
+// sortTargets orders targets by cluster, namespace, object, host, port and
+// backend so that reports are stable between runs.
+func sortTargets(targets []target) {
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.object != b.object {
+			return a.object < b.object
+		}
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.port != b.port {
+			return a.port < b.port
+		}
+		return a.backend < b.backend
+	})
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testcerts issues self-signed certificates, and certificates of a
+// private certificate authority, and serves them on local TLS listeners, so
+// that certificate checks can be exercised without real endpoints.
+package testcerts
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultKey is the key of certificates whose options do not set one.
+const DefaultKey = "ecdsa-p256"
+
+// Options are the properties of an issued certificate.
+type Options struct {
+	// CommonName is the common name of the subject.
+	CommonName string
+	// SANs are the DNS names and IP addresses the certificate is valid for,
+	// the common name if empty.
+	SANs []string
+	// NotBefore is when the certificate becomes valid, an hour ago if zero.
+	NotBefore time.Time
+	// NotAfter is when the certificate expires, which may be in the past, a
+	// year from now if zero.
+	NotAfter time.Time
+	// Key is the algorithm and size of the key: rsa-<bits>, e.g. rsa-2048,
+	// ecdsa-p256, ecdsa-p384, ecdsa-p521 or ed25519. DefaultKey if empty.
+	Key string
+	// SignatureAlgorithm is the algorithm the certificate is signed with,
+	// the default of the key of the issuer if unknown.
+	SignatureAlgorithm x509.SignatureAlgorithm
+	// IsCA issues a certificate that can issue others.
+	IsCA bool
+}
+
+// KeyPair is an issued certificate and its key.
+type KeyPair struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+	// Chain are the certificates of the issuers of Certificate, up to but
+	// excluding the root, served along with it.
+	Chain []*x509.Certificate
+	// issuer is the key pair that issued Certificate, nil if it is
+	// self-signed.
+	issuer *KeyPair
+}
+
+// SelfSigned returns a certificate signed with its own key.
+func SelfSigned(o Options) (*KeyPair, error) {
+	return issue(o, nil)
+}
+
+// NewAuthority returns the root certificate of a private certificate
+// authority named name, issuing certificates with Issue.
+func NewAuthority(name string, o Options) (*KeyPair, error) {
+	o.CommonName = name
+	o.IsCA = true
+	if o.SANs == nil {
+		o.SANs = []string{}
+	}
+	return issue(o, nil)
+}
+
+// Issue returns a certificate issued by k, which must be a certificate
+// authority.
+func (k *KeyPair) Issue(o Options) (*KeyPair, error) {
+	if !k.Certificate.IsCA {
+		return nil, fmt.Errorf("%s is not a certificate authority", k.Certificate.Subject.CommonName)
+	}
+	return issue(o, k)
+}
+
+func issue(o Options, issuer *KeyPair) (*KeyPair, error) {
+	key, err := GenerateKey(o.Key)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: o.CommonName},
+		NotBefore:             o.NotBefore,
+		NotAfter:              o.NotAfter,
+		SignatureAlgorithm:    o.SignatureAlgorithm,
+		BasicConstraintsValid: true,
+		IsCA:                  o.IsCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = now.Add(-time.Hour)
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotAfter = now.AddDate(1, 0, 0)
+	}
+	if o.IsCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+	}
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	sans := o.SANs
+	if sans == nil && o.CommonName != "" {
+		sans = []string{o.CommonName}
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	parent, parentKey := tmpl, key
+	if issuer != nil {
+		parent, parentKey = issuer.Certificate, issuer.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	k := &KeyPair{Certificate: crt, Key: key, issuer: issuer}
+	// The root is left out of the chain, as servers do.
+	for i := issuer; i != nil && i.issuer != nil; i = i.issuer {
+		k.Chain = append(k.Chain, i.Certificate)
+	}
+	return k, nil
+}
+
+// GenerateKey returns a new key of the algorithm and size of name, as the
+// Key of Options.
+func GenerateKey(name string) (crypto.Signer, error) {
+	if name == "" {
+		name = DefaultKey
+	}
+	switch name {
+	case "ecdsa-p256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ecdsa-p521":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	if strings.HasPrefix(name, "rsa-") {
+		bits, err := strconv.Atoi(strings.TrimPrefix(name, "rsa-"))
+		if err == nil && bits >= 512 {
+			return rsa.GenerateKey(rand.Reader, bits)
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q: must be rsa-<bits>, ecdsa-p256, ecdsa-p384, ecdsa-p521 or ed25519", name)
+}
+
+// TLSCertificate returns k as served by a TLS server, with its chain.
+func (k *KeyPair) TLSCertificate() tls.Certificate {
+	crt := tls.Certificate{Certificate: [][]byte{k.Certificate.Raw}, PrivateKey: k.Key, Leaf: k.Certificate}
+	for _, c := range k.Chain {
+		crt.Certificate = append(crt.Certificate, c.Raw)
+	}
+	return crt
+}
+
+// CertificatePEM returns the certificate of k followed by its chain, PEM
+// encoded.
+func (k *KeyPair) CertificatePEM() []byte {
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k.Certificate.Raw})
+	for _, c := range k.Chain {
+		b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return b
+}
+
+// KeyPEM returns the key of k, PEM encoded as PKCS #8.
+func (k *KeyPair) KeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Server is a local TLS listener serving a certificate.
+type Server struct {
+	// Addr is the address the server listens on, host:port.
+	Addr     string
+	listener net.Listener
+	done     chan struct{}
+}
+
+// Serve serves the certificates of pairs on addr, e.g. 127.0.0.1:0 for a
+// free port, until it is closed. The certificate served is the one valid for
+// the name the client sends, the first one otherwise. Connections are
+// closed once the handshake is done.
+func Serve(addr string, pairs ...*KeyPair) (*Server, error) {
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no certificate to serve")
+	}
+	var certificates []tls.Certificate
+	for _, k := range pairs {
+		certificates = append(certificates, k.TLSCertificate())
+	}
+	config := &tls.Config{
+		// The certificates are picked by name only, so that expired ones
+		// are served as well.
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			for i := range certificates {
+				if hello.ServerName != "" && certificates[i].Leaf.VerifyHostname(hello.ServerName) == nil {
+					return &certificates[i], nil
+				}
+			}
+			return &certificates[0], nil
+		},
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: l.Addr().String(), listener: l, done: make(chan struct{})}
+	go s.serve(tls.NewListener(l, config))
+	return s, nil
+}
+
+func (s *Server) serve(l net.Listener) {
+	defer close(s.done)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			conn.(*tls.Conn).Handshake()
+		}()
+	}
+}
+
+// Close stops serving.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testcerts
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIssue(t *testing.T) {
+	root, err := NewAuthority("Test Root", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, err := root.Issue(Options{CommonName: "Test Intermediate", SANs: []string{}, IsCA: true, Key: "rsa-2048"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	leaf, err := intermediate.Issue(Options{CommonName: "shop.test", SANs: []string{"shop.test", "127.0.0.1"}, NotAfter: notAfter, SignatureAlgorithm: x509.SHA384WithRSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := leaf.Certificate
+	if !c.NotAfter.Equal(notAfter) || c.SignatureAlgorithm != x509.SHA384WithRSA || c.PublicKeyAlgorithm != x509.ECDSA {
+		t.Errorf("unexpected certificate %v, %v, %v", c.NotAfter, c.SignatureAlgorithm, c.PublicKeyAlgorithm)
+	}
+	if !reflect.DeepEqual(c.DNSNames, []string{"shop.test"}) || len(c.IPAddresses) != 1 || !c.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("unexpected SANs %v %v", c.DNSNames, c.IPAddresses)
+	}
+	if len(leaf.Chain) != 1 || leaf.Chain[0] != intermediate.Certificate {
+		t.Errorf("expected the intermediate to be the chain, got %v", leaf.Chain)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root.Certificate)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intermediate.Certificate)
+	if _, err := c.Verify(x509.VerifyOptions{DNSName: "shop.test", Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("expected the certificate to verify, got %v", err)
+	}
+	if _, err := leaf.Issue(Options{CommonName: "api.test"}); err == nil {
+		t.Error("expected a leaf certificate not to issue others")
+	}
+
+	for _, key := range []string{"rsa-1024", "ecdsa-p384", "ecdsa-p521", "ed25519"} {
+		if _, err := SelfSigned(Options{CommonName: "shop.test", Key: key}); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+	for _, key := range []string{"rsa", "rsa-64", "dsa"} {
+		if _, err := GenerateKey(key); err == nil {
+			t.Errorf("expected key %q to be invalid", key)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	expired, err := SelfSigned(Options{CommonName: "expired.test", NotBefore: time.Now().AddDate(0, 0, -2), NotAfter: time.Now().AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shop, err := SelfSigned(Options{CommonName: "shop.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Serve("127.0.0.1:0", expired, shop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for name, want := range map[string]*KeyPair{"shop.test": shop, "expired.test": expired, "": expired} {
+		conn, err := tls.Dial("tcp", s.Addr, &tls.Config{ServerName: name, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if got := conn.ConnectionState().PeerCertificates[0]; !got.Equal(want.Certificate) {
+			t.Errorf("%q: expected the certificate of %s, got the one of %s", name, want.Certificate.Subject.CommonName, got.Subject.CommonName)
+		}
+		conn.Close()
+	}
+	if _, err := Serve("127.0.0.1:0"); err == nil {
+		t.Error("expected serving no certificate to fail")
+	}
+}
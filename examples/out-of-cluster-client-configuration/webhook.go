@@ -0,0 +1,53 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// webhookNotifier posts summaries to an arbitrary URL, either as JSON or as
+// rendered by a template. In watch mode it is sent the ones of scan rounds.
+type webhookNotifier struct {
+	url string
+	// template renders the request body; the summary is sent as JSON when
+	// it is nil.
+	template *template.Template
+	client   *http.Client
+}
+
+func (n *webhookNotifier) perRound() {}
+
+func (n *webhookNotifier) notify(ctx context.Context, s *summary) error {
+	var body []byte
+	if n.template != nil {
+		text, err := render(n.template, s)
+		if err != nil {
+			return err
+		}
+		body = []byte(text)
+	} else {
+		var err error
+		if body, err = json.Marshal(s); err != nil {
+			return err
+		}
+	}
+	return post(ctx, n.client, n.url, "application/json", body)
+}